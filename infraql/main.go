@@ -23,7 +23,7 @@ import (
 
 func main() {
 	if err := execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
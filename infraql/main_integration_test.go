@@ -107,6 +107,38 @@ func TestInsertAwaitExecSuccess(t *testing.T) {
 	infraqltestutil.RunStdOutTestAgainstFiles(t, execStuff, []string{testobjects.ExpectedComputeNetworkInsertAsyncFile})
 }
 
+func TestInsertAwaitExecFailed(t *testing.T) {
+
+	runtimeCtx, err := infraqltestutil.GetRuntimeCtx(config.GetGoogleProviderString(), "text")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	args := []string{
+		"--loglevel=warn",
+		fmt.Sprintf("--keyfilepath=%s", runtimeCtx.KeyFilePath),
+		fmt.Sprintf("--providerroot=%s", runtimeCtx.ProviderRootPath),
+		fmt.Sprintf("--dbfilepath=%s", runtimeCtx.DbFilePath),
+		fmt.Sprintf("--dbinitfilepath=%s", runtimeCtx.DbInitFilePath),
+		"-i=stdin",
+		"exec",
+		testobjects.SimpleInsertExecComputeNetwork,
+	}
+	t.Logf("k8s e2e integration: about to invoke main() with args:\n\t%s", strings.Join(args, ",\n\t"))
+
+	infraqltestutil.SetupFailedInsertGoogleComputeNetworks(t)
+
+	os.Args = args
+
+	failedExecStuff := func(t *testing.T) {
+		if err := execute(); err == nil {
+			t.Fatalf("Test failed: expected an error for the failed operation")
+		}
+	}
+
+	infraqltestutil.RunStdOutTestAgainstFiles(t, failedExecStuff, []string{testobjects.ExpectedComputeNetworkInsertAsyncFailedFile})
+}
+
 func TestDeleteAwaitSuccess(t *testing.T) {
 
 	runtimeCtx, err := infraqltestutil.GetRuntimeCtx(config.GetGoogleProviderString(), "text")
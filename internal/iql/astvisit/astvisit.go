@@ -66,23 +66,14 @@ func (v *DRMAstVisitor) computeQIDWhereSubTree() (sqlparser.Expr, error) {
 			return v.generateQIDComparison(k.As), nil
 		}
 	}
-	var retVal, curAndExpr *sqlparser.AndExpr
-	i := 0
+	var retVal sqlparser.Expr
 	for k := range v.tablesCited {
 		comparisonExpr := v.generateQIDComparison(k.As)
-		if i == 0 {
-			curAndExpr = &sqlparser.AndExpr{Left: comparisonExpr}
-			retVal = curAndExpr
-			i++
+		if retVal == nil {
+			retVal = comparisonExpr
 			continue
 		}
-		if i == tblCount {
-			curAndExpr.Right = comparisonExpr
-			break
-		}
-		newAndExpr := &sqlparser.AndExpr{Left: comparisonExpr}
-		curAndExpr.Right = newAndExpr
-		curAndExpr = newAndExpr
+		retVal = &sqlparser.AndExpr{Left: retVal, Right: comparisonExpr}
 	}
 	return retVal, nil
 }
@@ -126,13 +117,15 @@ func (v *DRMAstVisitor) Visit(node sqlparser.SQLNode) error {
 		}
 		qIdSubtree, _ := fromVis.computeQIDWhereSubTree()
 		augmentedWhere := node.Where
-		if augmentedWhere != nil {
+		switch {
+		case qIdSubtree == nil:
+		case augmentedWhere != nil:
 			newWhereExpr := &sqlparser.AndExpr{
 				Left:  node.Where.Expr,
 				Right: qIdSubtree,
 			}
 			augmentedWhere = sqlparser.NewWhere(sqlparser.WhereStr, newWhereExpr)
-		} else {
+		default:
 			augmentedWhere = sqlparser.NewWhere(sqlparser.WhereStr, qIdSubtree)
 		}
 		augmentedWhere.Accept(v)
@@ -5,6 +5,8 @@ import (
 	"infraql/internal/iql/drm"
 	"infraql/internal/iql/dto"
 	"infraql/internal/iql/httpexec"
+	"infraql/internal/iql/iqlerror"
+	"infraql/internal/iql/metadata"
 	"infraql/internal/iql/plan"
 	"infraql/internal/iql/provider"
 	"infraql/internal/iql/taxonomy"
@@ -18,19 +20,19 @@ import (
 var MonitorPollIntervalSeconds int = 10
 
 type IAsyncMonitor interface {
-	GetMonitorPrimitive(heirarchy *taxonomy.HeirarchyObjects, precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx) (plan.IPrimitive, error)
+	GetMonitorPrimitive(heirarchy *taxonomy.HeirarchyObjects, precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx, params MonitorParams) (plan.IPrimitive, error)
+	GetOperationMonitorPrimitive(precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx, params MonitorParams, pollUrl string) (plan.IPrimitive, error)
 }
 
 type AsyncHttpMonitorPrimitive struct {
-	heirarchy           *taxonomy.HeirarchyObjects
-	initialCtx          plan.IPrimitiveCtx
-	precursor           plan.IPrimitive
-	transferPayload     map[string]interface{}
-	Executor            func(pc plan.IPrimitiveCtx) dto.ExecutorOutput
-	monitorExecutor     func(pc plan.IPrimitiveCtx) dto.ExecutorOutput
-	elapsedSeconds      int
-	pollIntervalSeconds int
-	noStatus            bool
+	heirarchy       *taxonomy.HeirarchyObjects
+	initialCtx      plan.IPrimitiveCtx
+	precursor       plan.IPrimitive
+	transferPayload map[string]interface{}
+	Executor        func(pc plan.IPrimitiveCtx) dto.ExecutorOutput
+	monitorExecutor func(pc plan.IPrimitiveCtx) dto.ExecutorOutput
+	params          MonitorParams
+	noStatus        bool
 }
 
 func (pr *AsyncHttpMonitorPrimitive) SetTxnId(id int) {
@@ -52,6 +54,7 @@ func (asm *AsyncHttpMonitorPrimitive) Execute(pc plan.IPrimitiveCtx) dto.Executo
 			pc.GetErrWriter(),
 			pc.GetCommentDirectives(),
 		)
+		asyP.SetContext(pc.GetContext())
 		return asm.Executor(asyP)
 	}
 	return dto.NewExecutorOutput(nil, nil, nil, nil)
@@ -61,33 +64,39 @@ func (pr *AsyncHttpMonitorPrimitive) GetPreparedStatementContext() *drm.Prepared
 	return nil
 }
 
-func NewAsyncMonitor(prov provider.IProvider) (IAsyncMonitor, error) {
+// NewAsyncMonitor returns the monitor for the provider's operations,
+// whose polling is counted in the stats, if any.
+func NewAsyncMonitor(prov provider.IProvider, runtimeCtx dto.RuntimeCtx, stats *dto.QueryStats) (IAsyncMonitor, error) {
+	retryPolicy := httpexec.NewRetryPolicy(runtimeCtx)
+	retryPolicy.Stats = stats
 	switch prov.GetProviderString() {
 	case "google":
-		return newGoogleAsyncMonitor(prov, prov.GetVersion())
+		return newGoogleAsyncMonitor(prov, prov.GetVersion(), retryPolicy)
 	}
 	return nil, fmt.Errorf("async operation monitor for provider = '%s', api version = '%s' currently not supported", prov.GetProviderString(), prov.GetVersion())
 }
 
-func newGoogleAsyncMonitor(prov provider.IProvider, version string) (IAsyncMonitor, error) {
+func newGoogleAsyncMonitor(prov provider.IProvider, version string, retryPolicy httpexec.RetryPolicy) (IAsyncMonitor, error) {
 	switch version {
 	case "v1":
 		return &DefaultGoogleAsyncMonitor{
-			provider: prov,
+			provider:    prov,
+			retryPolicy: retryPolicy,
 		}, nil
 	}
 	return nil, fmt.Errorf("async operation monitor for google, api version = '%s' currently not supported", version)
 }
 
 type DefaultGoogleAsyncMonitor struct {
-	provider  provider.IProvider
-	precursor plan.IPrimitive
+	provider    provider.IProvider
+	precursor   plan.IPrimitive
+	retryPolicy httpexec.RetryPolicy
 }
 
-func (gm *DefaultGoogleAsyncMonitor) GetMonitorPrimitive(heirarchy *taxonomy.HeirarchyObjects, precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx) (plan.IPrimitive, error) {
+func (gm *DefaultGoogleAsyncMonitor) GetMonitorPrimitive(heirarchy *taxonomy.HeirarchyObjects, precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx, params MonitorParams) (plan.IPrimitive, error) {
 	switch strings.ToLower(heirarchy.Provider.GetVersion()) {
 	case "v1":
-		return gm.getV1Monitor(heirarchy, precursor, initialCtx)
+		return gm.getV1Monitor(heirarchy, precursor, initialCtx, params)
 	}
 	return nil, fmt.Errorf("monitor primitive unavailable for service = '%s', resource = '%s', method = '%s'", heirarchy.HeirarchyIds.ServiceStr, heirarchy.HeirarchyIds.ResourceStr, heirarchy.HeirarchyIds.MethodStr)
 }
@@ -105,57 +114,95 @@ func getOperationDescriptor(body map[string]interface{}) string {
 					operationDescriptor = fmt.Sprintf("%s: %s", descriptorStr, typeStr)
 				}
 			}
+			return operationDescriptor
 		}
 	}
+	// google.longrunning operations are described by name alone
+	if name, ok := body["name"].(string); ok && name != "" {
+		operationDescriptor = fmt.Sprintf("operation %s", name)
+	}
 	return operationDescriptor
 }
 
-func (gm *DefaultGoogleAsyncMonitor) getV1Monitor(heirarchy *taxonomy.HeirarchyObjects, precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx) (plan.IPrimitive, error) {
+// GetOperationMonitorPrimitive monitors an operation already begun, as
+// returned by the precursor, polling it at pollUrl with the provider's
+// default scopes.
+func (gm *DefaultGoogleAsyncMonitor) GetOperationMonitorPrimitive(precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx, params MonitorParams, pollUrl string) (plan.IPrimitive, error) {
+	return gm.newMonitorPrimitive(nil, &fixedUrlOperationStrategy{pollUrl: pollUrl}, precursor, initialCtx, params, nil), nil
+}
+
+func (gm *DefaultGoogleAsyncMonitor) getV1Monitor(heirarchy *taxonomy.HeirarchyObjects, precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx, params MonitorParams) (plan.IPrimitive, error) {
+	strategy := GetOperationStrategy(heirarchy)
+	if strategy == nil {
+		return nil, nil
+	}
+	// operations are polled with the scopes of the method that began them
+	scopes := metadata.GetMinimalScopes([][]string{heirarchy.Method.GetAcceptedScopes(false)})
+	return gm.newMonitorPrimitive(heirarchy, strategy, precursor, initialCtx, params, scopes), nil
+}
+
+func (gm *DefaultGoogleAsyncMonitor) newMonitorPrimitive(heirarchy *taxonomy.HeirarchyObjects, strategy OperationStrategy, precursor plan.IPrimitive, initialCtx plan.IPrimitiveCtx, params MonitorParams, scopes []string) *AsyncHttpMonitorPrimitive {
 	asyncPrim := AsyncHttpMonitorPrimitive{
-		heirarchy:           heirarchy,
-		initialCtx:          initialCtx,
-		precursor:           precursor,
-		elapsedSeconds:      0,
-		pollIntervalSeconds: MonitorPollIntervalSeconds,
+		heirarchy:  heirarchy,
+		initialCtx: initialCtx,
+		precursor:  precursor,
+		params:     params,
 	}
 	if cd := initialCtx.GetCommentDirectives(); cd != nil {
 		asyncPrim.noStatus = cd.IsSet("NOSTATUS")
 	}
-	if heirarchy.Method.ResponseType.Type == "Operation" {
-		asyncPrim.Executor = func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
-			if pc == nil {
-				return dto.NewExecutorOutput(nil, nil, nil, fmt.Errorf("cannot execute monitor: nil plan primitive"))
-			}
-			body := pc.GetBody()
-			if body == nil {
-				return dto.NewExecutorOutput(nil, nil, nil, fmt.Errorf("cannot execute monitor: no body present"))
-			}
+	asyncPrim.Executor = func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+		if pc == nil {
+			return dto.NewExecutorOutput(nil, nil, nil, fmt.Errorf("cannot execute monitor: nil plan primitive"))
+		}
+		body := pc.GetBody()
+		if body == nil {
+			return dto.NewExecutorOutput(nil, nil, nil, fmt.Errorf("cannot execute monitor: no body present"))
+		}
+		authCtx := pc.GetAuthContext()
+		ctx := pc.GetContext()
+		retryPolicy := gm.retryPolicy
+		retryPolicy.Context = ctx
+		operationDescriptor := getOperationDescriptor(body)
+		start := time.Now()
+		interval := asyncPrim.params.Interval
+		for {
 			log.Infoln(fmt.Sprintf("body = %v", body))
-
-			operationDescriptor := getOperationDescriptor(body)
-			endTime, endTimeOk := body["endTime"]
-			if endTimeOk && endTime != "" {
+			if strategy.IsDone(body) {
 				return prepareReultSet(&asyncPrim, pc, body, operationDescriptor)
 			}
-			url, ok := body["selfLink"]
-			if !ok {
-				return dto.NewExecutorOutput(nil, nil, nil, fmt.Errorf("cannot execute monitor: no 'selfLink' property present"))
+			url, err := strategy.GetPollUrl(heirarchy, body)
+			if err != nil {
+				return dto.NewExecutorOutput(nil, nil, nil, err)
 			}
-			authCtx := pc.GetAuthContext()
 			if authCtx == nil {
 				return dto.NewExecutorOutput(nil, nil, nil, fmt.Errorf("cannot execute monitor: no auth context"))
 			}
-			httpClient, httpClientErr := gm.provider.Auth(authCtx, authCtx.Type, false)
+			wait := interval
+			if timeout := asyncPrim.params.Timeout; timeout > 0 {
+				remaining := timeout - time.Since(start)
+				if remaining <= 0 {
+					return dto.NewExecutorOutput(nil, body, nil, &AwaitTimeoutError{Operation: operationDescriptor, SelfLink: url, Timeout: timeout})
+				}
+				if wait > remaining {
+					wait = remaining
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return dto.NewExecutorOutput(nil, body, nil, fmt.Errorf("await of %s cancelled, it may still be in progress, see: %s", operationDescriptor, url))
+			case <-time.After(wait):
+			}
+			interval = asyncPrim.params.getNextInterval(interval)
+			httpClient, httpClientErr := gm.provider.AuthWithScopes(authCtx, scopes)
 			if httpClientErr != nil {
 				return dto.NewExecutorOutput(nil, nil, nil, httpClientErr)
 			}
-			time.Sleep(time.Duration(asyncPrim.pollIntervalSeconds) * time.Second)
-			asyncPrim.elapsedSeconds += asyncPrim.pollIntervalSeconds
 			if !asyncPrim.noStatus {
-				pc.GetWriter().Write([]byte(fmt.Sprintf("%s in progress, %d seconds elapsed", operationDescriptor, asyncPrim.elapsedSeconds) + fmt.Sprintln("")))
+				pc.GetWriter().Write([]byte(fmt.Sprintf("%s in progress, %d seconds elapsed", operationDescriptor, int(time.Since(start).Seconds())) + fmt.Sprintln("")))
 			}
-			rc, err := getMonitorRequestCtx(url.(string))
-			response, apiErr := httpexec.HTTPApiCall(httpClient, rc)
+			rc, err := getMonitorRequestCtx(url)
+			response, apiErr := httpexec.HTTPApiCallWithRetry(httpClient, rc, retryPolicy)
 			if apiErr != nil {
 				return dto.NewExecutorOutput(nil, nil, nil, apiErr)
 			}
@@ -163,30 +210,61 @@ func (gm *DefaultGoogleAsyncMonitor) getV1Monitor(heirarchy *taxonomy.HeirarchyO
 			if err != nil {
 				return dto.NewExecutorOutput(nil, nil, nil, err)
 			}
-			return asyncPrim.Executor(dto.NewBasicPrimitiveContext(
-				target,
-				authCtx,
-				pc.GetWriter(),
-				pc.GetErrWriter(),
-				pc.GetCommentDirectives(),
-			))
+			body = target
 		}
-		return &asyncPrim, nil
 	}
-	return nil, nil
+	return &asyncPrim
 }
 
+// getOperationWarnings renders the warnings field of an operation.
+func getOperationWarnings(body map[string]interface{}) []string {
+	var retVal []string
+	warnings, ok := body["warnings"].([]interface{})
+	if !ok {
+		return retVal
+	}
+	for _, w := range warnings {
+		m, ok := w.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		code, _ := m["code"].(string)
+		msg, _ := m["message"].(string)
+		if code == "" {
+			retVal = append(retVal, fmt.Sprintf("warning: %s", msg))
+			continue
+		}
+		retVal = append(retVal, fmt.Sprintf("warning: %s: %s", code, msg))
+	}
+	return retVal
+}
+
+// prepareReultSet reports the outcome of a completed operation, which
+// is an error if the operation failed, with any warnings as messages.
 func prepareReultSet(prim *AsyncHttpMonitorPrimitive, pc plan.IPrimitiveCtx, target map[string]interface{}, operationDescriptor string) dto.ExecutorOutput {
+	var msgs *dto.BackendMessages
+	if warnings := getOperationWarnings(target); len(warnings) > 0 {
+		msgs = &dto.BackendMessages{WorkingMessages: warnings}
+	}
+	var err error
+	if opErr, ok := target["error"]; ok && opErr != nil {
+		selfLink, _ := target["selfLink"].(string)
+		err = iqlerror.NewOperationError(operationDescriptor, selfLink, opErr)
+	}
 	payload := dto.PrepareResultSetDTO{
 		OutputBody:  target,
-		Msg:         nil,
+		Msg:         msgs,
 		RowMap:      nil,
 		ColumnOrder: nil,
 		RowSort:     nil,
-		Err:         nil,
+		Err:         err,
 	}
 	if !prim.noStatus {
-		pc.GetWriter().Write([]byte(fmt.Sprintf("%s complete", operationDescriptor) + fmt.Sprintln("")))
+		status := "complete"
+		if err != nil {
+			status = "failed"
+		}
+		pc.GetWriter().Write([]byte(fmt.Sprintf("%s %s", operationDescriptor, status) + fmt.Sprintln("")))
 	}
 	return util.PrepareResultSet(payload)
 }
@@ -0,0 +1,155 @@
+package asyncmonitor_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "infraql/internal/iql/asyncmonitor"
+	"infraql/internal/iql/config"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/primitivebuilder"
+	"infraql/internal/iql/provider"
+)
+
+const runningOperationTemplate string = `{
+	"kind": "compute#operation",
+	"name": "op-1",
+	"operationType": "insert",
+	"status": "RUNNING",
+	"selfLink": "%s"
+}`
+
+// stubProvider authorises polls with the default client; the
+// monitor uses nothing else of the provider.
+type stubProvider struct {
+	provider.IProvider
+}
+
+func (sp *stubProvider) AuthWithScopes(authCtx *dto.AuthCtx, scopes []string) (*http.Client, error) {
+	return http.DefaultClient, nil
+}
+
+func (sp *stubProvider) GetProviderString() string {
+	return config.GetGoogleProviderString()
+}
+
+func (sp *stubProvider) GetVersion() string {
+	return "v1"
+}
+
+// operationServer stands in for an operation which never completes,
+// recording when it is polled.
+type operationServer struct {
+	mu    sync.Mutex
+	polls []time.Time
+}
+
+func (s *operationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.polls = append(s.polls, time.Now())
+	s.mu.Unlock()
+	fmt.Fprintf(w, runningOperationTemplate, "http://"+r.Host+r.URL.Path)
+}
+
+func (s *operationServer) getPolls() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time{}, s.polls...)
+}
+
+func getTestMonitorPrimitive(t *testing.T, pollUrl string, params MonitorParams) plan.IPrimitive {
+	monitor, err := NewAsyncMonitor(&stubProvider{}, dto.RuntimeCtx{}, nil)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	precursor := primitivebuilder.NewLocalPrimitive(func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+		return dto.NewExecutorOutput(nil, map[string]interface{}{"status": "RUNNING", "selfLink": pollUrl}, nil, nil)
+	})
+	initialCtx := dto.NewBasicPrimitiveContext(nil, &dto.AuthCtx{}, ioutil.Discard, ioutil.Discard, nil)
+	prim, err := monitor.GetOperationMonitorPrimitive(precursor, initialCtx, params, pollUrl)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return prim
+}
+
+func TestAwaitTimeout(t *testing.T) {
+	server := httptest.NewServer(&operationServer{})
+	defer server.Close()
+	pollUrl := server.URL + "/compute/v1/projects/testing-project/global/operations/op-1"
+
+	prim := getTestMonitorPrimitive(t, pollUrl, MonitorParams{Timeout: 100 * time.Millisecond, Interval: 10 * time.Millisecond, Backoff: 1})
+	start := time.Now()
+	output := prim.Execute(nil)
+	var timeoutErr *AwaitTimeoutError
+	if !errors.As(output.Err, &timeoutErr) {
+		t.Fatalf("Test failed: expected await timeout, got %v", output.Err)
+	}
+	if timeoutErr.SelfLink != pollUrl || timeoutErr.Timeout != 100*time.Millisecond {
+		t.Fatalf("Test failed: unexpected timeout error %+v", timeoutErr)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Test failed: timeout of 100ms took %v", elapsed)
+	}
+}
+
+func TestAwaitCancel(t *testing.T) {
+	server := httptest.NewServer(&operationServer{})
+	defer server.Close()
+	pollUrl := server.URL + "/compute/v1/projects/testing-project/global/operations/op-1"
+
+	// with no timeout, only cancellation ends the wait
+	prim := getTestMonitorPrimitive(t, pollUrl, MonitorParams{Interval: 10 * time.Millisecond, Backoff: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	pc := dto.NewBasicPrimitiveContext(nil, &dto.AuthCtx{}, ioutil.Discard, ioutil.Discard, nil)
+	pc.SetContext(ctx)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	done := make(chan dto.ExecutorOutput, 1)
+	go func() {
+		done <- prim.Execute(pc)
+	}()
+	select {
+	case output := <-done:
+		if output.Err == nil || !strings.Contains(output.Err.Error(), "cancelled") || !strings.Contains(output.Err.Error(), pollUrl) {
+			t.Fatalf("Test failed: expected cancellation error naming '%s', got %v", pollUrl, output.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Test failed: await not stopped by cancellation")
+	}
+}
+
+func TestAwaitBackoffCap(t *testing.T) {
+	defer func(d time.Duration) { MaxPollInterval = d }(MaxPollInterval)
+	MaxPollInterval = 40 * time.Millisecond
+	server := &operationServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	pollUrl := httpServer.URL + "/compute/v1/projects/testing-project/global/operations/op-1"
+
+	// intervals of 10, 20, 40 then 40ms; uncapped, the last would be 640ms
+	prim := getTestMonitorPrimitive(t, pollUrl, MonitorParams{Timeout: 400 * time.Millisecond, Interval: 10 * time.Millisecond, Backoff: 2})
+	output := prim.Execute(nil)
+	var timeoutErr *AwaitTimeoutError
+	if !errors.As(output.Err, &timeoutErr) {
+		t.Fatalf("Test failed: expected await timeout, got %v", output.Err)
+	}
+	polls := server.getPolls()
+	if len(polls) < 6 {
+		t.Fatalf("Test failed: %d polls in 400ms, expected the interval capped at 40ms", len(polls))
+	}
+	// the last wait may be cut short by the timeout
+	for i := 3; i < len(polls)-1; i++ {
+		if gap := polls[i].Sub(polls[i-1]); gap < 35*time.Millisecond {
+			t.Fatalf("Test failed: poll %d followed the last by %v, less than the cap of 40ms", i, gap)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package asyncmonitor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"infraql/internal/iql/parserutil"
+)
+
+// The polling of an operation is governed by parameters of the AWAIT
+// directive, eg: /*+ AWAIT(timeout=600, interval=2, backoff=1.5) */, all
+// in seconds bar backoff, the factor by which the interval grows after
+// each poll, up to MaxPollInterval.  There is no timeout by default.
+const (
+	AwaitDirectiveStr  string  = "AWAIT"
+	awaitBackoffStr    string  = "backoff"
+	awaitIntervalStr   string  = "interval"
+	awaitTimeoutStr    string  = "timeout"
+	defaultPollBackoff float64 = 1
+)
+
+var MaxPollInterval time.Duration = time.Minute
+
+type MonitorParams struct {
+	Timeout  time.Duration
+	Interval time.Duration
+	Backoff  float64
+}
+
+func NewMonitorParams() MonitorParams {
+	return MonitorParams{
+		Interval: time.Duration(MonitorPollIntervalSeconds) * time.Second,
+		Backoff:  defaultPollBackoff,
+	}
+}
+
+func parseSeconds(key string, val string) (time.Duration, error) {
+	secs, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil || secs < 0 {
+		return 0, fmt.Errorf("%s directive parameter '%s' must be a number of seconds, not '%s'", AwaitDirectiveStr, key, val)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// ParseMonitorParams reads the parameters of an AWAIT directive,
+// defaulting those absent; a nil directive yields the defaults.
+func ParseMonitorParams(directive *parserutil.FunctionDirective) (MonitorParams, error) {
+	retVal := NewMonitorParams()
+	if directive == nil {
+		return retVal, nil
+	}
+	if len(directive.Args) > 0 {
+		return retVal, fmt.Errorf("%s directive accepts only named parameters, eg: %s(%s=600, %s=2, %s=1.5)", AwaitDirectiveStr, AwaitDirectiveStr, awaitTimeoutStr, awaitIntervalStr, awaitBackoffStr)
+	}
+	var keys []string
+	for k := range directive.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val := directive.Params[k]
+		var err error
+		switch k {
+		case awaitTimeoutStr:
+			retVal.Timeout, err = parseSeconds(k, val)
+		case awaitIntervalStr:
+			retVal.Interval, err = parseSeconds(k, val)
+			if err == nil && retVal.Interval <= 0 {
+				err = fmt.Errorf("%s directive parameter '%s' must be positive", AwaitDirectiveStr, k)
+			}
+		case awaitBackoffStr:
+			retVal.Backoff, err = strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil || retVal.Backoff < 1 {
+				err = fmt.Errorf("%s directive parameter '%s' must be a number no less than 1, not '%s'", AwaitDirectiveStr, k, val)
+			}
+		default:
+			err = fmt.Errorf("unknown %s directive parameter '%s'", AwaitDirectiveStr, k)
+		}
+		if err != nil {
+			return retVal, err
+		}
+	}
+	return retVal, nil
+}
+
+// getNextInterval grows the interval by the backoff factor, to at most
+// MaxPollInterval unless the interval was set longer to begin with.
+func (mp MonitorParams) getNextInterval(interval time.Duration) time.Duration {
+	next := time.Duration(float64(interval) * mp.Backoff)
+	limit := MaxPollInterval
+	if mp.Interval > limit {
+		limit = mp.Interval
+	}
+	if next > limit {
+		return limit
+	}
+	return next
+}
+
+// AwaitTimeoutError is returned when an operation does not complete within
+// the AWAIT timeout.  The operation is not cancelled, so may be checked
+// later at its selfLink.
+type AwaitTimeoutError struct {
+	Operation string
+	SelfLink  string
+	Timeout   time.Duration
+}
+
+func (e *AwaitTimeoutError) Error() string {
+	return fmt.Sprintf("%s did not complete within the timeout of %v, it may still be in progress, see: %s", e.Operation, e.Timeout, e.SelfLink)
+}
@@ -0,0 +1,53 @@
+package asyncmonitor_test
+
+import (
+	"testing"
+	"time"
+
+	. "infraql/internal/iql/asyncmonitor"
+	"infraql/internal/iql/parserutil"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func getAwaitDirective(t *testing.T, query string) *parserutil.FunctionDirective {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	directive, ok := parserutil.ExtractFunctionDirective(parserutil.GetStatementComments(stmt), AwaitDirectiveStr)
+	if !ok {
+		t.Fatalf("Test failed: AWAIT directive not found in '%s'", query)
+	}
+	return directive
+}
+
+func TestParseMonitorParams(t *testing.T) {
+	params, err := ParseMonitorParams(getAwaitDirective(t, `delete /*+ AWAIT(timeout=600, interval=2, backoff=1.5) */ from google.compute.disks where project = 'p' and zone = 'z' and disk = 'd'`))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if params.Timeout != 600*time.Second || params.Interval != 2*time.Second || params.Backoff != 1.5 {
+		t.Fatalf("Test failed: unexpected params %+v", params)
+	}
+
+	params, err = ParseMonitorParams(getAwaitDirective(t, `delete /*+ AWAIT */ from google.compute.disks where project = 'p' and zone = 'z' and disk = 'd'`))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if params != NewMonitorParams() {
+		t.Fatalf("Test failed: bare AWAIT params %+v, expected the defaults", params)
+	}
+
+	for _, query := range []string{
+		`delete /*+ AWAIT(interval=0) */ from google.compute.disks where disk = 'd'`,
+		`delete /*+ AWAIT(backoff=0.5) */ from google.compute.disks where disk = 'd'`,
+		`delete /*+ AWAIT(timeout=soon) */ from google.compute.disks where disk = 'd'`,
+		`delete /*+ AWAIT(deadline=60) */ from google.compute.disks where disk = 'd'`,
+		`delete /*+ AWAIT(60) */ from google.compute.disks where disk = 'd'`,
+	} {
+		if _, err := ParseMonitorParams(getAwaitDirective(t, query)); err == nil {
+			t.Fatalf("Test failed: invalid directive accepted in '%s'", query)
+		}
+	}
+}
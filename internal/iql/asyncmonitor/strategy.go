@@ -0,0 +1,186 @@
+package asyncmonitor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"infraql/internal/iql/metadata"
+	"infraql/internal/iql/taxonomy"
+)
+
+// Google APIs return long running operations of (at least) two shapes:
+// those of compute, sqladmin, container, etc, which carry a selfLink and
+// a status, and google.longrunning.Operation, of resource manager and
+// most newer APIs, which carry only a name and a done flag.  The shape is
+// detected from the method's response schema, unless a strategy has been
+// registered for the service.
+type OperationStrategy interface {
+	// Matches reports whether the response schema is an operation of this shape.
+	Matches(schema *metadata.Schema) bool
+	IsDone(body map[string]interface{}) bool
+	GetPollUrl(heirarchy *taxonomy.HeirarchyObjects, body map[string]interface{}) (string, error)
+}
+
+var (
+	selfLinkStrategy           OperationStrategy            = &SelfLinkOperationStrategy{}
+	longRunningStrategy        OperationStrategy            = &LongRunningOperationStrategy{}
+	operationStrategies        []OperationStrategy          = []OperationStrategy{selfLinkStrategy, longRunningStrategy}
+	serviceOperationStrategies map[string]OperationStrategy = map[string]OperationStrategy{
+		"compute": selfLinkStrategy,
+	}
+	versionPathRegex *regexp.Regexp = regexp.MustCompile(`^(v[0-9][a-z0-9]*)/`)
+)
+
+// RegisterOperationStrategy overrides schema detection for the service.
+func RegisterOperationStrategy(serviceName string, strategy OperationStrategy) {
+	serviceOperationStrategies[serviceName] = strategy
+}
+
+// GetOperationStrategy returns the strategy for monitoring the
+// method's response, or nil if the response is not an operation.
+func GetOperationStrategy(heirarchy *taxonomy.HeirarchyObjects) OperationStrategy {
+	if heirarchy == nil || heirarchy.Method == nil {
+		return nil
+	}
+	var schema *metadata.Schema
+	if heirarchy.Provider != nil {
+		schema, _ = heirarchy.GetObjectSchema()
+	}
+	// without a recognisable schema, the response type name is taken at its word
+	isNamedOperation := heirarchy.Method.ResponseType.Type == "Operation"
+	if strategy, ok := serviceOperationStrategies[heirarchy.HeirarchyIds.ServiceStr]; ok {
+		if isNamedOperation || (schema != nil && strategy.Matches(schema)) {
+			return strategy
+		}
+		return nil
+	}
+	if schema != nil {
+		for _, strategy := range operationStrategies {
+			if strategy.Matches(schema) {
+				return strategy
+			}
+		}
+	}
+	if isNamedOperation {
+		return selfLinkStrategy
+	}
+	return nil
+}
+
+func hasProperties(schema *metadata.Schema, names ...string) bool {
+	for _, name := range names {
+		if _, ok := schema.Properties[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type SelfLinkOperationStrategy struct{}
+
+func (s *SelfLinkOperationStrategy) Matches(schema *metadata.Schema) bool {
+	// resources too may have a selfLink and status, eg: compute instances
+	return hasProperties(schema, "selfLink", "status", "operationType")
+}
+
+func (s *SelfLinkOperationStrategy) IsDone(body map[string]interface{}) bool {
+	if endTime, ok := body["endTime"].(string); ok && endTime != "" {
+		return true
+	}
+	status, _ := body["status"].(string)
+	return strings.ToUpper(status) == "DONE"
+}
+
+func (s *SelfLinkOperationStrategy) GetPollUrl(heirarchy *taxonomy.HeirarchyObjects, body map[string]interface{}) (string, error) {
+	url, ok := body["selfLink"].(string)
+	if !ok || url == "" {
+		return "", fmt.Errorf("cannot execute monitor: no 'selfLink' property present")
+	}
+	return url, nil
+}
+
+// LongRunningOperationStrategy polls google.longrunning.Operation by name,
+// with the service's operations get method, eg: GET v3/{+name}.
+type LongRunningOperationStrategy struct{}
+
+func (s *LongRunningOperationStrategy) Matches(schema *metadata.Schema) bool {
+	return hasProperties(schema, "name", "done")
+}
+
+func (s *LongRunningOperationStrategy) IsDone(body map[string]interface{}) bool {
+	done, _ := body["done"].(bool)
+	return done
+}
+
+func (s *LongRunningOperationStrategy) GetPollUrl(heirarchy *taxonomy.HeirarchyObjects, body map[string]interface{}) (string, error) {
+	name, ok := body["name"].(string)
+	if !ok || name == "" {
+		return "", fmt.Errorf("cannot execute monitor: no 'name' property present")
+	}
+	if heirarchy == nil || heirarchy.Resource == nil {
+		return "", fmt.Errorf("cannot execute monitor: operation '%s' of unknown service", name)
+	}
+	if rsc, m := findOperationGetMethod(heirarchy); m != nil {
+		return joinUrl(rsc.BaseUrl, strings.Replace(m.Path, "{+name}", name, 1)), nil
+	}
+	// otherwise the name is taken to be relative to the method's api version
+	if matches := versionPathRegex.FindStringSubmatch(heirarchy.Method.Path); matches != nil {
+		return joinUrl(heirarchy.Resource.BaseUrl, matches[1]+"/"+name), nil
+	}
+	return joinUrl(heirarchy.Resource.BaseUrl, name), nil
+}
+
+// findOperationGetMethod returns the service's method for getting an
+// operation by name, preferring an operations resource at the top level.
+func findOperationGetMethod(heirarchy *taxonomy.HeirarchyObjects) (*metadata.Resource, *metadata.Method) {
+	if heirarchy.ServiceHdl == nil {
+		return nil, nil
+	}
+	var candidates []string
+	for k, rsc := range heirarchy.ServiceHdl.Resources {
+		m, ok := rsc.Methods["get"]
+		if !ok || m.ResponseType.Type != heirarchy.Method.ResponseType.Type || !strings.Contains(m.Path, "{+name}") {
+			continue
+		}
+		candidates = append(candidates, k)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(candidates[i]) != len(candidates[j]) {
+			return len(candidates[i]) < len(candidates[j])
+		}
+		return candidates[i] < candidates[j]
+	})
+	rsc := heirarchy.ServiceHdl.Resources[candidates[0]]
+	m := rsc.Methods["get"]
+	return &rsc, &m
+}
+
+func joinUrl(baseUrl string, path string) string {
+	return strings.TrimSuffix(baseUrl, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// fixedUrlOperationStrategy polls an operation already recorded,
+// whose shape is known only from the body.
+type fixedUrlOperationStrategy struct {
+	pollUrl string
+}
+
+func (s *fixedUrlOperationStrategy) Matches(schema *metadata.Schema) bool {
+	return false
+}
+
+func (s *fixedUrlOperationStrategy) IsDone(body map[string]interface{}) bool {
+	if _, ok := body["done"]; ok {
+		return longRunningStrategy.IsDone(body)
+	}
+	return selfLinkStrategy.IsDone(body)
+}
+
+func (s *fixedUrlOperationStrategy) GetPollUrl(heirarchy *taxonomy.HeirarchyObjects, body map[string]interface{}) (string, error) {
+	return s.pollUrl, nil
+}
@@ -0,0 +1,64 @@
+package asyncmonitor_test
+
+import (
+	"testing"
+
+	. "infraql/internal/iql/asyncmonitor"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/metadata"
+	"infraql/internal/iql/taxonomy"
+)
+
+func TestLongRunningOperationStrategy(t *testing.T) {
+	operationSchema := &metadata.Schema{
+		Properties: map[string]metadata.SchemaHandle{
+			"name":     {},
+			"done":     {},
+			"error":    {},
+			"metadata": {},
+		},
+	}
+	strategy := &LongRunningOperationStrategy{}
+	if !strategy.Matches(operationSchema) || (&SelfLinkOperationStrategy{}).Matches(operationSchema) {
+		t.Fatalf("Test failed: google.longrunning operation schema not detected")
+	}
+	createMethod := metadata.Method{Path: "v3/projects", ResponseType: metadata.SchemaType{Type: "Operation"}}
+	heirarchy := &taxonomy.HeirarchyObjects{
+		HeirarchyIds: *dto.NewHeirarchyIdentifiers("google", "cloudresourcemanager", "projects", "create"),
+		ServiceHdl: &metadata.ServiceHandle{
+			Resources: map[string]metadata.Resource{
+				"operations": {
+					BaseUrl: "https://cloudresourcemanager.googleapis.com/",
+					Methods: map[string]metadata.Method{
+						"get": {Path: "v3/{+name}", ResponseType: metadata.SchemaType{Type: "Operation"}},
+					},
+				},
+			},
+		},
+		Resource: &metadata.Resource{BaseUrl: "https://cloudresourcemanager.googleapis.com/"},
+		Method:   &createMethod,
+	}
+	if GetOperationStrategy(heirarchy) == nil {
+		t.Fatalf("Test failed: no strategy for operation response")
+	}
+	body := map[string]interface{}{"name": "operations/cp.123"}
+	if strategy.IsDone(body) {
+		t.Fatalf("Test failed: operation without done flag reported done")
+	}
+	url, err := strategy.GetPollUrl(heirarchy, body)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if url != "https://cloudresourcemanager.googleapis.com/v3/operations/cp.123" {
+		t.Fatalf("Test failed: unexpected poll url '%s'", url)
+	}
+	heirarchy.ServiceHdl = nil
+	url, _ = strategy.GetPollUrl(heirarchy, body)
+	if url != "https://cloudresourcemanager.googleapis.com/v3/operations/cp.123" {
+		t.Fatalf("Test failed: unexpected poll url '%s' inferred from method path", url)
+	}
+	body["done"] = true
+	if !strategy.IsDone(body) {
+		t.Fatalf("Test failed: done operation not reported done")
+	}
+}
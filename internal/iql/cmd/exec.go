@@ -17,6 +17,7 @@ package cmd
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 
@@ -26,6 +27,7 @@ import (
 	"infraql/internal/iql/entryutil"
 	"infraql/internal/iql/handler"
 	"infraql/internal/iql/iqlerror"
+	"infraql/internal/iql/operations"
 	"infraql/internal/iql/writer"
 )
 
@@ -44,7 +46,10 @@ infraql exec -i iqlscripts/listinstances.iql --keyfilepath /mnt/c/tmp/infraql-de
 
 infraql exec -i iqlscripts/create-disk.iql --keyfilepath /mnt/c/tmp/infraql-demo.json
 `,
-	Run: func(cmd *cobra.Command, args []string) {
+	// a failed statement is reported as it happens, so only the exit status remains
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 
 		var err error
 		var rdr io.Reader
@@ -65,7 +70,14 @@ infraql exec -i iqlscripts/create-disk.iql --keyfilepath /mnt/c/tmp/infraql-demo
 		handlerCtx, err := entryutil.BuildHandlerContext(runtimeCtx, rdr, queryCache, sqlEngine)
 		iqlerror.PrintErrorAndExitOneIfError(err)
 		iqlerror.PrintErrorAndExitOneIfNil(&handlerCtx, "Handler context error")
-		RunCommand(&handlerCtx, nil, nil)
+		if !runtimeCtx.DryRunFlag {
+			stopRefresher := operations.StartRefresher(&handlerCtx)
+			defer stopRefresher()
+		}
+		if failed := RunCommand(&handlerCtx, nil, nil); failed > 0 {
+			return fmt.Errorf("%d statement(s) failed", failed)
+		}
+		return nil
 	},
 }
 
@@ -80,7 +92,9 @@ func getOutputFile(filename string) (*os.File, error) {
 	}
 }
 
-func RunCommand(handlerCtx *handler.HandlerContext, outfile io.Writer, outErrFile io.Writer) {
+// RunCommand runs the statements of the handler context's
+// raw query, returning the number that failed.
+func RunCommand(handlerCtx *handler.HandlerContext, outfile io.Writer, outErrFile io.Writer) int {
 	if outfile == nil {
 		outfile, _ = getOutputFile(handlerCtx.RuntimeContext.OutfilePath)
 	}
@@ -91,7 +105,7 @@ func RunCommand(handlerCtx *handler.HandlerContext, outfile io.Writer, outErrFil
 	handlerCtx.OutErrFile = outErrFile
 	if handlerCtx.RuntimeContext.DryRunFlag {
 		driver.ProcessDryRun(handlerCtx)
-		return
+		return 0
 	}
-	driver.ProcessQuery(handlerCtx)
+	return driver.ProcessQuery(handlerCtx)
 }
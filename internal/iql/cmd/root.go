@@ -20,6 +20,7 @@ import (
 	"infraql/internal/iql/config"
 	"infraql/internal/iql/constants"
 	"infraql/internal/iql/dto"
+	"infraql/internal/iql/httpexec"
 	"infraql/internal/pkg/txncounter"
 	"os"
 	"path/filepath"
@@ -44,6 +45,13 @@ var (
 	BuildPlatform       string = ""
 )
 
+// The installed app OAuth client of interactive auth, injected at build
+// time; installed app secrets are not confidential.
+var (
+	BuildOAuthClientID     string = ""
+	BuildOAuthClientSecret string = ""
+)
+
 var SemVersion string = fmt.Sprintf("%s.%s.%s", BuildMajorVersion, BuildMinorVersion, BuildPatchVersion)
 
 var (
@@ -98,12 +106,32 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.DbEngine, dto.DbEngineKey, config.GetDefaultDbEngine(), fmt.Sprintf("DB engine id"))
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.DbFilePath, dto.DbFilePathKey, config.GetDefaultDbFilePath(), fmt.Sprintf("DB persistence filename"))
 	rootCmd.PersistentFlags().IntVar(&runtimeCtx.DbGenerationId, dto.DbGenerationIdKey, txncounter.GetNextGenerationId(), fmt.Sprintf("DB generation id"))
+	rootCmd.PersistentFlags().IntVar(&runtimeCtx.ConcurrencyLimit, dto.ConcurrencyLimitKey, constants.DefaultConcurrencyLimit, "max concurrent requests for statements that fan out over many rows, eg: INSERT ... SELECT")
 	rootCmd.PersistentFlags().IntVar(&runtimeCtx.HTTPMaxResults, dto.HTTPMaxResultsKey, -1, "max results per http request, any number <=0 results in no limitation")
 	rootCmd.PersistentFlags().IntVar(&runtimeCtx.HTTPProxyPort, dto.HTTPProxyPortKey, -1, "http proxy port, any number <=0 will result in the default port for a given scheme (eg: http -> 80)")
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.HTTPProxyHost, dto.HTTPProxyHostKey, "", "http proxy host, empty means no proxy")
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.HTTPProxyScheme, dto.HTTPProxySchemeKey, "http", "http proxy scheme, eg 'http'")
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.HTTPProxyPassword, dto.HTTPProxyPasswordKey, "", "http proxy password")
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.HTTPProxyUser, dto.HTTPProxyUserKey, "", "http proxy user")
+	rootCmd.PersistentFlags().IntVar(&runtimeCtx.HTTPBatchMaxParts, dto.HTTPBatchMaxPartsKey, httpexec.MaxBatchParts, fmt.Sprintf("max requests coalesced into a batch request, for statements that fan out over many rows, at most %d; any number <=1 disables batching", httpexec.MaxBatchParts))
+	rootCmd.PersistentFlags().IntVar(&runtimeCtx.OperationsRefresh, dto.OperationsRefreshKey, 30, "interval in seconds at which the status of unfinished operations is refreshed in the background, any number <=0 disables refreshing")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.HTTPRecordDir, dto.HTTPRecordDirKey, "", "directory in which to record every http request and response, with credentials redacted")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.HTTPReplayDir, dto.HTTPReplayDirKey, "", "directory of recorded http responses to serve in place of the network; implies --offline")
+	rootCmd.PersistentFlags().IntVar(&runtimeCtx.HTTPRetryMaxAttempts, dto.HTTPRetryMaxAttemptsKey, 4, "max attempts at an http request failing transiently (eg: 429, 503), any number <=1 results in no retries")
+	rootCmd.PersistentFlags().IntVar(&runtimeCtx.HTTPRetryBackoff, dto.HTTPRetryBackoffKey, 500, "initial http retry backoff in milliseconds, doubled per attempt and jittered")
+	rootCmd.PersistentFlags().IntVar(&runtimeCtx.HTTPRetryMaxBackoff, dto.HTTPRetryMaxBackoffKey, 30000, "max http retry backoff in milliseconds, also capping any Retry-After")
+	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.HTTPRetryMutations, dto.HTTPRetryMutationsKey, false, "also retry non idempotent http requests (eg: POST), which may repeat a mutation")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.AuthType, dto.AuthTypeKey, "", fmt.Sprintf("Auth type, one of {'%s', '%s', '%s'}; when empty, inferred from the presence of a keyfilepath", dto.AuthInteractiveStr, dto.AuthServiceAccountStr, dto.AuthADCStr))
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.GCEMetadataURL, dto.GCEMetadataURLKey, config.GetDefaultGCEMetadataURL(), "GCE metadata server url, consulted last for application default credentials")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.Impersonate, dto.ImpersonateKey, "", "Service account to impersonate, using the configured credentials to obtain its tokens")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.IAMCredentialsURL, dto.IAMCredentialsURLKey, constants.GoogleIAMCredentialsURL, "IAM credentials endpoint, used for impersonation")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.OAuthClientID, dto.OAuthClientIDKey, BuildOAuthClientID, "OAuth client id for interactive auth, defaulting to the one built into infraql")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.OAuthClientSecret, dto.OAuthClientSecretKey, BuildOAuthClientSecret, "OAuth client secret for interactive auth; installed app secrets are not confidential")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.OAuthFlow, dto.OAuthFlowKey, dto.OAuthFlowAuthCodeStr, fmt.Sprintf("OAuth flow for interactive auth, must be one of {'%s', '%s', '%s'}; use '%s' where no browser is available, or '%s' to delegate to an installed Google Cloud SDK", dto.OAuthFlowAuthCodeStr, dto.OAuthFlowDeviceStr, dto.OAuthFlowGcloudStr, dto.OAuthFlowDeviceStr, dto.OAuthFlowGcloudStr))
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.OAuthAuthURL, dto.OAuthAuthURLKey, constants.GoogleOAuthAuthURL, "OAuth authorization endpoint")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.OAuthTokenURL, dto.OAuthTokenURLKey, constants.GoogleOAuthTokenURL, "OAuth token endpoint")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.OAuthDeviceAuthURL, dto.OAuthDeviceAuthURLKey, constants.GoogleOAuthDeviceAuthURL, "OAuth device authorization endpoint")
+	rootCmd.PersistentFlags().StringVar(&runtimeCtx.OAuthRevokeURL, dto.OAuthRevokeURLKey, constants.GoogleOAuthRevokeURL, "OAuth token revocation endpoint")
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.DbInitFilePath, dto.DbInitFilePathKey, config.GetDefaultDbInitFilePath(), fmt.Sprintf("DB init file path"))
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.ProviderStr, dto.ProviderStrKey, config.GetGoogleProviderString(), fmt.Sprintf(`InfraQL provider`))
 	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.WorkOffline, dto.WorkOfflineKey, false, "Work offline, using cached data")
@@ -111,14 +139,17 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.DryRunFlag, dto.DryRunFlagKey, false, "dryrun flag; preprocessor only will run and output returned")
 	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.Reinit, dto.ReinitKey, false, "reinit; will delete db file at startup and force regeneration of all dependencies")
 	rootCmd.PersistentFlags().BoolVarP(&runtimeCtx.CSVHeadersDisable, dto.CSVHeadersDisableKey, "H", false, "Disable CSV headers flag")
-	rootCmd.PersistentFlags().StringVarP(&runtimeCtx.OutputFormat, dto.OutputFormatKey, "o", "table", "Output format, must be (json | table | csv)")
+	rootCmd.PersistentFlags().StringVarP(&runtimeCtx.OutputFormat, dto.OutputFormatKey, "o", "table", "Output format, must be (json | ndjson | yaml | table | markdown | csv | text | pptext | parquet | sqlite); sqlite requires an outfile")
+	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.OutputEnvelope, dto.OutputEnvelopeKey, false, "write the results of all statements as one document, each with its statement, status, row count, duration, messages and error; json, ndjson and yaml output only")
 	rootCmd.PersistentFlags().StringVarP(&runtimeCtx.OutfilePath, dto.OutfilePathKey, "f", "stdout", "Output file into which results are written")
 	rootCmd.PersistentFlags().StringVarP(&runtimeCtx.InfilePath, dto.InfilePathKey, "i", "stdin", "Input file from which queries are read")
 	rootCmd.PersistentFlags().StringVarP(&runtimeCtx.TemplateCtxFilePath, dto.TemplateCtxFilePathKey, "q", "", "Context file for templating")
 	rootCmd.PersistentFlags().IntVar(&runtimeCtx.QueryCacheSize, dto.QueryCacheSizeKey, constants.DefaultQueryCacheSize, "Size in number of entries of LRU cache for query plans")
+	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.ReadOnlyScopes, dto.ReadOnlyScopesKey, false, "request read only OAuth scopes for SELECT statements, where the API offers them")
 	rootCmd.PersistentFlags().StringVarP(&runtimeCtx.Delimiter, dto.DelimiterKey, "d", ",", "Delimiter for csv output;  single character only, ignored for all non-csv output")
 	rootCmd.PersistentFlags().IntVar(&runtimeCtx.CacheKeyCount, dto.CacheKeyCountKey, 100, "Cache initial key count")
 	rootCmd.PersistentFlags().IntVar(&runtimeCtx.CacheTTL, dto.CacheTTLKey, 3600, "TTL for cached metadata documents, in seconds")
+	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.Timing, dto.TimingKey, false, "report the time taken by each statement, with its http calls, pages fetched, bytes received, rows inserted and retries, to stderr")
 	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.TestWithoutApiCalls, dto.TestWithoutApiCallsKey, false, "Flag to omit api calls for testing")
 	rootCmd.PersistentFlags().BoolVar(&runtimeCtx.UseNonPreferredAPIs, dto.UseNonPreferredAPIsKEy, false, "Flag to enable non-preferred APIs")
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.LogLevelStr, dto.LogLevelStrKey, config.GetDefaultLogLevelString(), fmt.Sprintf(`Log level`))
@@ -137,6 +168,7 @@ func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.AddCommand(execCmd)
 	rootCmd.AddCommand(shellCmd)
+	rootCmd.AddCommand(watchCmd)
 	// rootCmd.AddCommand(srvCmd)
 
 }
@@ -158,6 +190,16 @@ func mergeConfigFromFile(runtimeCtx *dto.RuntimeCtx, flagSet pflag.FlagSet) {
 				runtimeCtx.Set(k, v)
 			}
 		}
+		authProfiles, err := dto.ParseAuthProfiles(propertiesMap)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runtimeCtx.AuthProfiles = authProfiles
+		rateLimits, err := dto.ParseRateLimits(propertiesMap)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runtimeCtx.RateLimits = rateLimits
 	}
 }
 
@@ -167,6 +209,12 @@ func initConfig() {
 	mergeConfigFromFile(&runtimeCtx, *rootCmd.PersistentFlags())
 
 	setLogLevel()
+	if err := httpexec.ConfigureRecordReplay(runtimeCtx.HTTPRecordDir, runtimeCtx.HTTPReplayDir); err != nil {
+		log.Fatal(err)
+	}
+	if httpexec.IsReplaying() {
+		runtimeCtx.WorkOffline = true
+	}
 	config.CreateDirIfNotExists(runtimeCtx.ProviderRootPath, os.FileMode(runtimeCtx.ProviderRootPathMode))
 	config.CreateDirIfNotExists(filepath.Join(runtimeCtx.ProviderRootPath, runtimeCtx.ProviderStr), os.FileMode(runtimeCtx.ProviderRootPathMode))
 	config.CreateDirIfNotExists(config.GetReadlineDirPath(runtimeCtx), os.FileMode(runtimeCtx.ProviderRootPathMode))
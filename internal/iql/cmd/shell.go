@@ -23,6 +23,7 @@ import (
 	"infraql/internal/iql/entryutil"
 	"infraql/internal/iql/handler"
 	"infraql/internal/iql/iqlerror"
+	"infraql/internal/iql/operations"
 	"infraql/internal/iql/provider"
 	"infraql/internal/iql/writer"
 	"io"
@@ -44,6 +45,8 @@ Welcome to the interactive shell for running InfraQL commands.
 ---`
 
 	// Auth messages
+	adcSuccessMsgTmpl string = `Authenticated to google as %s using application default credentials from source '%s', for more information see https://docs.infraql.io/language-spec/auth`
+
 	interactiveSuccessMsgTmpl string = `Authenticated interactively to google as %s, to change the authenticated user, use AUTH REVOKE followed by AUTH LOGIN, see https://docs.infraql.io/language-spec/auth`
 
 	notAuthenticatedMsg string = `Not authenticated, to authenticate to a provider use the AUTH LOGIN command, see https://docs.infraql.io/language-spec/auth`
@@ -68,6 +71,8 @@ func getShellPRompt(authCtx *dto.AuthCtx, cd *color.ColorDriver) string {
 			return cd.ShellColorPrint("InfraQL* >>")
 		case dto.AuthServiceAccountStr:
 			return cd.ShellColorPrint("InfraQL**>>")
+		case dto.AuthADCStr:
+			return cd.ShellColorPrint(fmt.Sprintf("InfraQL(%s)>>", authCtx.Source))
 		}
 	}
 	return cd.ShellColorPrint("InfraQL  >>")
@@ -81,6 +86,8 @@ func getIntroAuthMsg(authCtx *dto.AuthCtx, provider provider.IProvider) string {
 				return fmt.Sprintf(interactiveSuccessMsgTmpl, authCtx.ID)
 			case dto.AuthServiceAccountStr:
 				return fmt.Sprintf(saSuccessMsgTmpl, authCtx.KeyFilePath)
+			case dto.AuthADCStr:
+				return fmt.Sprintf(adcSuccessMsgTmpl, authCtx.ID, authCtx.Source)
 			}
 		} else if err := provider.CheckServiceAccountFile(authCtx.KeyFilePath); authCtx.KeyFilePath != "" && err != nil {
 			log.Debugln(fmt.Sprintf("authCtx.KeyFilePath = %v", authCtx.KeyFilePath))
@@ -125,6 +132,8 @@ var shellCmd = &cobra.Command{
 		} else {
 			fmt.Fprintln(outErrFile, fmt.Sprintf("Error setting up API for provider '%s'", handlerCtx.RuntimeContext.ProviderStr))
 		}
+		stopRefresher := operations.StartRefresher(&handlerCtx)
+		defer stopRefresher()
 
 		var readlineCfg *readline.Config
 
@@ -180,6 +189,13 @@ var shellCmd = &cobra.Command{
 				usage(outErrFile)
 			case line == "clear":
 				readline.ClearScreen(l.Stdout())
+			case line == `\timing`:
+				handlerCtx.RuntimeContext.Timing = !handlerCtx.RuntimeContext.Timing
+				if handlerCtx.RuntimeContext.Timing {
+					fmt.Fprintln(outErrFile, "Timing is on.")
+				} else {
+					fmt.Fprintln(outErrFile, "Timing is off.")
+				}
 			case line == "exit" || line == `\q` || line == "quit":
 				goto exit
 			case line == "":
@@ -0,0 +1,109 @@
+/*
+Copyright © 2019 InfraQL info@infraql.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"infraql/internal/iql/entryutil"
+	"infraql/internal/iql/iqlerror"
+	"infraql/internal/iql/watch"
+	"infraql/internal/iql/writer"
+)
+
+var (
+	watchInterval   time.Duration
+	watchKeyColumns []string
+	watchMaxPolls   int
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Periodically re-run an InfraQL query and print the rows that changed",
+	Long: `Periodically re-run a single InfraQL query, printing only the rows
+inserted, updated or deleted since the previous poll.  The first poll reports
+every row as inserted.  With --output json, changes are streamed as one JSON
+object per line. For example:
+
+infraql watch --interval 60s \
+"select id, name, status from compute.instances where project = 'infraql-demo' and zone = 'australia-southeast1-a'" \
+--keyfilepath /mnt/c/tmp/infraql-demo.json --output json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		var err error
+		var rdr io.Reader
+
+		switch runtimeCtx.InfilePath {
+		case "stdin":
+			if len(args) == 0 || args[0] == "" {
+				cmd.Help()
+				os.Exit(0)
+			}
+			rdr = bytes.NewReader([]byte(args[0]))
+		default:
+			rdr, err = os.Open(runtimeCtx.InfilePath)
+			iqlerror.PrintErrorAndExitOneIfError(err)
+		}
+		if watchInterval <= 0 {
+			iqlerror.PrintErrorAndExitOneIfError(fmt.Errorf("watch interval must be positive"))
+		}
+		sqlEngine, err := entryutil.BuildSQLEngine(runtimeCtx)
+		iqlerror.PrintErrorAndExitOneIfError(err)
+		handlerCtx, err := entryutil.BuildHandlerContext(runtimeCtx, rdr, queryCache, sqlEngine)
+		iqlerror.PrintErrorAndExitOneIfError(err)
+		iqlerror.PrintErrorAndExitOneIfNil(&handlerCtx, "Handler context error")
+
+		var queries []string
+		for _, s := range strings.Split(handlerCtx.RawQuery, ";") {
+			if strings.TrimSpace(s) != "" {
+				queries = append(queries, s)
+			}
+		}
+		if len(queries) != 1 {
+			iqlerror.PrintErrorAndExitOneIfError(fmt.Errorf("watch requires exactly one query, got %d", len(queries)))
+		}
+
+		handlerCtx.Outfile, err = getOutputFile(handlerCtx.RuntimeContext.OutfilePath)
+		iqlerror.PrintErrorAndExitOneIfError(err)
+		handlerCtx.OutErrFile, _ = getOutputFile(writer.StdErrStr)
+		handlerCtx.TxnCounterMgr, err = entryutil.GetTxnCounterManager(handlerCtx)
+		iqlerror.PrintErrorAndExitOneIfError(err)
+
+		watcher := watch.NewWatcher(&handlerCtx, queries[0], watchInterval, watchKeyColumns)
+		watcher.MaxPolls = watchMaxPolls
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		iqlerror.PrintErrorAndExitOneIfError(watcher.Run(ctx))
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", watch.DefaultInterval, "Interval between polls, eg: 30s, 5m")
+	watchCmd.Flags().StringSliceVar(&watchKeyColumns, "key", nil, "Columns identifying a row across polls; defaults to the first of {id, selfLink, name} present")
+	watchCmd.Flags().IntVar(&watchMaxPolls, "count", 0, "Number of polls before exiting, any number <=0 polls until interrupted")
+}
@@ -31,6 +31,10 @@ const readlineTmpFile = "readline.tmp"
 
 const defaultDbEngine = "sqlite3"
 
+const defaultGCEMetadataURL = "http://metadata.google.internal"
+
+const gceMetadataHostEnvVar = "GCE_METADATA_HOST"
+
 func GetGoogleProviderString() string {
 	return googleProvider
 }
@@ -94,6 +98,15 @@ func GetDefaultDbInitFilePath() string {
 	return ""
 }
 
+// GetDefaultGCEMetadataURL honours the GCE_METADATA_HOST
+// override understood by the Google client libraries.
+func GetDefaultGCEMetadataURL() string {
+	if host := os.Getenv(gceMetadataHostEnvVar); host != "" {
+		return "http://" + host
+	}
+	return defaultGCEMetadataURL
+}
+
 func GetDefaultProviderCacheDirFileMode() uint32 {
 	if runtime.GOOS == "windows" {
 		return defaultWindowsConfigCacheDirFileMode
@@ -5,9 +5,18 @@ const (
 	GoogleV1String                     string = "v1"
 	GoogleV1OperationURLPropertyString string = "selfLink"
 	GoogleV1ProviderCacheName          string = "google_provider_v_0_3_7"
+	GoogleIAMCredentialsURL            string = "https://iamcredentials.googleapis.com"
+	GoogleCloudPlatformScope           string = "https://www.googleapis.com/auth/cloud-platform"
+	GoogleCloudPlatformReadOnlyScope   string = "https://www.googleapis.com/auth/cloud-platform.read-only"
+	GoogleOAuthAuthURL                 string = "https://accounts.google.com/o/oauth2/auth"
+	GoogleOAuthDeviceAuthURL           string = "https://oauth2.googleapis.com/device/code"
+	GoogleOAuthRevokeURL               string = "https://oauth2.googleapis.com/revoke"
+	GoogleOAuthTokenURL                string = "https://oauth2.googleapis.com/token"
+	OAuthTokenFileName                 string = "oauth_token.json"
 	InfraqlKeyTmplStr                  string = "__KEY_TEMPLATE__"
 	InfraqlPathKey                     string = "name"
 	ServiceAccountRevokeErrStr         string = `[INFO] Only interactive login credentials can be revoked, to authenticate with a different service account change the keyfilepath in the .iqlrc file or reauthenticate with a different service account using the AUTH command.`
+	ADCRevokeErrStr                    string = `[INFO] Application default credentials are managed outside of infraql and cannot be revoked here.`
 	ServiceAccountPathErrStr           string = `[ERROR] Keyfilepath not supplied or key file does not exist.`
 	OAuthInteractiveAuthErrStr         string = `[INFO] Interactive credentials must be revoked before logging in with a different user, use the AUTH REVOKE command before attempting to authenticate again.`
 	NotAuthenticatedShowStr            string = `[INFO] Not authenticated, use the AUTH command to authenticate to a provider.`
@@ -16,6 +25,11 @@ const (
 	CSVStr                             string = "csv"
 	TextStr                            string = "text"
 	PrettyTextStr                      string = "pptext"
+	YAMLStr                            string = "yaml"
+	NDJSONStr                          string = "ndjson"
+	MarkdownStr                        string = "markdown"
+	ParquetStr                         string = "parquet"
+	SQLiteStr                          string = "sqlite"
 	DefaulHttpBodyFormat               string = JsonStr
 	RequestBodyKeyPrefix               string = "data"
 	RequestBodyKeyDelimiter            string = "__"
@@ -23,4 +37,5 @@ const (
 	DefaultPrettyPrintBaseIndent       int    = 2
 	DefaultPrettyPrintIndent           int    = 2
 	DefaultQueryCacheSize              int    = 10000
+	DefaultConcurrencyLimit            int    = 4
 )
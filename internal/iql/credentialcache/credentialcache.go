@@ -0,0 +1,140 @@
+package credentialcache
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"infraql/internal/iql/dto"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultRefreshMargin is how long before expiry a token is replaced,
+	// so that requests issued late in a long running statement, eg: the
+	// polls of an AWAIT, never carry a token that lapses in flight.
+	DefaultRefreshMargin time.Duration = 5 * time.Minute
+)
+
+// TokenSourceFunc adapts a function to oauth2.TokenSource.
+type TokenSourceFunc func() (*oauth2.Token, error)
+
+func (f TokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
+
+type refreshingTokenSource struct {
+	mutex  sync.Mutex
+	mint   oauth2.TokenSource
+	token  *oauth2.Token
+	margin time.Duration
+}
+
+// NewRefreshingTokenSource returns a source that holds on to a token
+// until it is within margin of expiry and only then asks mint, which
+// must obtain a new token on every call, for its replacement.  The
+// initial token, if any, is used until then.  Tokens without an expiry
+// are held indefinitely.
+func NewRefreshingTokenSource(mint oauth2.TokenSource, initial *oauth2.Token, margin time.Duration) oauth2.TokenSource {
+	return &refreshingTokenSource{
+		mint:   mint,
+		token:  initial,
+		margin: margin,
+	}
+}
+
+func (rts *refreshingTokenSource) isFresh() bool {
+	if rts.token == nil || rts.token.AccessToken == "" {
+		return false
+	}
+	return rts.token.Expiry.IsZero() || time.Now().Add(rts.margin).Before(rts.token.Expiry)
+}
+
+func (rts *refreshingTokenSource) Token() (*oauth2.Token, error) {
+	rts.mutex.Lock()
+	defer rts.mutex.Unlock()
+	if rts.isFresh() {
+		return rts.token, nil
+	}
+	tok, err := rts.mint.Token()
+	if err != nil {
+		return nil, err
+	}
+	rts.token = tok
+	return tok, nil
+}
+
+// key captures the parts of an auth context that determine its
+// credentials; a cached client is discarded once any of them change.
+type key struct {
+	authType    string
+	keyFilePath string
+	impersonate string
+	scopes      string
+}
+
+func getKey(authCtx *dto.AuthCtx, authType string) key {
+	return key{
+		authType:    authType,
+		keyFilePath: authCtx.KeyFilePath,
+		impersonate: authCtx.Impersonate,
+		scopes:      strings.Join(authCtx.Scopes, " "),
+	}
+}
+
+// entryKey allows an auth context a client per requested scope set.
+type entryKey struct {
+	authCtx *dto.AuthCtx
+	scopes  string
+}
+
+type entry struct {
+	key    key
+	client *http.Client
+}
+
+// Cache holds authenticated clients per auth context, so that
+// credentials are resolved once rather than per request.
+type Cache struct {
+	mutex   sync.Mutex
+	entries map[entryKey]entry
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[entryKey]entry),
+	}
+}
+
+// GetOrCreate returns the cached client for the auth context and
+// scopes, calling create for a new one where there is none, where the
+// auth context has since changed, or where replace is set.  Creation
+// is serialised, so that concurrent requests do not each authenticate.
+func (c *Cache) GetOrCreate(authCtx *dto.AuthCtx, authType string, scopes []string, replace bool, create func() (*http.Client, error)) (*http.Client, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ek := entryKey{authCtx: authCtx, scopes: strings.Join(scopes, " ")}
+	k := getKey(authCtx, authType)
+	if e, ok := c.entries[ek]; ok && !replace && e.key == k {
+		return e.client, nil
+	}
+	client, err := create()
+	if err != nil {
+		return nil, err
+	}
+	c.entries[ek] = entry{key: k, client: client}
+	return client, nil
+}
+
+// Delete discards every client held for the auth context.
+func (c *Cache) Delete(authCtx *dto.AuthCtx) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for ek := range c.entries {
+		if ek.authCtx == authCtx {
+			delete(c.entries, ek)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package credentialcache_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	. "infraql/internal/iql/credentialcache"
+	"infraql/internal/iql/dto"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshingTokenSource(t *testing.T) {
+	mints := 0
+	mint := TokenSourceFunc(func() (*oauth2.Token, error) {
+		mints++
+		return &oauth2.Token{AccessToken: "minted", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	// inside the margin, so replaced at once
+	initial := &oauth2.Token{AccessToken: "initial", Expiry: time.Now().Add(time.Minute)}
+	ts := NewRefreshingTokenSource(mint, initial, DefaultRefreshMargin)
+	for i := 0; i < 3; i++ {
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		if tok.AccessToken != "minted" {
+			t.Fatalf("Test failed: access token = '%s', expected 'minted'", tok.AccessToken)
+		}
+	}
+	if mints != 1 {
+		t.Fatalf("Test failed: minted %d tokens, expected 1", mints)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache()
+	authCtx := dto.GetAuthCtx(nil, "/path/to/key.json", dto.AuthServiceAccountStr)
+	creates := 0
+	create := func() (*http.Client, error) {
+		creates++
+		return &http.Client{}, nil
+	}
+	first, _ := c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, nil, false, create)
+	second, _ := c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, nil, false, create)
+	if first != second || creates != 1 {
+		t.Fatalf("Test failed: client not reused, %d created", creates)
+	}
+	authCtx.KeyFilePath = "/path/to/other.json"
+	c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, nil, false, create)
+	c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, nil, true, create)
+	scopes := []string{"https://www.googleapis.com/auth/compute.readonly"}
+	c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, scopes, false, create)
+	c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, scopes, false, create)
+	c.Delete(authCtx)
+	c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, nil, false, create)
+	c.GetOrCreate(authCtx, dto.AuthServiceAccountStr, scopes, false, create)
+	if creates != 6 {
+		t.Fatalf("Test failed: %d clients created, expected 6", creates)
+	}
+}
@@ -1,15 +1,33 @@
 package driver
 
 import (
+	"context"
+	"fmt"
+	"infraql/internal/iql/asyncmonitor"
+	"infraql/internal/iql/constants"
 	"infraql/internal/iql/dto"
 	"infraql/internal/iql/entryutil"
 	"infraql/internal/iql/handler"
+	"infraql/internal/iql/operations"
+	"infraql/internal/iql/output"
+	"infraql/internal/iql/parserutil"
 	"infraql/internal/iql/querysubmit"
 	"infraql/internal/iql/responsehandler"
 	"infraql/internal/iql/util"
+	"infraql/internal/iql/watch"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+const (
+	OutputDirectiveName string = "OUTPUT"
 )
 
 func ProcessDryRun(handlerCtx *handler.HandlerContext) {
@@ -28,20 +46,200 @@ func throwErr(err error, handlerCtx *handler.HandlerContext) {
 	responsehandler.HandleResponse(handlerCtx, response)
 }
 
-func ProcessQuery(handlerCtx *handler.HandlerContext) {
+// ProcessQuery runs each statement of the raw query in turn,
+// returning the number that failed.  With the output envelope, results
+// are collected and written as one document once all have run.
+func ProcessQuery(handlerCtx *handler.HandlerContext) int {
 	cmdString := handlerCtx.RawQuery
+	var envelope *output.Envelope
+	if handlerCtx.RuntimeContext.OutputEnvelope {
+		if !output.IsEnvelopeFormat(handlerCtx.RuntimeContext.OutputFormat) {
+			throwErr(fmt.Errorf("output envelope not supported for output format = '%s', must be (json | ndjson | yaml)", handlerCtx.RuntimeContext.OutputFormat), handlerCtx)
+			return 1
+		}
+		envelope = output.NewEnvelope()
+	}
 	tc, err := entryutil.GetTxnCounterManager(*handlerCtx)
 	if err != nil {
 		throwErr(err, handlerCtx)
-		return
+		return 1
 	}
 	handlerCtx.TxnCounterMgr = tc
+	failed := 0
 	for _, s := range strings.Split(cmdString, ";") {
 		if s == "" {
 			continue
 		}
 		handlerCtx.Query = s
-		response := querysubmit.SubmitQuery(handlerCtx)
-		responsehandler.HandleResponse(handlerCtx, response)
+		if !processStatement(handlerCtx, envelope) {
+			failed++
+		}
+	}
+	if envelope != nil {
+		if err := envelope.Write(handlerCtx.Outfile, handlerCtx.RuntimeContext.OutputFormat); err != nil {
+			throwErr(err, handlerCtx)
+		}
+	}
+	return failed
+}
+
+// processStatement runs the current statement, with its output redirected
+// for the statement alone where it carries an OUTPUT directive, returning
+// whether it succeeded.  Background refreshes of operations wait until it is
+// done, or, for a watch, which runs until interrupted, until each poll is done.
+func processStatement(handlerCtx *handler.HandlerContext, envelope *output.Envelope) bool {
+	start := time.Now()
+	outfilePath, restoreOutput, err := redirectOutput(handlerCtx, handlerCtx.Query)
+	if err != nil {
+		handleResponse(handlerCtx, envelope, "", dto.NewExecutorOutput(nil, nil, nil, err), time.Since(start))
+		return false
+	}
+	defer restoreOutput()
+	// progress is kept out of documents, be they envelope or file
+	if envelope != nil || outfilePath != "" {
+		handlerCtx.StatusFile = handlerCtx.OutErrFile
+		defer func() { handlerCtx.StatusFile = nil }()
+	}
+	if watcher, err := getWatcherForQuery(handlerCtx, handlerCtx.Query); watcher != nil || err != nil {
+		if err == nil && envelope != nil {
+			err = fmt.Errorf("WATCH is not supported with the output envelope")
+		} else if err == nil {
+			err = runWatcher(watcher)
+		}
+		if err != nil {
+			handleResponse(handlerCtx, envelope, outfilePath, dto.NewExecutorOutput(nil, nil, nil, err), time.Since(start))
+			return false
+		}
+		return true
+	}
+	defer operations.Exclusive()()
+	response := submitQuery(handlerCtx)
+	err = handleResponse(handlerCtx, envelope, outfilePath, response, time.Since(start))
+	return response.Err == nil && err == nil
+}
+
+// handleResponse writes the response, or adds it to the envelope.  The
+// results of a statement with redirected output are written to its file
+// nonetheless, the envelope recording only where.
+func handleResponse(handlerCtx *handler.HandlerContext, envelope *output.Envelope, outfilePath string, response dto.ExecutorOutput, duration time.Duration) error {
+	var err error
+	if envelope == nil || outfilePath != "" {
+		err = responsehandler.HandleResponse(handlerCtx, response)
+	}
+	if envelope != nil {
+		entry := output.NewEnvelopeEntry(handlerCtx.Query, response, duration)
+		if outfilePath != "" {
+			entry.Outfile = outfilePath
+			entry.Rows = []map[string]interface{}{}
+		}
+		envelope.Add(entry)
+	}
+	return err
+}
+
+// redirectOutput applies the OUTPUT directive of the statement, if any, eg:
+// /*+ OUTPUT(file='instances.csv', format='csv', append=true) */, returning
+// the file written and a func restoring the prior output.  The file path
+// may be templated with preprocessor variables, as is the whole statement.
+// A sqlite file is never truncated, each statement adding its own table.
+func redirectOutput(handlerCtx *handler.HandlerContext, query string) (string, func(), error) {
+	if !strings.Contains(strings.ToUpper(query), OutputDirectiveName) {
+		return "", func() {}, nil
+	}
+	directive, ok := parserutil.ExtractQueryFunctionDirective(query, OutputDirectiveName)
+	if !ok {
+		return "", func() {}, nil
 	}
+	outfilePath, ok := directive.GetParam("file")
+	if !ok {
+		outfilePath, _ = directive.GetArg(0)
+	}
+	if outfilePath == "" {
+		return "", nil, fmt.Errorf("%s directive requires a file", OutputDirectiveName)
+	}
+	outputFormat := handlerCtx.RuntimeContext.OutputFormat
+	if v, ok := directive.GetParam("format"); ok && v != "" {
+		outputFormat = strings.ToLower(v)
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if outputFormat == constants.SQLiteStr {
+		// the sqlite writer opens the file as a db, adding a table to any there
+		flag = os.O_CREATE | os.O_WRONLY
+	}
+	if v, ok := directive.GetParam("append"); ok {
+		isAppend, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s directive append = '%s' is not a boolean", OutputDirectiveName, v)
+		}
+		if isAppend {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+	}
+	outfile, err := os.OpenFile(outfilePath, flag, 0666)
+	if err != nil {
+		return "", nil, err
+	}
+	prevOutfile := handlerCtx.Outfile
+	prevOutfilePath := handlerCtx.RuntimeContext.OutfilePath
+	prevOutputFormat := handlerCtx.RuntimeContext.OutputFormat
+	handlerCtx.Outfile = outfile
+	handlerCtx.RuntimeContext.OutfilePath = outfilePath
+	handlerCtx.RuntimeContext.OutputFormat = outputFormat
+	return outfilePath, func() {
+		outfile.Close()
+		handlerCtx.Outfile = prevOutfile
+		handlerCtx.RuntimeContext.OutfilePath = prevOutfilePath
+		handlerCtx.RuntimeContext.OutputFormat = prevOutputFormat
+	}, nil
+}
+
+// submitQuery runs the statement; one that awaits operations does so in a
+// context cancelled by an interrupt, eg: Ctrl-C in the shell, so that the
+// wait is abandoned rather than the process.
+func submitQuery(handlerCtx *handler.HandlerContext) dto.ExecutorOutput {
+	if !strings.Contains(strings.ToUpper(handlerCtx.Query), asyncmonitor.AwaitDirectiveStr) {
+		return querysubmit.SubmitQuery(handlerCtx)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	handlerCtx.Context = ctx
+	defer func() { handlerCtx.Context = nil }()
+	return querysubmit.SubmitQuery(handlerCtx)
+}
+
+// getWatcherForQuery returns a watcher iff the statement carries a WATCH directive.
+func getWatcherForQuery(handlerCtx *handler.HandlerContext, query string) (*watch.Watcher, error) {
+	if !strings.Contains(strings.ToUpper(query), watch.DirectiveName) {
+		return nil, nil
+	}
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, nil
+	}
+	if _, ok := stmt.(*sqlparser.Select); !ok {
+		return nil, nil
+	}
+	directive, ok := parserutil.ExtractFunctionDirective(parserutil.GetStatementComments(stmt), watch.DirectiveName)
+	if !ok {
+		return nil, nil
+	}
+	intervalStr, _ := directive.GetArg(0)
+	if v, ok := directive.GetParam("interval"); ok {
+		intervalStr = v
+	}
+	interval, err := watch.ParseInterval(intervalStr)
+	if err != nil {
+		return nil, err
+	}
+	var keyColumns []string
+	if v, ok := directive.GetParam("key"); ok && v != "" {
+		keyColumns = strings.Split(v, "|")
+	}
+	return watch.NewWatcher(handlerCtx, query, interval, keyColumns), nil
+}
+
+func runWatcher(watcher *watch.Watcher) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return watcher.Run(ctx)
 }
@@ -15,6 +15,7 @@ import (
 	"infraql/internal/iql/config"
 	"infraql/internal/iql/entryutil"
 	"infraql/internal/iql/handler"
+	"infraql/internal/iql/iqlerror"
 	"infraql/internal/iql/provider"
 	"infraql/internal/iql/querysubmit"
 	"infraql/internal/iql/responsehandler"
@@ -234,6 +235,90 @@ func TestSimpleInsertGoogleComputeNetworkAsync(t *testing.T) {
 
 }
 
+func TestSimpleInsertGoogleComputeNetworkAsyncFailed(t *testing.T) {
+	runtimeCtx, err := infraqltestutil.GetRuntimeCtx(config.GetGoogleProviderString(), "text")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	sqlEngine, err := infraqltestutil.BuildSQLEngine(*runtimeCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	testSubject := func(t *testing.T, outFile *bufio.Writer) {
+
+		handlerCtx, err := entryutil.BuildHandlerContext(*runtimeCtx, strings.NewReader(""), lrucache.NewLRUCache(int64(runtimeCtx.QueryCacheSize)), sqlEngine)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+
+		handlerCtx.Outfile = outFile
+		handlerCtx.OutErrFile = os.Stderr
+
+		tc, err := entryutil.GetTxnCounterManager(handlerCtx)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		handlerCtx.TxnCounterMgr = tc
+
+		handlerCtx.Query = testobjects.SimpleInsertComputeNetwork
+		response := querysubmit.SubmitQuery(&handlerCtx)
+		opErr, ok := response.Err.(*iqlerror.OperationError)
+		if !ok {
+			t.Fatalf("Test failed: expected operation error, got %v", response.Err)
+		}
+		if len(opErr.Errors) != 1 || opErr.Errors[0].Code != "RESOURCE_ALREADY_EXISTS" {
+			t.Fatalf("Test failed: unexpected operation error details %v", opErr.Errors)
+		}
+		handlerCtx.Outfile = outFile
+		responsehandler.HandleResponse(&handlerCtx, response)
+	}
+
+	infraqltestutil.SetupFailedInsertGoogleComputeNetworks(t)
+	infraqltestutil.RunCaptureTestAgainstFiles(t, testSubject, []string{testobjects.ExpectedComputeNetworkInsertAsyncFailedFile})
+
+}
+
+func TestSimpleInsertGoogleComputeNetworkAsyncWarnings(t *testing.T) {
+	runtimeCtx, err := infraqltestutil.GetRuntimeCtx(config.GetGoogleProviderString(), "text")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	sqlEngine, err := infraqltestutil.BuildSQLEngine(*runtimeCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	testSubject := func(t *testing.T, outFile *bufio.Writer) {
+
+		handlerCtx, err := entryutil.BuildHandlerContext(*runtimeCtx, strings.NewReader(""), lrucache.NewLRUCache(int64(runtimeCtx.QueryCacheSize)), sqlEngine)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+
+		handlerCtx.Outfile = outFile
+		handlerCtx.OutErrFile = os.Stderr
+
+		tc, err := entryutil.GetTxnCounterManager(handlerCtx)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		handlerCtx.TxnCounterMgr = tc
+
+		handlerCtx.Query = testobjects.SimpleInsertComputeNetwork
+		response := querysubmit.SubmitQuery(&handlerCtx)
+		if response.Err != nil {
+			t.Fatalf("Test failed: %v", response.Err)
+		}
+		handlerCtx.Outfile = outFile
+		responsehandler.HandleResponse(&handlerCtx, response)
+	}
+
+	infraqltestutil.SetupWarningsInsertGoogleComputeNetworks(t)
+	infraqltestutil.RunCaptureTestAgainstFiles(t, testSubject, []string{testobjects.ExpectedComputeNetworkInsertAsyncWarningsFile})
+
+}
+
 func TestK8sTheHardWayAsync(t *testing.T) {
 
 	runtimeCtx, err := infraqltestutil.GetRuntimeCtx(config.GetGoogleProviderString(), "text")
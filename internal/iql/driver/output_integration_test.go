@@ -0,0 +1,162 @@
+package driver_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "infraql/internal/iql/driver"
+	"infraql/internal/iql/localtable"
+	"infraql/internal/iql/output"
+	"infraql/internal/iql/provider"
+	"infraql/internal/test/infraqltestutil"
+	"infraql/internal/test/testhttpapi"
+	"infraql/internal/test/testobjects"
+
+	"vitess.io/vitess/go/sqltypes"
+)
+
+const networkOperationTemplate string = `{
+	"kind": "compute#operation",
+	"name": "op-%s",
+	"operationType": "insert",
+	"status": "%s",
+	"targetLink": "https://www.googleapis.com/compute/v1/projects/testing-project/global/networks/%s",
+	"selfLink": "https://www.googleapis.com/compute/v1/projects/testing-project/global/operations/op-%s"
+}`
+
+// networkInsertServer stands in for the compute API, network
+// inserts completing at the first poll.
+func networkInsertServer(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Host == "oauth2.googleapis.com":
+		fmt.Fprint(w, testobjects.GoogleAuthTokenResponse)
+	case r.Method == "POST" && r.URL.Path == "/compute/v1/projects/testing-project/global/networks":
+		fmt.Fprintf(w, networkOperationTemplate, "net-a", "RUNNING", "net-a", "net-a")
+	case r.Method == "GET" && r.URL.Path == "/compute/v1/projects/testing-project/global/operations/op-net-a":
+		fmt.Fprintf(w, networkOperationTemplate, "net-a", "DONE", "net-a", "net-a")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestEnvelopeAwait(t *testing.T) {
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(http.HandlerFunc(networkInsertServer), nil)
+	provider.DummyAuth = true
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "INSERT /*+ AWAIT(interval=0.05) */ INTO google.compute.networks(project, data__name) SELECT 'testing-project', 'net-a';", "json")
+	handlerCtx.RuntimeContext.OutputEnvelope = true
+	handlerCtx.RuntimeContext.HTTPBatchMaxParts = 0
+	var outfile, outErrFile bytes.Buffer
+	handlerCtx.Outfile = &outfile
+	handlerCtx.OutErrFile = &outErrFile
+
+	if failed := ProcessQuery(handlerCtx); failed != 0 {
+		t.Fatalf("Test failed: %d statements failed, stderr: %s", failed, outErrFile.String())
+	}
+	// the output is the envelope alone, progress going to stderr
+	var envelope output.Envelope
+	if err := json.Unmarshal(outfile.Bytes(), &envelope); err != nil {
+		t.Fatalf("Test failed: output is not an envelope: %v\n%s", err, outfile.String())
+	}
+	if len(envelope.Statements) != 1 || envelope.Statements[0].Status != output.EnvelopeStatusOK {
+		t.Fatalf("Test failed: unexpected envelope %+v", envelope)
+	}
+	if !strings.Contains(outErrFile.String(), "complete") {
+		t.Fatalf("Test failed: await progress not reported on stderr: '%s'", outErrFile.String())
+	}
+	if handlerCtx.StatusFile != nil {
+		t.Fatalf("Test failed: status file not restored")
+	}
+}
+
+func TestOutputRedirect(t *testing.T) {
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "json")
+	err := localtable.CreateTable(handlerCtx.SQLEngine, "nets", []localtable.Column{localtable.NewColumn("name", "text")}, false)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if _, err := localtable.InsertRows(handlerCtx.SQLEngine, "nets", []string{"name"}, [][]sqltypes.Value{{sqltypes.NewVarChar("net-a")}, {sqltypes.NewVarChar("net-b")}}); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	outfilePath := filepath.Join(t.TempDir(), "nets.csv")
+	if err := ioutil.WriteFile(outfilePath, []byte("earlier\n"), 0644); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	var outfile bytes.Buffer
+	handlerCtx.Outfile = &outfile
+
+	// only the redirected statement is written to the file, as csv
+	handlerCtx.RawQuery = fmt.Sprintf("/*+ OUTPUT(file='%s', format='csv', append=true) */ SELECT name FROM local.nets ORDER BY name; SELECT name FROM local.nets ORDER BY name;", outfilePath)
+	if failed := ProcessQuery(handlerCtx); failed != 0 {
+		t.Fatalf("Test failed: %d statements failed", failed)
+	}
+	b, err := ioutil.ReadFile(outfilePath)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if string(b) != "earlier\nname\nnet-a\nnet-b\n" {
+		t.Fatalf("Test failed: unexpected file contents '%s'", string(b))
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(outfile.Bytes(), &rows); err != nil {
+		t.Fatalf("Test failed: output of the statement not redirected is not json: %v\n%s", err, outfile.String())
+	}
+	if len(rows) != 2 || rows[0]["name"] != "net-a" {
+		t.Fatalf("Test failed: unexpected output %v", rows)
+	}
+	if handlerCtx.Outfile != &outfile || handlerCtx.RuntimeContext.OutputFormat != "json" || handlerCtx.RuntimeContext.OutfilePath != "" {
+		t.Fatalf("Test failed: output not restored after the redirected statement")
+	}
+
+	// without append, the file is overwritten
+	handlerCtx.RawQuery = fmt.Sprintf("/*+ OUTPUT(file='%s') */ SELECT name FROM local.nets WHERE name = 'net-b';", outfilePath)
+	if failed := ProcessQuery(handlerCtx); failed != 0 {
+		t.Fatalf("Test failed: %d statements failed", failed)
+	}
+	b, err = ioutil.ReadFile(outfilePath)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	rows = nil
+	if err := json.Unmarshal(b, &rows); err != nil || len(rows) != 1 || rows[0]["name"] != "net-b" {
+		t.Fatalf("Test failed: file not overwritten in the session's format: '%s'", string(b))
+	}
+}
+
+func TestOutputRedirectSQLite(t *testing.T) {
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "json")
+	err := localtable.CreateTable(handlerCtx.SQLEngine, "subnets", []localtable.Column{localtable.NewColumn("name", "text")}, false)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if _, err := localtable.InsertRows(handlerCtx.SQLEngine, "subnets", []string{"name"}, [][]sqltypes.Value{{sqltypes.NewVarChar("subnet-a")}, {sqltypes.NewVarChar("subnet-b")}}); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	outfilePath := filepath.Join(t.TempDir(), "subnets.db")
+
+	// each statement adds a table to the one file, without append
+	handlerCtx.RawQuery = fmt.Sprintf("/*+ OUTPUT(file='%s', format='sqlite') */ SELECT name FROM local.subnets ORDER BY name; /*+ OUTPUT(file='%s', format='sqlite') */ SELECT name FROM local.subnets WHERE name = 'subnet-b';", outfilePath, outfilePath)
+	if failed := ProcessQuery(handlerCtx); failed != 0 {
+		t.Fatalf("Test failed: %d statements failed", failed)
+	}
+	db, err := sql.Open("sqlite3", outfilePath)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	defer db.Close()
+	for table, expected := range map[string]int{"results": 2, "results_2": 1} {
+		var count int
+		if err := db.QueryRow(`SELECT count(*) FROM "` + table + `"`).Scan(&count); err != nil {
+			t.Fatalf("Test failed: table '%s' not written: %v", table, err)
+		}
+		if count != expected {
+			t.Fatalf("Test failed: table '%s' has %d rows, expected %d", table, count, expected)
+		}
+	}
+}
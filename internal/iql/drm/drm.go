@@ -15,6 +15,7 @@ import (
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	querypb "vitess.io/vitess/go/vt/proto/query"
 	"vitess.io/vitess/go/vt/sqlparser"
 )
 
@@ -63,6 +64,33 @@ func (cd ColumnMetadata) getTypeFromVal() string {
 	}
 }
 
+// GetQueryType returns the type of the column in result set fields,
+// so that output writers may render typed values.  The results of
+// functions are not typed by the schema of their arguments.
+func (cd ColumnMetadata) GetQueryType() querypb.Type {
+	if cd.Column.DecoratedCol != "" {
+		return querypb.Type_TEXT
+	}
+	var typeStr string
+	if cd.Column.Schema != nil {
+		typeStr = cd.Column.Schema.Type
+	} else if cd.Column.Val != nil {
+		typeStr = cd.getTypeFromVal()
+	}
+	switch typeStr {
+	case "boolean":
+		return querypb.Type_BIT
+	case "int", "integer":
+		return querypb.Type_INT64
+	case "float", "number":
+		return querypb.Type_FLOAT64
+	case "array", "object":
+		return querypb.Type_JSON
+	default:
+		return querypb.Type_TEXT
+	}
+}
+
 func NewColDescriptor(col metadata.ColumnDescriptor, relTypeStr string) ColumnMetadata {
 	return ColumnMetadata{
 		Coupling: DRMCoupling{RelationalType: relTypeStr, GolangKind: reflect.String},
@@ -0,0 +1,76 @@
+package dto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Named credential profiles are declared in the config file as
+// properties of the form auth.profile.<name>.<attribute>, eg:
+//
+//	auth.profile.prod-ro.type        = serviceaccount
+//	auth.profile.prod-ro.keyfilepath = /keys/prod-ro.json
+//
+// and selected per statement with /*+ AUTH(profile='prod-ro') */.
+const (
+	AuthProfileKeyPrefix      string = "auth.profile."
+	AuthProfileImpersonateStr string = "impersonate"
+	AuthProfileKeyFilePathStr string = "keyfilepath"
+	AuthProfileProviderStr    string = "provider"
+	AuthProfileTypeStr        string = "type"
+)
+
+type AuthProfile struct {
+	Name        string
+	Provider    string
+	Type        string
+	KeyFilePath string
+	Impersonate string
+}
+
+// ParseAuthProfiles extracts the profiles from config file properties,
+// ignoring any properties without the profile prefix.
+func ParseAuthProfiles(props map[string]string) (map[string]AuthProfile, error) {
+	retVal := make(map[string]AuthProfile)
+	var keys []string
+	for k := range props {
+		if strings.HasPrefix(k, AuthProfileKeyPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		suffix := k[len(AuthProfileKeyPrefix):]
+		dotIdx := strings.LastIndex(suffix, ".")
+		if dotIdx <= 0 {
+			return nil, fmt.Errorf("malformed auth profile property '%s', expected %s<name>.<attribute>", k, AuthProfileKeyPrefix)
+		}
+		name, attr := suffix[:dotIdx], strings.ToLower(suffix[dotIdx+1:])
+		profile := retVal[name]
+		profile.Name = name
+		val := strings.TrimSpace(props[k])
+		switch attr {
+		case AuthProfileImpersonateStr:
+			profile.Impersonate = val
+		case AuthProfileKeyFilePathStr:
+			profile.KeyFilePath = val
+		case AuthProfileProviderStr:
+			profile.Provider = val
+		case AuthProfileTypeStr:
+			profile.Type = strings.ToLower(val)
+		default:
+			return nil, fmt.Errorf("unknown auth profile attribute '%s' in property '%s'", attr, k)
+		}
+		retVal[name] = profile
+	}
+	return retVal, nil
+}
+
+// GetAuthCtx returns a fresh auth context for the profile.
+func (ap AuthProfile) GetAuthCtx() *AuthCtx {
+	authCtx := GetAuthCtx(nil, ap.KeyFilePath, ap.Type)
+	authCtx.Impersonate = ap.Impersonate
+	authCtx.Profile = ap.Name
+	return authCtx
+}
@@ -0,0 +1,52 @@
+package dto_test
+
+import (
+	"testing"
+
+	. "infraql/internal/iql/dto"
+)
+
+func TestParseAuthProfiles(t *testing.T) {
+	profiles, err := ParseAuthProfiles(map[string]string{
+		"auth.profile.prod-ro.type":        " ServiceAccount ",
+		"auth.profile.prod-ro.keyfilepath": "/keys/prod-ro.json",
+		"auth.profile.prod-ro.provider":    "google",
+		"auth.profile.dev.v2.Type":         "adc",
+		"auth.profile.dev.v2.impersonate":  "sa@project.iam.gserviceaccount.com",
+		"colorscheme":                      "dark",
+	})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("Test failed: expected 2 profiles, got %d", len(profiles))
+	}
+	prodRO := profiles["prod-ro"]
+	if prodRO.Name != "prod-ro" || prodRO.Type != "serviceaccount" || prodRO.KeyFilePath != "/keys/prod-ro.json" || prodRO.Provider != "google" {
+		t.Fatalf("Test failed: unexpected profile %+v", prodRO)
+	}
+	// the attribute is the last dotted part, so names may contain dots
+	dev := profiles["dev.v2"]
+	if dev.Type != "adc" || dev.Impersonate != "sa@project.iam.gserviceaccount.com" {
+		t.Fatalf("Test failed: unexpected profile %+v", dev)
+	}
+	authCtx := dev.GetAuthCtx()
+	if authCtx.Profile != "dev.v2" || authCtx.Type != "adc" || authCtx.Impersonate != dev.Impersonate {
+		t.Fatalf("Test failed: unexpected auth context %+v", authCtx)
+	}
+	if authCtx == dev.GetAuthCtx() {
+		t.Fatalf("Test failed: auth context shared between calls")
+	}
+}
+
+func TestParseAuthProfilesMalformed(t *testing.T) {
+	for _, props := range []map[string]string{
+		{"auth.profile.prod-ro": "serviceaccount"},
+		{"auth.profile..type": "serviceaccount"},
+		{"auth.profile.prod-ro.scopes": "cloud-platform"},
+	} {
+		if _, err := ParseAuthProfiles(props); err == nil {
+			t.Fatalf("Test failed: expected error parsing %v", props)
+		}
+	}
+}
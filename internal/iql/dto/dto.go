@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"context"
 	"io"
 	"strconv"
 
@@ -9,8 +10,16 @@ import (
 )
 
 const (
+	ADCSourceEnvStr           string = "env"
+	ADCSourceMetadataStr      string = "metadata"
+	ADCSourceWellKnownStr     string = "wellknown"
+	AuthADCStr                string = "adc"
+	AuthImpersonateStr        string = "impersonate"
 	AuthInteractiveStr        string = "interactive"
 	AuthServiceAccountStr     string = "serviceaccount"
+	OAuthFlowAuthCodeStr      string = "authcode"
+	OAuthFlowDeviceStr        string = "device"
+	OAuthFlowGcloudStr        string = "gcloud"
 	DarkColorScheme           string = "dark"
 	LightColorScheme          string = "light"
 	NullColorScheme           string = "null"
@@ -18,9 +27,11 @@ const (
 	DefaultWindowsColorScheme string = NullColorScheme
 	DryRunFlagKey             string = "dryrun"
 	APIRequestTimeoutKey      string = "apirequesttimeout"
+	AuthTypeKey               string = "authtype"
 	CacheKeyCountKey          string = "cachekeycount"
 	CacheTTLKey               string = "metadatattl"
 	ColorSchemeKey            string = "colorscheme"
+	ConcurrencyLimitKey       string = "concurrencylimit"
 	ConfigFilePathKey         string = "configfile"
 	CSVHeadersDisableKey      string = "hideheaders"
 	DbEngineKey               string = "dbengine"
@@ -29,23 +40,44 @@ const (
 	DbInitFilePathKey         string = "dbinitfilepath"
 	DelimiterKey              string = "delimiter"
 	ErrorPresentationKey      string = "errorpresentation"
+	GCEMetadataURLKey         string = "gce.metadataurl"
+	HTTPBatchMaxPartsKey      string = "http.batch.maxparts"
 	HTTPMaxResultsKey         string = "http.response.maxResults"
+	IAMCredentialsURLKey      string = "iam.credentialsurl"
+	ImpersonateKey            string = "impersonate"
 	HTTPProxyHostKey          string = "http.proxy.host"
 	HTTPProxyPasswordKey      string = "http.proxy.password"
 	HTTPProxyPortKey          string = "http.proxy.port"
 	HTTPProxySchemeKey        string = "http.proxy.scheme"
 	HTTPProxyUserKey          string = "http.proxy.user"
+	HTTPRecordDirKey          string = "http.record"
+	HTTPReplayDirKey          string = "http.replay"
+	HTTPRetryBackoffKey       string = "http.retry.backoff"
+	HTTPRetryMaxAttemptsKey   string = "http.retry.maxattempts"
+	HTTPRetryMaxBackoffKey    string = "http.retry.maxbackoff"
+	HTTPRetryMutationsKey     string = "http.retry.mutations"
 	InfilePathKey             string = "infile"
 	KeyFilePathKey            string = "keyfilepath"
 	LogLevelStrKey            string = "loglevel"
+	OAuthAuthURLKey           string = "oauth.authurl"
+	OAuthClientIDKey          string = "oauth.clientid"
+	OAuthClientSecretKey      string = "oauth.clientsecret"
+	OAuthDeviceAuthURLKey     string = "oauth.deviceauthurl"
+	OAuthFlowKey              string = "oauth.flow"
+	OAuthRevokeURLKey         string = "oauth.revokeurl"
+	OAuthTokenURLKey          string = "oauth.tokenurl"
+	OperationsRefreshKey      string = "operations.refresh"
 	OutfilePathKey            string = "outfile"
+	OutputEnvelopeKey         string = "output-envelope"
 	OutputFormatKey           string = "output"
 	ProviderRootPathKey       string = "providerroot"
 	ProviderRootPathModeKey   string = "providerrootfilemode"
 	ProviderStrKey            string = "provider"
 	QueryCacheSizeKey         string = "querycachesize"
+	ReadOnlyScopesKey         string = "readonlyscopes"
 	ReinitKey                 string = "reinit"
 	TemplateCtxFilePathKey    string = "iqldata"
+	TimingKey                 string = "timing"
 	TestWithoutApiCallsKey    string = "testwithoutapicalls"
 	UseNonPreferredAPIsKEy    string = "usenonpreferredapis"
 	VerboseFlagKey            string = "verbose"
@@ -58,6 +90,22 @@ type KeyVal struct {
 	V []byte
 }
 
+// OperationRecord is a long running operation begun by a statement,
+// as stored in the operations control table.
+type OperationRecord struct {
+	ID          string
+	SelfLink    string
+	Provider    string
+	Resource    string
+	Target      string
+	Principal   string
+	AuthProfile string
+	Status      string
+	Error       string
+	CreatedDttm string
+	UpdatedDttm string
+}
+
 type BackendMessages struct {
 	WorkingMessages []string
 }
@@ -81,6 +129,9 @@ type AuthCtx struct {
 	Type        string
 	ID          string
 	KeyFilePath string
+	Impersonate string
+	Source      string
+	Profile     string
 	Active      bool
 }
 
@@ -90,12 +141,13 @@ type ExecPayload struct {
 	PayloadMap map[string]interface{}
 }
 
-func GetAuthCtx(scopes []string, keyFilePath string) *AuthCtx {
-	var authType string
-	if keyFilePath == "" {
-		authType = AuthInteractiveStr
-	} else {
-		authType = AuthServiceAccountStr
+func GetAuthCtx(scopes []string, keyFilePath string, authType string) *AuthCtx {
+	if authType == "" {
+		if keyFilePath == "" {
+			authType = AuthInteractiveStr
+		} else {
+			authType = AuthServiceAccountStr
+		}
 	}
 	return &AuthCtx{
 		Scopes:      scopes,
@@ -107,9 +159,12 @@ func GetAuthCtx(scopes []string, keyFilePath string) *AuthCtx {
 
 type RuntimeCtx struct {
 	APIRequestTimeout    int
+	AuthProfiles         map[string]AuthProfile
+	AuthType             string
 	CacheKeyCount        int
 	CacheTTL             int
 	ColorScheme          string
+	ConcurrencyLimit     int
 	ConfigFilePath       string
 	CSVHeadersDisable    bool
 	DbEngine             string
@@ -119,24 +174,46 @@ type RuntimeCtx struct {
 	Delimiter            string
 	DryRunFlag           bool
 	ErrorPresentation    string
+	GCEMetadataURL       string
+	HTTPBatchMaxParts    int
 	HTTPMaxResults       int
 	HTTPProxyHost        string
 	HTTPProxyPassword    string
 	HTTPProxyPort        int
 	HTTPProxyScheme      string
 	HTTPProxyUser        string
+	HTTPRecordDir        string
+	HTTPReplayDir        string
+	HTTPRetryBackoff     int
+	HTTPRetryMaxAttempts int
+	HTTPRetryMaxBackoff  int
+	HTTPRetryMutations   bool
+	IAMCredentialsURL    string
+	Impersonate          string
 	InfilePath           string
 	KeyFilePath          string
 	LogLevelStr          string
+	OAuthAuthURL         string
+	OAuthClientID        string
+	OAuthClientSecret    string
+	OAuthDeviceAuthURL   string
+	OAuthFlow            string
+	OAuthRevokeURL       string
+	OAuthTokenURL        string
+	OperationsRefresh    int
 	OutfilePath          string
+	OutputEnvelope       bool
 	OutputFormat         string
 	ProviderRootPath     string
 	ProviderRootPathMode uint32
 	ProviderStr          string
 	Reinit               bool
 	QueryCacheSize       int
+	RateLimits           map[string]RateLimit
+	ReadOnlyScopes       bool
 	TemplateCtxFilePath  string
 	TestWithoutApiCalls  bool
+	Timing               bool
 	UseNonPreferredAPIs  bool
 	VerboseFlag          bool
 	ViperCfgFileName     string
@@ -172,12 +249,16 @@ func (rc *RuntimeCtx) Set(key string, val string) error {
 	switch key {
 	case APIRequestTimeoutKey:
 		retVal = setInt(&rc.APIRequestTimeout, val)
+	case AuthTypeKey:
+		rc.AuthType = val
 	case CacheKeyCountKey:
 		retVal = setInt(&rc.CacheKeyCount, val)
 	case CacheTTLKey:
 		retVal = setInt(&rc.CacheTTL, val)
 	case ColorSchemeKey:
 		rc.ColorScheme = val
+	case ConcurrencyLimitKey:
+		retVal = setInt(&rc.ConcurrencyLimit, val)
 	case ConfigFilePathKey:
 		rc.ConfigFilePath = val
 	case CSVHeadersDisableKey:
@@ -196,6 +277,10 @@ func (rc *RuntimeCtx) Set(key string, val string) error {
 		retVal = setBool(&rc.DryRunFlag, val)
 	case ErrorPresentationKey:
 		rc.ErrorPresentation = val
+	case GCEMetadataURLKey:
+		rc.GCEMetadataURL = val
+	case HTTPBatchMaxPartsKey:
+		retVal = setInt(&rc.HTTPBatchMaxParts, val)
 	case HTTPMaxResultsKey:
 		retVal = setInt(&rc.HTTPMaxResults, val)
 	case HTTPProxyHostKey:
@@ -208,14 +293,48 @@ func (rc *RuntimeCtx) Set(key string, val string) error {
 		rc.HTTPProxyScheme = val
 	case HTTPProxyUserKey:
 		rc.HTTPProxyUser = val
+	case HTTPRecordDirKey:
+		rc.HTTPRecordDir = val
+	case HTTPReplayDirKey:
+		rc.HTTPReplayDir = val
+	case HTTPRetryBackoffKey:
+		retVal = setInt(&rc.HTTPRetryBackoff, val)
+	case HTTPRetryMaxAttemptsKey:
+		retVal = setInt(&rc.HTTPRetryMaxAttempts, val)
+	case HTTPRetryMaxBackoffKey:
+		retVal = setInt(&rc.HTTPRetryMaxBackoff, val)
+	case HTTPRetryMutationsKey:
+		retVal = setBool(&rc.HTTPRetryMutations, val)
+	case IAMCredentialsURLKey:
+		rc.IAMCredentialsURL = val
+	case ImpersonateKey:
+		rc.Impersonate = val
 	case InfilePathKey:
 		rc.InfilePath = val
 	case KeyFilePathKey:
 		rc.KeyFilePath = val
 	case LogLevelStrKey:
 		rc.LogLevelStr = val
+	case OAuthAuthURLKey:
+		rc.OAuthAuthURL = val
+	case OAuthClientIDKey:
+		rc.OAuthClientID = val
+	case OAuthClientSecretKey:
+		rc.OAuthClientSecret = val
+	case OAuthDeviceAuthURLKey:
+		rc.OAuthDeviceAuthURL = val
+	case OAuthFlowKey:
+		rc.OAuthFlow = val
+	case OAuthRevokeURLKey:
+		rc.OAuthRevokeURL = val
+	case OAuthTokenURLKey:
+		rc.OAuthTokenURL = val
+	case OperationsRefreshKey:
+		retVal = setInt(&rc.OperationsRefresh, val)
 	case OutfilePathKey:
 		rc.OutfilePath = val
+	case OutputEnvelopeKey:
+		retVal = setBool(&rc.OutputEnvelope, val)
 	case OutputFormatKey:
 		rc.OutputFormat = val
 	case ProviderRootPathKey:
@@ -224,12 +343,16 @@ func (rc *RuntimeCtx) Set(key string, val string) error {
 		retVal = setUint32(&rc.ProviderRootPathMode, val)
 	case QueryCacheSizeKey:
 		retVal = setInt(&rc.QueryCacheSize, val)
+	case ReadOnlyScopesKey:
+		retVal = setBool(&rc.ReadOnlyScopes, val)
 	case ReinitKey:
 		retVal = setBool(&rc.Reinit, val)
 	case TemplateCtxFilePathKey:
 		rc.TemplateCtxFilePath = val
 	case TestWithoutApiCallsKey:
 		retVal = setBool(&rc.TestWithoutApiCalls, val)
+	case TimingKey:
+		retVal = setBool(&rc.Timing, val)
 	case UseNonPreferredAPIsKEy:
 		retVal = setBool(&rc.UseNonPreferredAPIs, val)
 	case VerboseFlagKey:
@@ -303,6 +426,7 @@ type BasicPrimitiveContext struct {
 	writer            io.Writer
 	errWriter         io.Writer
 	commentDirectives sqlparser.CommentDirectives
+	ctx               context.Context
 }
 
 func NewBasicPrimitiveContext(body map[string]interface{}, authCtx *AuthCtx, writer io.Writer, errWriter io.Writer, commentDirectives sqlparser.CommentDirectives) *BasicPrimitiveContext {
@@ -334,3 +458,16 @@ func (bpp *BasicPrimitiveContext) GetErrWriter() io.Writer {
 func (bpp *BasicPrimitiveContext) GetCommentDirectives() sqlparser.CommentDirectives {
 	return bpp.commentDirectives
 }
+
+// GetContext returns the context of the statement's execution, which is
+// cancelled should the user interrupt it, eg: while awaiting an operation.
+func (bpp *BasicPrimitiveContext) GetContext() context.Context {
+	if bpp.ctx == nil {
+		return context.Background()
+	}
+	return bpp.ctx
+}
+
+func (bpp *BasicPrimitiveContext) SetContext(ctx context.Context) {
+	bpp.ctx = ctx
+}
@@ -0,0 +1,75 @@
+package dto
+
+import (
+	"sync/atomic"
+)
+
+// QueryStats counts the work done by a statement, being reset before each.
+// The counters are safe for concurrent use, as requests fan out, and may
+// be incremented on a nil receiver, which counts nothing.
+type QueryStats struct {
+	HTTPCalls     uint64
+	Pages         uint64
+	BytesReceived uint64
+	RowsInserted  uint64
+	Retries       uint64
+}
+
+func NewQueryStats() *QueryStats {
+	return &QueryStats{}
+}
+
+func (qs *QueryStats) AddHTTPCall() {
+	if qs != nil {
+		atomic.AddUint64(&qs.HTTPCalls, 1)
+	}
+}
+
+func (qs *QueryStats) AddPage() {
+	if qs != nil {
+		atomic.AddUint64(&qs.Pages, 1)
+	}
+}
+
+func (qs *QueryStats) AddBytesReceived(n int) {
+	if qs != nil && n > 0 {
+		atomic.AddUint64(&qs.BytesReceived, uint64(n))
+	}
+}
+
+func (qs *QueryStats) AddRowInserted() {
+	if qs != nil {
+		atomic.AddUint64(&qs.RowsInserted, 1)
+	}
+}
+
+func (qs *QueryStats) AddRetry() {
+	if qs != nil {
+		atomic.AddUint64(&qs.Retries, 1)
+	}
+}
+
+// Get returns a copy of the counters.
+func (qs *QueryStats) Get() QueryStats {
+	if qs == nil {
+		return QueryStats{}
+	}
+	return QueryStats{
+		HTTPCalls:     atomic.LoadUint64(&qs.HTTPCalls),
+		Pages:         atomic.LoadUint64(&qs.Pages),
+		BytesReceived: atomic.LoadUint64(&qs.BytesReceived),
+		RowsInserted:  atomic.LoadUint64(&qs.RowsInserted),
+		Retries:       atomic.LoadUint64(&qs.Retries),
+	}
+}
+
+func (qs *QueryStats) Reset() {
+	if qs == nil {
+		return
+	}
+	atomic.StoreUint64(&qs.HTTPCalls, 0)
+	atomic.StoreUint64(&qs.Pages, 0)
+	atomic.StoreUint64(&qs.BytesReceived, 0)
+	atomic.StoreUint64(&qs.RowsInserted, 0)
+	atomic.StoreUint64(&qs.Retries, 0)
+}
@@ -0,0 +1,85 @@
+package dto
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Client side rate limits are declared in the config file as properties
+// of the form ratelimit.<provider>.<service>[.<resource>.<method>].<attribute>,
+// eg:
+//
+//	ratelimit.google.compute.rate                  = 20
+//	ratelimit.google.compute.burst                 = 40
+//	ratelimit.google.compute.concurrency           = 8
+//	ratelimit.google.compute.instances.insert.rate = 1
+//
+// where rate is in requests per second.  A request is subject to
+// both the limit for its service and that for its method, if any.
+const (
+	RateLimitKeyPrefix      string = "ratelimit."
+	RateLimitBurstStr       string = "burst"
+	RateLimitConcurrencyStr string = "concurrency"
+	RateLimitRateStr        string = "rate"
+)
+
+type RateLimit struct {
+	Scope       string
+	Rate        float64
+	Burst       int
+	Concurrency int
+}
+
+// GetRateLimitScopes returns the scopes of the limits that apply to
+// requests for the method, the service's first.
+func GetRateLimitScopes(hIds HeirarchyIdentifiers) []string {
+	svcScope := hIds.ProviderStr + "." + hIds.ServiceStr
+	if hIds.ResourceStr == "" || hIds.MethodStr == "" {
+		return []string{svcScope}
+	}
+	return []string{svcScope, svcScope + "." + hIds.ResourceStr + "." + hIds.MethodStr}
+}
+
+// ParseRateLimits extracts the rate limits from config file properties,
+// ignoring any properties without the rate limit prefix.
+func ParseRateLimits(props map[string]string) (map[string]RateLimit, error) {
+	retVal := make(map[string]RateLimit)
+	var keys []string
+	for k := range props {
+		if strings.HasPrefix(k, RateLimitKeyPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		suffix := k[len(RateLimitKeyPrefix):]
+		dotIdx := strings.LastIndex(suffix, ".")
+		// a scope of three segments would be a service plus either
+		// a resource or a method, which is ambiguous
+		if segments := strings.Count(suffix, "."); dotIdx <= 0 || segments == 1 || segments == 3 {
+			return nil, fmt.Errorf("malformed rate limit property '%s', expected %s<provider>.<service>[.<resource>.<method>].<attribute>", k, RateLimitKeyPrefix)
+		}
+		scope, attr := suffix[:dotIdx], strings.ToLower(suffix[dotIdx+1:])
+		limit := retVal[scope]
+		limit.Scope = scope
+		val := strings.TrimSpace(props[k])
+		var err error
+		switch attr {
+		case RateLimitBurstStr:
+			limit.Burst, err = strconv.Atoi(val)
+		case RateLimitConcurrencyStr:
+			limit.Concurrency, err = strconv.Atoi(val)
+		case RateLimitRateStr:
+			limit.Rate, err = strconv.ParseFloat(val, 64)
+		default:
+			return nil, fmt.Errorf("unknown rate limit attribute '%s' in property '%s'", attr, k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for rate limit property '%s'", val, k)
+		}
+		retVal[scope] = limit
+	}
+	return retVal, nil
+}
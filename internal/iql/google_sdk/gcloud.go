@@ -13,8 +13,18 @@ const (
 	gcloudInstallInfo     string = `Visit https://cloud.google.com/sdk to download the SDK or alternatively use serviceaccount mode, see https://docs.infraql.io/language-spec/auth for more information`
 	gcloudNotPresentError string = `Interactive mode requires the Google Cloud SDK to be installed on the system being used for infraql. ` + gcloudInstallInfo
 	gcloudRevokeError     string = "Error revoking gcloud credentials.  Please ensure that gcloud is installed and credentials are valid before running this command. " + gcloudInstallInfo
+	gcloudNotFoundError   string = `Interactive auth with --oauth.flow=gcloud requires the Google Cloud SDK, but gcloud was not found on the PATH. ` + gcloudInstallInfo + `; alternatively, omit --oauth.flow to use infraql's own OAuth flow`
 )
 
+// CheckInstalled errors where gcloud cannot be found, so that
+// delegating to it fails up front rather than at some later command.
+func CheckInstalled() error {
+	if _, err := exec.LookPath("gcloud"); err != nil {
+		return errors.New(gcloudNotFoundError)
+	}
+	return nil
+}
+
 func waitErrWrapper(cmd *exec.Cmd, prefixStr string) error {
 	return errWrapper(cmd.Wait(), prefixStr)
 }
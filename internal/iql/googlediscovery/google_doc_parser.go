@@ -32,6 +32,17 @@ func TranslateServiceKeyIqlToGoogle(serviceKey string) string {
 	return strings.Replace(serviceKey, infraqlServiceKeyDelimiter, googleServiceKeyDelimiter, -1)
 }
 
+// getBatchUrl returns the endpoint accepting batches of the
+// service's requests, or the empty string if there is none.
+func getBatchUrl(doc map[string]interface{}) string {
+	rootUrl, _ := doc["rootUrl"].(string)
+	batchPath, _ := doc["batchPath"].(string)
+	if rootUrl == "" || batchPath == "" {
+		return ""
+	}
+	return strings.TrimSuffix(rootUrl, "/") + "/" + strings.TrimPrefix(batchPath, "/")
+}
+
 func GoogleServiceDiscoveryDocParser(bytes []byte, dbEngine sqlengine.SQLEngine, prefix string) (map[string]interface{}, error) {
 	fields := strings.Split(prefix, ".")
 	if len(fields) != 2 {
@@ -51,6 +62,7 @@ func GoogleServiceDiscoveryDocParser(bytes []byte, dbEngine sqlengine.SQLEngine,
 	serviceName := result["id"].(string)
 	serviceId := TranslateServiceKeyGoogleToIql(serviceName)
 	baseUrl := result["baseUrl"].(string)
+	batchUrl := getBatchUrl(result)
 	var version string
 	v, ok := result["version"]
 	if ok {
@@ -92,6 +104,7 @@ func GoogleServiceDiscoveryDocParser(bytes []byte, dbEngine sqlengine.SQLEngine,
 				return nil, unmarshalErr
 			}
 			rsc.BaseUrl = baseUrl
+			rsc.BatchUrl = batchUrl
 			keys[k] = rsc
 		}
 	}
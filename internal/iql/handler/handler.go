@@ -1,13 +1,17 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"infraql/internal/iql/drm"
 	"infraql/internal/iql/dto"
 	"infraql/internal/iql/provider"
+	"infraql/internal/iql/ratelimit"
 	"infraql/internal/iql/sqlengine"
 	"infraql/internal/pkg/txncounter"
 	"io"
+	"net/http"
+	"sort"
 
 	lrucache "vitess.io/vitess/go/cache"
 )
@@ -22,14 +26,31 @@ type HandlerContext struct {
 	RuntimeContext    dto.RuntimeCtx
 	providers         map[string]provider.IProvider
 	CurrentProvider   string
+	AuthProfile       string
+	Scopes            []string
 	authContexts      map[string]*dto.AuthCtx
+	authProfiles      map[string]authProfileCtx
 	ErrorPresentation string
 	Outfile           io.Writer
 	OutErrFile        io.Writer
+	StatusFile        io.Writer
 	LRUCache          *lrucache.LRUCache
 	SQLEngine         sqlengine.SQLEngine
 	DrmConfig         drm.DRMConfig
 	TxnCounterMgr     *txncounter.TxnCounterManager
+	ThrottleStats     *ratelimit.Stats
+	QueryStats        *dto.QueryStats
+	Context           context.Context
+}
+
+// GetStatusFile returns where the progress of a statement is written, eg: that
+// of awaited operations, being the output unless the statement's results are
+// bound elsewhere, eg: an output envelope.
+func (hc *HandlerContext) GetStatusFile() io.Writer {
+	if hc.StatusFile != nil {
+		return hc.StatusFile
+	}
+	return hc.Outfile
 }
 
 func (hc *HandlerContext) GetProvider(providerName string) (provider.IProvider, error) {
@@ -44,11 +65,35 @@ func (hc *HandlerContext) GetProvider(providerName string) (provider.IProvider,
 	return provider, err
 }
 
+type authProfileCtx struct {
+	provider string
+	authCtx  *dto.AuthCtx
+}
+
+// GetAuthContext returns the auth context of the credential
+// profile selected for the current statement, if any, otherwise
+// the provider's default auth context.
 func (hc *HandlerContext) GetAuthContext(providerName string) (*dto.AuthCtx, error) {
+	return hc.GetAuthContextForProfile(providerName, hc.AuthProfile)
+}
+
+// GetAuthContextForProfile returns the auth context of the named
+// credential profile, or the provider's default if none is named.
+func (hc *HandlerContext) GetAuthContextForProfile(providerName string, authProfile string) (*dto.AuthCtx, error) {
 	var err error
 	if providerName == "" {
 		providerName = hc.RuntimeContext.ProviderStr
 	}
+	if authProfile != "" {
+		profile, ok := hc.authProfiles[authProfile]
+		if !ok {
+			return nil, fmt.Errorf("cannot find AUTH profile = '%s'", authProfile)
+		}
+		if profile.provider != providerName {
+			return nil, fmt.Errorf("AUTH profile '%s' is for provider '%s', not '%s'", authProfile, profile.provider, providerName)
+		}
+		return profile.authCtx, nil
+	}
 	authCtx, ok := hc.authContexts[providerName]
 	if !ok {
 		err = fmt.Errorf("cannot find AUTH context for provider = '%s'", providerName)
@@ -56,11 +101,55 @@ func (hc *HandlerContext) GetAuthContext(providerName string) (*dto.AuthCtx, err
 	return authCtx, err
 }
 
+// GetAuthContexts returns the provider's default auth context
+// followed by those of its credential profiles, in name order.
+func (hc *HandlerContext) GetAuthContexts(providerName string) []*dto.AuthCtx {
+	if providerName == "" {
+		providerName = hc.RuntimeContext.ProviderStr
+	}
+	var retVal []*dto.AuthCtx
+	if authCtx, ok := hc.authContexts[providerName]; ok {
+		retVal = append(retVal, authCtx)
+	}
+	var names []string
+	for k, v := range hc.authProfiles {
+		if v.provider == providerName {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		retVal = append(retVal, hc.authProfiles[name].authCtx)
+	}
+	return retVal
+}
+
+// GetAuthenticatedClient returns the http client for the current
+// auth context, requesting the current statement's scopes if any;
+// the provider caches clients per auth context and scope set.
+func (hc *HandlerContext) GetAuthenticatedClient(prov provider.IProvider) (*http.Client, error) {
+	authCtx, err := hc.GetAuthContext(prov.GetProviderString())
+	if err != nil {
+		return nil, err
+	}
+	return prov.AuthWithScopes(authCtx, hc.Scopes)
+}
+
 func GetHandlerCtx(cmdString string, runtimeCtx dto.RuntimeCtx, lruCache *lrucache.LRUCache, sqlEng sqlengine.SQLEngine) (HandlerContext, error) {
 	prov, err := provider.GetProviderFromRuntimeCtx(runtimeCtx, sqlEng)
 	if err != nil {
 		return HandlerContext{}, err
 	}
+	authCtx := dto.GetAuthCtx(nil, runtimeCtx.KeyFilePath, runtimeCtx.AuthType)
+	authCtx.Impersonate = runtimeCtx.Impersonate
+	authProfiles := make(map[string]authProfileCtx)
+	for name, profile := range runtimeCtx.AuthProfiles {
+		providerName := profile.Provider
+		if providerName == "" {
+			providerName = runtimeCtx.ProviderStr
+		}
+		authProfiles[name] = authProfileCtx{provider: providerName, authCtx: profile.GetAuthCtx()}
+	}
 	return HandlerContext{
 		RawQuery:       cmdString,
 		RuntimeContext: runtimeCtx,
@@ -68,12 +157,15 @@ func GetHandlerCtx(cmdString string, runtimeCtx dto.RuntimeCtx, lruCache *lrucac
 			runtimeCtx.ProviderStr: prov,
 		},
 		authContexts: map[string]*dto.AuthCtx{
-			runtimeCtx.ProviderStr: dto.GetAuthCtx(nil, runtimeCtx.KeyFilePath),
+			runtimeCtx.ProviderStr: authCtx,
 		},
+		authProfiles:      authProfiles,
 		ErrorPresentation: runtimeCtx.ErrorPresentation,
 		LRUCache:          lruCache,
 		SQLEngine:         sqlEng,
 		DrmConfig:         drmConfig,
 		TxnCounterMgr:     nil,
+		ThrottleStats:     ratelimit.NewStats(),
+		QueryStats:        dto.NewQueryStats(),
 	}, nil
 }
@@ -122,7 +122,10 @@ func getSelectRequestCtx(handlerCtx *handler.HandlerContext, prov provider.IProv
 	var path string
 	var httpVerb string
 	var err error
-	currentSvcRsc, _ := sqlparser.TableFromStatement(handlerCtx.Query)
+	currentSvcRsc, err := parserutil.ExtractSingleTableFromTableExprs(node.From)
+	if err != nil {
+		return nil, err
+	}
 	currentService := currentSvcRsc.Qualifier.GetRawVal()
 	currentResource := currentSvcRsc.Name.GetRawVal()
 	rsc, err := prov.GetResource(currentService, currentResource, handlerCtx.RuntimeContext)
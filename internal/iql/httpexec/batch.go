@@ -0,0 +1,197 @@
+package httpexec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Independent requests to a Google service may be sent together as the
+// parts of a single multipart/mixed request to the service's batch
+// endpoint.  Each part is itself an HTTP request, and the response is
+// likewise a multipart/mixed body of HTTP responses, whose Content-ID
+// headers identify the parts to which they respond.
+const (
+	MaxBatchParts        int    = 100
+	batchContentIDPrefix string = "item-"
+	batchResponsePrefix  string = "response-"
+)
+
+// BatchResult is the outcome of one part of a batch request.
+type BatchResult struct {
+	Response *http.Response
+	Err      error
+}
+
+// IsBatchable reports whether the request may be sent as part
+// of a batch to the endpoint, which requires the same host.
+func IsBatchable(batchUrl string, requestCtx IHttpContext) bool {
+	if batchUrl == "" {
+		return false
+	}
+	batchUrlObj, err := url.Parse(batchUrl)
+	if err != nil {
+		return false
+	}
+	urlStr, err := requestCtx.GetUrl()
+	if err != nil {
+		return false
+	}
+	urlObj, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(urlObj.Host, batchUrlObj.Host)
+}
+
+func writeBatchPart(mw *multipart.Writer, idx int, requestCtx IHttpContext) error {
+	urlStr, err := requestCtx.GetUrl()
+	if err != nil {
+		return err
+	}
+	urlObj, err := url.Parse(urlStr)
+	if err != nil {
+		return err
+	}
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Type", "application/http")
+	partHeader.Set("Content-Transfer-Encoding", "binary")
+	partHeader.Set("Content-ID", fmt.Sprintf("<%s%d>", batchContentIDPrefix, idx))
+	pw, err := mw.CreatePart(partHeader)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if requestCtx.GetBody() != nil {
+		body, err = ioutil.ReadAll(requestCtx.GetBody())
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(pw, "%s %s HTTP/1.1\r\n", requestCtx.GetMethod(), urlObj.RequestURI())
+	for k, v := range requestCtx.GetHeaders() {
+		// the batch request carries credentials for all of its parts
+		if strings.EqualFold(k, "Authorization") {
+			continue
+		}
+		for i := range v {
+			fmt.Fprintf(pw, "%s: %s\r\n", k, v[i])
+		}
+	}
+	if body != nil {
+		if requestCtx.GetHeaders().Get("Content-Type") == "" {
+			fmt.Fprintf(pw, "Content-Type: application/json\r\n")
+		}
+		fmt.Fprintf(pw, "Content-Length: %d\r\n", len(body))
+	}
+	fmt.Fprintf(pw, "\r\n")
+	_, err = pw.Write(body)
+	return err
+}
+
+// getBatchPartIndex reads the part number from a response Content-ID,
+// eg: "<response-item-3>".
+func getBatchPartIndex(contentID string) (int, bool) {
+	id := strings.Trim(contentID, "<>")
+	id = strings.TrimPrefix(id, batchResponsePrefix)
+	if !strings.HasPrefix(id, batchContentIDPrefix) {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(strings.TrimPrefix(id, batchContentIDPrefix))
+	return idx, err == nil
+}
+
+func readBatchResponse(response *http.Response, partCount int) ([]BatchResult, error) {
+	defer response.Body.Close()
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("batch response of unexpected content type '%s'", response.Header.Get("Content-Type"))
+	}
+	results := make([]BatchResult, partCount)
+	seen := make([]bool, partCount)
+	mr := multipart.NewReader(response.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		idx, ok := getBatchPartIndex(part.Header.Get("Content-ID"))
+		if !ok {
+			// parts are answered in order where they are not identified
+			idx = i
+		}
+		if idx < 0 || idx >= partCount {
+			continue
+		}
+		partResponse, err := http.ReadResponse(bufio.NewReader(part), nil)
+		if err != nil {
+			results[idx] = BatchResult{Err: fmt.Errorf("cannot read batch response part: %s", err.Error())}
+			seen[idx] = true
+			continue
+		}
+		// the part is read in full, as the next part supersedes it
+		b, err := ioutil.ReadAll(partResponse.Body)
+		partResponse.Body.Close()
+		if err != nil {
+			results[idx] = BatchResult{Err: err}
+			seen[idx] = true
+			continue
+		}
+		partResponse.Body = ioutil.NopCloser(bytes.NewReader(b))
+		results[idx] = BatchResult{Response: partResponse}
+		seen[idx] = true
+	}
+	for i := range results {
+		if !seen[i] {
+			results[i] = BatchResult{Err: fmt.Errorf("batch response lacks a response to part %d", i)}
+		}
+	}
+	return results, nil
+}
+
+// HTTPApiCallBatch sends the requests, at most MaxBatchParts of them, as a
+// single batch request to the endpoint, returning the outcome of each in
+// the same order.  An error is returned only if the batch as a whole fails.
+func HTTPApiCallBatch(httpClient *http.Client, batchUrl string, requestCtxs []IHttpContext, policy RetryPolicy) ([]BatchResult, error) {
+	if len(requestCtxs) > MaxBatchParts {
+		return nil, fmt.Errorf("batch of %d requests exceeds the maximum of %d", len(requestCtxs), MaxBatchParts)
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for i, requestCtx := range requestCtxs {
+		if err := writeBatchPart(mw, i, requestCtx); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	batchCtx := CreateNonTemplatedHttpContext(http.MethodPost, batchUrl, header)
+	batchCtx.SetBody(bytes.NewReader(buf.Bytes()))
+	response, err := HTTPApiCallWithRetry(httpClient, batchCtx, policy)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		_, err := ProcessHttpResponse(response)
+		if err == nil {
+			err = fmt.Errorf("batch request failed: %s", response.Status)
+		}
+		return nil, err
+	}
+	return readBatchResponse(response, len(requestCtxs))
+}
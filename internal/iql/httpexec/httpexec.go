@@ -1,10 +1,12 @@
 package httpexec
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"infraql/internal/iql/util"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 )
@@ -120,10 +122,24 @@ func HTTPApiCall(httpClient *http.Client, requestCtx IHttpContext) (*http.Respon
 	if requestErr != nil {
 		return nil, requestErr
 	}
+	if rr != nil && rr.replay {
+		return rr.serve(req)
+	}
+	var reqBody []byte
+	if rr != nil && req.Body != nil {
+		reqBody, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
 	response, reponseErr := httpClient.Do(req)
 	if reponseErr != nil {
 		return nil, reponseErr
 	}
+	if rr != nil {
+		return rr.record(req, reqBody, response)
+	}
 	return response, nil
 }
 
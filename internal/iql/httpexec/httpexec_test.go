@@ -1,14 +1,23 @@
 package httpexec_test
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"infraql/internal/iql/dto"
 	. "infraql/internal/iql/httpexec"
 
 	"infraql/internal/test/testhttpapi"
@@ -91,3 +100,204 @@ func TestContextualisedRewrittenHTTPSCall(t *testing.T) {
 	ex.Put("google.com/create-widget", *expectations)
 	validateContextualisedHTTPCallHeavyweight(t, requestCtx, ex)
 }
+
+func TestHTTPApiCallWithRetry(t *testing.T) {
+	attempts := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if attempts == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{ "items": [] }`))
+	}))
+	defer s.Close()
+	stats := dto.NewQueryStats()
+	policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Stats: stats}
+
+	response, err := HTTPApiCallWithRetry(s.Client(), CreateNonTemplatedHttpContext("GET", s.URL, make(http.Header)), policy)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if response.StatusCode != http.StatusOK || attempts != 3 {
+		t.Fatalf("Test failed: status %d after %d attempts, expected 200 after 3", response.StatusCode, attempts)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	if counted := stats.Get(); counted.HTTPCalls != 3 || counted.Retries != 2 || counted.BytesReceived != uint64(len(body)) {
+		t.Fatalf("Test failed: unexpected stats %+v for %d bytes received", counted, len(body))
+	}
+
+	attempts = 0
+	response, err = HTTPApiCallWithRetry(s.Client(), CreateNonTemplatedHttpContext("POST", s.URL, make(http.Header)), policy)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if response.StatusCode != http.StatusTooManyRequests || attempts != 1 {
+		t.Fatalf("Test failed: mutation retried, status %d after %d attempts", response.StatusCode, attempts)
+	}
+
+	attempts = 0
+	policy.RetryMutations = true
+	requestCtx := CreateNonTemplatedHttpContext("POST", s.URL, make(http.Header))
+	requestCtx.SetBody(strings.NewReader(`{ "name": "x" }`))
+	response, err = HTTPApiCallWithRetry(s.Client(), requestCtx, policy)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if response.StatusCode != http.StatusOK || attempts != 3 {
+		t.Fatalf("Test failed: status %d after %d attempts with mutations retried", response.StatusCode, attempts)
+	}
+}
+
+func TestHTTPApiCallWithRetryCancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: time.Minute, Context: ctx}
+
+	// the wait of a minute asked for is cut short by cancellation
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := HTTPApiCallWithRetry(s.Client(), CreateNonTemplatedHttpContext("GET", s.URL, make(http.Header)), policy)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Test failed: expected cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Test failed: cancelled retry took %v", elapsed)
+	}
+}
+
+func TestHTTPRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httprecord")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	polls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		w.Write([]byte(fmt.Sprintf(`{ "status": "%d", "access_token": "secret-token" }`, polls)))
+	}))
+	defer s.Close()
+	headers := make(http.Header)
+	headers.Set("Authorization", "Bearer secret-token")
+
+	if err := ConfigureRecordReplay(dir, ""); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	defer ConfigureRecordReplay("", "")
+	for _, u := range []string{s.URL + "/ops?b=2&a=1", s.URL + "/ops?a=1&b=2&access_token=x"} {
+		response, err := HTTPApiCall(s.Client(), CreateNonTemplatedHttpContext("GET", u, headers))
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		response.Body.Close()
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Fatalf("Test failed: %d exchanges recorded, expected 2", len(files))
+	}
+	for _, f := range files {
+		b, _ := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if strings.Contains(string(b), "secret-token") {
+			t.Fatalf("Test failed: credentials recorded in %s", f.Name())
+		}
+	}
+
+	if err := ConfigureRecordReplay("", dir); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	s.Close()
+	for _, expected := range []string{"1", "2", "2"} {
+		response, err := HTTPApiCall(http.DefaultClient, CreateNonTemplatedHttpContext("GET", s.URL+"/ops?a=1&b=2", headers))
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		b, _ := ioutil.ReadAll(response.Body)
+		if !strings.Contains(string(b), fmt.Sprintf(`"status":"%s"`, expected)) {
+			t.Fatalf("Test failed: replayed '%s', expected status %s", string(b), expected)
+		}
+	}
+	if _, err := HTTPApiCall(http.DefaultClient, CreateNonTemplatedHttpContext("GET", s.URL+"/other", headers)); err == nil {
+		t.Fatalf("Test failed: unrecorded request served")
+	}
+}
+
+func TestHTTPApiCallBatch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var parts []string
+		var ids []string
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			req, err := http.ReadRequest(bufio.NewReader(part))
+			if err != nil {
+				t.Fatalf("Test failed: %v", err)
+			}
+			body, _ := ioutil.ReadAll(req.Body)
+			parts = append(parts, fmt.Sprintf("%s %s %s", req.Method, req.URL.RequestURI(), string(body)))
+			ids = append(ids, part.Header.Get("Content-ID"))
+		}
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		// answered in reverse, so that parts must be matched by id
+		for i := len(parts) - 1; i >= 0; i-- {
+			pw, _ := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type": {"application/http"},
+				"Content-Id":   {"<response-" + strings.Trim(ids[i], "<>") + ">"},
+			})
+			if strings.Contains(parts[i], "missing") {
+				fmt.Fprintf(pw, "HTTP/1.1 404 Not Found\r\nContent-Type: application/json\r\n\r\n{ \"error\": { \"code\": 404 } }")
+				continue
+			}
+			fmt.Fprintf(pw, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{ \"name\": %q }", parts[i])
+		}
+		mw.Close()
+	}))
+	defer s.Close()
+
+	var requestCtxs []IHttpContext
+	for _, name := range []string{"a", "missing", "c"} {
+		requestCtx := CreateNonTemplatedHttpContext("POST", s.URL+"/compute/v1/instances?zone=z1", make(http.Header))
+		requestCtx.SetBody(strings.NewReader(fmt.Sprintf(`{"name":"%s"}`, name)))
+		if !IsBatchable(s.URL+"/batch/compute/v1", requestCtx) {
+			t.Fatalf("Test failed: request not batchable")
+		}
+		requestCtxs = append(requestCtxs, requestCtx)
+	}
+	results, err := HTTPApiCallBatch(s.Client(), s.URL+"/batch/compute/v1", requestCtxs, RetryPolicy{})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Test failed: %d results, expected 3", len(results))
+	}
+	for i, expected := range []string{"a", "missing", "c"} {
+		target, err := ProcessHttpResponse(results[i].Response)
+		if expected == "missing" {
+			if err == nil {
+				t.Fatalf("Test failed: error response to part %d not reported", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		if target["name"] != fmt.Sprintf(`POST /compute/v1/instances?zone=z1 {"name":"%s"}`, expected) {
+			t.Fatalf("Test failed: part %d answered with '%v'", i, target["name"])
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package httpexec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// In record mode every request and response made by HTTPApiCall is
+// written to a directory, one file per exchange, with credentials
+// redacted.  In replay mode responses are served from such a directory
+// instead of the network.  Exchanges are matched on method, path and
+// normalized query; repeats of a request, eg: the polls of an operation,
+// are served in the order that they were recorded, the last repeatedly.
+const (
+	redactedStr      string = "REDACTED"
+	exchangeFileExt  string = ".json"
+	exchangeFileMode        = 0600
+)
+
+// redactedHeaders and redactedParams are matched case insensitively;
+// redactedParams apply to query parameters and JSON body properties.
+var (
+	redactedHeaders = map[string]bool{
+		"authorization":       true,
+		"cookie":              true,
+		"proxy-authorization": true,
+		"set-cookie":          true,
+		"x-goog-api-key":      true,
+	}
+	redactedParams = map[string]bool{
+		"access_token":   true,
+		"accesstoken":    true,
+		"client_secret":  true,
+		"id_token":       true,
+		"key":            true,
+		"password":       true,
+		"private_key":    true,
+		"privatekeydata": true,
+		"refresh_token":  true,
+		"secret":         true,
+	}
+)
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+type recordedExchange struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordReplay struct {
+	mutex  sync.Mutex
+	dir    string
+	replay bool
+	counts map[string]int
+}
+
+var rr *recordReplay
+
+// ConfigureRecordReplay sets up record mode if recordDir is given,
+// or replay mode if replayDir is given; at most one may be.
+func ConfigureRecordReplay(recordDir string, replayDir string) error {
+	switch {
+	case recordDir != "" && replayDir != "":
+		return fmt.Errorf("cannot both record and replay http exchanges")
+	case recordDir != "":
+		if err := os.MkdirAll(recordDir, 0700); err != nil {
+			return err
+		}
+		rr = &recordReplay{dir: recordDir, counts: make(map[string]int)}
+	case replayDir != "":
+		if fi, err := os.Stat(replayDir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("cannot replay http exchanges from '%s': no such directory", replayDir)
+		}
+		rr = &recordReplay{dir: replayDir, replay: true, counts: make(map[string]int)}
+	default:
+		rr = nil
+	}
+	return nil
+}
+
+// IsReplaying reports whether responses are served from a recording,
+// in which case requests need no credentials.
+func IsReplaying() bool {
+	return rr != nil && rr.replay
+}
+
+func isRedacted(k string, redacted map[string]bool) bool {
+	return redacted[strings.ToLower(k)]
+}
+
+func redactHeader(header http.Header) http.Header {
+	retVal := make(http.Header)
+	for k, v := range header {
+		if isRedacted(k, redactedHeaders) {
+			retVal[k] = []string{redactedStr}
+			continue
+		}
+		retVal[k] = v
+	}
+	return retVal
+}
+
+func redactURL(u *url.URL) string {
+	redacted := *u
+	q := u.Query()
+	for k := range q {
+		if isRedacted(k, redactedParams) {
+			q.Set(k, redactedStr)
+		}
+	}
+	redacted.RawQuery = q.Encode()
+	return redacted.String()
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if isRedacted(k, redactedParams) {
+				v[k] = redactedStr
+				continue
+			}
+			v[k] = redactJSON(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactJSON(v[i])
+		}
+	}
+	return v
+}
+
+// redactBody redacts credentials from JSON bodies; other bodies
+// are kept as they are.
+func redactBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	b, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(b)
+}
+
+// getExchangeKey identifies a request by method, path and query,
+// the latter sorted and stripped of credentials.
+func getExchangeKey(req *http.Request) string {
+	q := req.URL.Query()
+	for k, v := range q {
+		if isRedacted(k, redactedParams) {
+			q.Del(k)
+			continue
+		}
+		sort.Strings(v)
+	}
+	return strings.Join([]string{strings.ToUpper(req.Method), req.URL.Path, q.Encode()}, " ")
+}
+
+func (r *recordReplay) getExchangePath(key string, seq int) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(r.dir, fmt.Sprintf("%s-%03d%s", hex.EncodeToString(h[:8]), seq, exchangeFileExt))
+}
+
+func (r *recordReplay) nextSeq(key string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	seq := r.counts[key]
+	r.counts[key] = seq + 1
+	return seq
+}
+
+func (r *recordReplay) record(req *http.Request, reqBody []byte, response *http.Response) (*http.Response, error) {
+	respBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	exchange := recordedExchange{
+		Request: recordedRequest{
+			Method: req.Method,
+			URL:    redactURL(req.URL),
+			Header: redactHeader(req.Header),
+			Body:   redactBody(reqBody),
+		},
+		Response: recordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     redactHeader(response.Header),
+			Body:       redactBody(respBody),
+		},
+	}
+	b, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	key := getExchangeKey(req)
+	if err := ioutil.WriteFile(r.getExchangePath(key, r.nextSeq(key)), b, exchangeFileMode); err != nil {
+		return nil, fmt.Errorf("cannot record http exchange: %s", err.Error())
+	}
+	return response, nil
+}
+
+func (r *recordReplay) serve(req *http.Request) (*http.Response, error) {
+	key := getExchangeKey(req)
+	seq := r.nextSeq(key)
+	b, err := ioutil.ReadFile(r.getExchangePath(key, seq))
+	for os.IsNotExist(err) && seq > 0 {
+		seq--
+		b, err = ioutil.ReadFile(r.getExchangePath(key, seq))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response in '%s' for %s %s", r.dir, req.Method, redactURL(req.URL))
+	}
+	var exchange recordedExchange
+	if err := json.Unmarshal(b, &exchange); err != nil {
+		return nil, fmt.Errorf("cannot parse recorded http exchange: %s", err.Error())
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Response.StatusCode, http.StatusText(exchange.Response.StatusCode)),
+		StatusCode:    exchange.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        exchange.Response.Header,
+		Body:          ioutil.NopCloser(strings.NewReader(exchange.Response.Body)),
+		ContentLength: int64(len(exchange.Response.Body)),
+		Request:       req,
+	}, nil
+}
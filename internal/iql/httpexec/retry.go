@@ -0,0 +1,175 @@
+package httpexec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"infraql/internal/iql/dto"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy governs the retrying of requests that fail transiently,
+// ie: with a network error or a 429 or 5xx status that is worth trying
+// again.  Only requests that are safe to repeat are retried, unless
+// RetryMutations is set.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	RetryMutations bool
+	// Stats, if any, counts every attempt, retry and byte received.
+	Stats *dto.QueryStats
+	// Context, if any, cuts short the wait between attempts, eg: should
+	// the user interrupt the statement.
+	Context context.Context
+}
+
+func NewRetryPolicy(runtimeCtx dto.RuntimeCtx) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    runtimeCtx.HTTPRetryMaxAttempts,
+		InitialBackoff: time.Duration(runtimeCtx.HTTPRetryBackoff) * time.Millisecond,
+		MaxBackoff:     time.Duration(runtimeCtx.HTTPRetryMaxBackoff) * time.Millisecond,
+		RetryMutations: runtimeCtx.HTTPRetryMutations,
+	}
+}
+
+func (rp RetryPolicy) getContext() context.Context {
+	if rp.Context == nil {
+		return context.Background()
+	}
+	return rp.Context
+}
+
+func (rp RetryPolicy) isRetryableMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return rp.RetryMutations
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// getBackoff returns a duration chosen uniformly from zero to the
+// exponential backoff for the attempt, so that concurrent callers
+// rejected together do not all try again together.
+func (rp RetryPolicy) getBackoff(attempt int) time.Duration {
+	backoff := rp.InitialBackoff << uint(attempt)
+	if backoff <= 0 || backoff > rp.MaxBackoff {
+		backoff = rp.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// getRetryAfter reads the Retry-After header, which may be
+// given either in seconds or as an HTTP date.
+func getRetryAfter(response *http.Response) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	val := response.Header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	stats *dto.QueryStats
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.stats.AddBytesReceived(n)
+	return n, err
+}
+
+func (rp RetryPolicy) call(httpClient *http.Client, requestCtx IHttpContext) (*http.Response, error) {
+	rp.Stats.AddHTTPCall()
+	response, err := HTTPApiCall(httpClient, requestCtx)
+	if err == nil && response != nil && response.Body != nil && rp.Stats != nil {
+		response.Body = &countingReadCloser{ReadCloser: response.Body, stats: rp.Stats}
+	}
+	return response, err
+}
+
+// HTTPApiCallWithRetry makes the request, repeating it per the policy
+// for as long as it fails transiently.  The response to the final
+// attempt is returned, whatever its status, unless the policy's context
+// is done while waiting to try again.
+func HTTPApiCallWithRetry(httpClient *http.Client, requestCtx IHttpContext, policy RetryPolicy) (*http.Response, error) {
+	if policy.MaxAttempts <= 1 || !policy.isRetryableMethod(requestCtx.GetMethod()) {
+		return policy.call(httpClient, requestCtx)
+	}
+	// the body is read once, so that every attempt may send it
+	var body []byte
+	if requestCtx.GetBody() != nil {
+		b, err := ioutil.ReadAll(requestCtx.GetBody())
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	for attempt := 0; ; attempt++ {
+		if body != nil {
+			requestCtx.SetBody(bytes.NewReader(body))
+		}
+		response, err := policy.call(httpClient, requestCtx)
+		if attempt+1 >= policy.MaxAttempts || (err == nil && !isRetryableStatus(response.StatusCode)) {
+			return response, err
+		}
+		wait := policy.getBackoff(attempt)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = response.Status
+			if retryAfter, ok := getRetryAfter(response); ok {
+				wait = retryAfter
+				if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+					wait = policy.MaxBackoff
+				}
+			}
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+		urlStr, _ := requestCtx.GetUrl()
+		policy.Stats.AddRetry()
+		log.Warnln(fmt.Sprintf("retrying %s %s in %v, attempt %d of %d failed: %s", requestCtx.GetMethod(), urlStr, wait, attempt+1, policy.MaxAttempts, reason))
+		timer := time.NewTimer(wait)
+		select {
+		case <-policy.getContext().Done():
+			timer.Stop()
+			return nil, fmt.Errorf("retry of %s %s cancelled, attempt %d of %d failed: %s", requestCtx.GetMethod(), urlStr, attempt+1, policy.MaxAttempts, reason)
+		case <-timer.C:
+		}
+	}
+}
@@ -1,20 +1,94 @@
 package httpmiddleware
 
 import (
+	"infraql/internal/iql/dto"
 	"infraql/internal/iql/handler"
 	"infraql/internal/iql/httpexec"
 	"infraql/internal/iql/provider"
+	"infraql/internal/iql/ratelimit"
 	"net/http"
 )
 
-func HttpApiCall(handlerCtx handler.HandlerContext, prov provider.IProvider, requestCtx httpexec.IHttpContext) (*http.Response, error) {
-	authCtx, authErr := handlerCtx.GetAuthContext(prov.GetProviderString())
-	if authErr != nil {
-		return nil, authErr
-	}
-	httpClient, httpClientErr := prov.Auth(authCtx, authCtx.Type, false)
+// HttpApiCall makes the request for the method, once the
+// configured rate limits for its service and itself permit.
+func HttpApiCall(handlerCtx handler.HandlerContext, prov provider.IProvider, hIds dto.HeirarchyIdentifiers, requestCtx httpexec.IHttpContext) (*http.Response, error) {
+	httpClient, httpClientErr := handlerCtx.GetAuthenticatedClient(prov)
 	if httpClientErr != nil {
 		return nil, httpClientErr
 	}
-	return httpexec.HTTPApiCall(httpClient, requestCtx)
+	release, throttled := ratelimit.Acquire(handlerCtx.RuntimeContext.RateLimits, hIds)
+	defer release()
+	if handlerCtx.ThrottleStats != nil {
+		handlerCtx.ThrottleStats.Add(throttled)
+	}
+	return httpexec.HTTPApiCallWithRetry(httpClient, requestCtx, getRetryPolicy(handlerCtx))
+}
+
+func getRetryPolicy(handlerCtx handler.HandlerContext) httpexec.RetryPolicy {
+	policy := httpexec.NewRetryPolicy(handlerCtx.RuntimeContext)
+	policy.Stats = handlerCtx.QueryStats
+	policy.Context = handlerCtx.Context
+	return policy
+}
+
+// HttpApiCallBatch makes the requests for the method, which must be
+// independent of one another, coalescing those that the service accepts
+// in batches into batch requests of up to the configured number of parts.
+// The outcome of each request is returned in the same order.
+func HttpApiCallBatch(handlerCtx handler.HandlerContext, prov provider.IProvider, hIds dto.HeirarchyIdentifiers, batchUrl string, requestCtxs []httpexec.IHttpContext) []httpexec.BatchResult {
+	results := make([]httpexec.BatchResult, len(requestCtxs))
+	maxParts := handlerCtx.RuntimeContext.HTTPBatchMaxParts
+	if maxParts > httpexec.MaxBatchParts {
+		maxParts = httpexec.MaxBatchParts
+	}
+	var batchIdxs []int
+	for i, requestCtx := range requestCtxs {
+		if maxParts > 1 && httpexec.IsBatchable(batchUrl, requestCtx) {
+			batchIdxs = append(batchIdxs, i)
+			continue
+		}
+		response, err := HttpApiCall(handlerCtx, prov, hIds, requestCtx)
+		results[i] = httpexec.BatchResult{Response: response, Err: err}
+	}
+	if len(batchIdxs) == 0 {
+		return results
+	}
+	httpClient, httpClientErr := handlerCtx.GetAuthenticatedClient(prov)
+	for start := 0; start < len(batchIdxs); start += maxParts {
+		end := start + maxParts
+		if end > len(batchIdxs) {
+			end = len(batchIdxs)
+		}
+		chunkIdxs := batchIdxs[start:end]
+		if httpClientErr != nil {
+			for _, idx := range chunkIdxs {
+				results[idx] = httpexec.BatchResult{Err: httpClientErr}
+			}
+			continue
+		}
+		// a lone request gains nothing from batching
+		if len(chunkIdxs) == 1 {
+			response, err := HttpApiCall(handlerCtx, prov, hIds, requestCtxs[chunkIdxs[0]])
+			results[chunkIdxs[0]] = httpexec.BatchResult{Response: response, Err: err}
+			continue
+		}
+		chunk := make([]httpexec.IHttpContext, len(chunkIdxs))
+		for i, idx := range chunkIdxs {
+			chunk[i] = requestCtxs[idx]
+		}
+		release, throttled := ratelimit.AcquireN(handlerCtx.RuntimeContext.RateLimits, hIds, len(chunk))
+		if handlerCtx.ThrottleStats != nil {
+			handlerCtx.ThrottleStats.Add(throttled)
+		}
+		chunkResults, err := httpexec.HTTPApiCallBatch(httpClient, batchUrl, chunk, getRetryPolicy(handlerCtx))
+		release()
+		for i, idx := range chunkIdxs {
+			if err != nil {
+				results[idx] = httpexec.BatchResult{Err: err}
+				continue
+			}
+			results[idx] = chunkResults[i]
+		}
+	}
+	return results
 }
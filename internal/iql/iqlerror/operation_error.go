@@ -0,0 +1,89 @@
+package iqlerror
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OperationError is the failure of an asynchronous operation, as reported
+// in the error field of the completed operation, eg: for google compute,
+//
+//	"error": { "errors": [ { "code": "RESOURCE_ALREADY_EXISTS", "message": "..." } ] }
+//
+// or for google.longrunning operations, "error": { "code": 6, "message": "..." }.
+type OperationError struct {
+	Operation string
+	SelfLink  string
+	Errors    []OperationErrorDetail
+}
+
+type OperationErrorDetail struct {
+	Code     string
+	Location string
+	Message  string
+}
+
+func (ed OperationErrorDetail) String() string {
+	var sb strings.Builder
+	if ed.Code != "" {
+		sb.WriteString(ed.Code + ": ")
+	}
+	sb.WriteString(ed.Message)
+	if ed.Location != "" {
+		sb.WriteString(fmt.Sprintf(" (location: %s)", ed.Location))
+	}
+	return sb.String()
+}
+
+func (e *OperationError) Error() string {
+	details := make([]string, len(e.Errors))
+	for i, ed := range e.Errors {
+		details[i] = ed.String()
+	}
+	msg := fmt.Sprintf("%s failed: %s", e.Operation, strings.Join(details, "; "))
+	if e.SelfLink != "" {
+		msg += fmt.Sprintf(", see: %s", e.SelfLink)
+	}
+	return msg
+}
+
+func getStringField(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%v", v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// NewOperationError parses the error field of an operation.
+func NewOperationError(operation string, selfLink string, errorBody interface{}) *OperationError {
+	retVal := &OperationError{Operation: operation, SelfLink: selfLink}
+	errMap, ok := errorBody.(map[string]interface{})
+	if !ok {
+		retVal.Errors = append(retVal.Errors, OperationErrorDetail{Message: fmt.Sprintf("%v", errorBody)})
+		return retVal
+	}
+	if errs, ok := errMap["errors"].([]interface{}); ok {
+		for _, e := range errs {
+			if m, ok := e.(map[string]interface{}); ok {
+				retVal.Errors = append(retVal.Errors, OperationErrorDetail{
+					Code:     getStringField(m, "code"),
+					Location: getStringField(m, "location"),
+					Message:  getStringField(m, "message"),
+				})
+			}
+		}
+	}
+	if len(retVal.Errors) == 0 {
+		retVal.Errors = append(retVal.Errors, OperationErrorDetail{
+			Code:    getStringField(errMap, "code"),
+			Message: getStringField(errMap, "message"),
+		})
+	}
+	return retVal
+}
@@ -0,0 +1,33 @@
+package iqlerror_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "infraql/internal/iql/iqlerror"
+)
+
+func TestNewOperationError(t *testing.T) {
+	var computeErr, longrunningErr interface{}
+	if err := json.Unmarshal([]byte(`{"errors": [{"code": "RESOURCE_ALREADY_EXISTS", "location": "name", "message": "already exists"}, {"code": "QUOTA_EXCEEDED", "message": "quota exceeded"}]}`), &computeErr); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"code": 6, "message": "already exists"}`), &longrunningErr); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	opErr := NewOperationError("compute#operation: insert", "https://compute/operations/op-1", computeErr)
+	if len(opErr.Errors) != 2 {
+		t.Fatalf("Test failed: %d error details parsed, expected 2", len(opErr.Errors))
+	}
+	expected := "compute#operation: insert failed: RESOURCE_ALREADY_EXISTS: already exists (location: name); QUOTA_EXCEEDED: quota exceeded, see: https://compute/operations/op-1"
+	if opErr.Error() != expected {
+		t.Fatalf("Test failed: '%s' != '%s'", opErr.Error(), expected)
+	}
+
+	opErr = NewOperationError("operations/op-2", "", longrunningErr)
+	expected = "operations/op-2 failed: 6: already exists"
+	if opErr.Error() != expected {
+		t.Fatalf("Test failed: '%s' != '%s'", opErr.Error(), expected)
+	}
+}
@@ -0,0 +1,269 @@
+package localtable
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/sqlengine"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// Local tables are user owned tables persisted in the DRM database
+// under the "local" namespace; they are never garbage collected.
+const (
+	Qualifier       string = "local"
+	tableNamePrefix string = Qualifier + "."
+	defaultColType  string = "text"
+	nullStr         string = "null"
+)
+
+// Scratch tables materialise provider rows in the DRM for the length of
+// a statement that joins them with local tables; any left behind by an
+// interrupted statement are collected as unreachable.
+const (
+	scratchQualifier   string = "scratch"
+	scratchTablePrefix string = scratchQualifier + "."
+)
+
+type Column struct {
+	Name string
+	Type string
+}
+
+func NewColumn(name string, colType string) Column {
+	if colType == "" {
+		colType = defaultColType
+	}
+	return Column{Name: name, Type: colType}
+}
+
+func IsLocalTable(tn sqlparser.TableName) bool {
+	return tn.QualifierSecond.IsEmpty() && strings.ToLower(tn.Qualifier.GetRawVal()) == Qualifier && !tn.Name.IsEmpty()
+}
+
+func GetTableName(name string) string {
+	return tableNamePrefix + name
+}
+
+func GetScratchTableName(id int) string {
+	return fmt.Sprintf("%sjoin_%d", scratchTablePrefix, id)
+}
+
+// GetScratchTable returns the scratch table, as named by
+// GetScratchTableName, for use in a rewritten statement.
+func GetScratchTable(id int) sqlparser.TableName {
+	return sqlparser.TableName{
+		Qualifier: sqlparser.NewTableIdent(scratchQualifier),
+		Name:      sqlparser.NewTableIdent(fmt.Sprintf("join_%d", id)),
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func TableExists(eng sqlengine.SQLEngine, name string) (bool, error) {
+	var count int
+	rows, err := eng.Query(`SELECT count(*) FROM sqlite_schema WHERE type = 'table' AND name = ?`, GetTableName(name))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		err = rows.Scan(&count)
+	}
+	return count > 0, err
+}
+
+// GetColumnNames returns the columns of a local table, or none if
+// the table does not exist.
+func GetColumnNames(eng sqlengine.SQLEngine, name string) ([]string, error) {
+	rows, err := eng.Query(fmt.Sprintf("SELECT name FROM pragma_table_info(%s)", quoteLiteral(GetTableName(name))))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var retVal []string
+	for rows.Next() {
+		var colName string
+		if err := rows.Scan(&colName); err != nil {
+			return nil, err
+		}
+		retVal = append(retVal, colName)
+	}
+	return retVal, rows.Err()
+}
+
+func quoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+func CreateTable(eng sqlengine.SQLEngine, name string, columns []Column, ifNotExists bool) error {
+	return createTable(eng, GetTableName(name), columns, ifNotExists)
+}
+
+// CreateScratchTable (re)creates a scratch table, as named by GetScratchTableName.
+func CreateScratchTable(eng sqlengine.SQLEngine, tableName string, columns []Column) error {
+	if err := DropScratchTable(eng, tableName); err != nil {
+		return err
+	}
+	return createTable(eng, tableName, columns, false)
+}
+
+func DropScratchTable(eng sqlengine.SQLEngine, tableName string) error {
+	_, err := eng.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdentifier(tableName)))
+	return err
+}
+
+func createTable(eng sqlengine.SQLEngine, tableName string, columns []Column, ifNotExists bool) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("cannot create table '%s' with no columns", tableName)
+	}
+	var colDefs []string
+	for _, col := range columns {
+		colDefs = append(colDefs, fmt.Sprintf("%s %s", quoteIdentifier(col.Name), col.Type))
+	}
+	var ifNotExistsStr string
+	if ifNotExists {
+		ifNotExistsStr = "IF NOT EXISTS "
+	}
+	_, err := eng.Exec(fmt.Sprintf("CREATE TABLE %s%s ( %s )", ifNotExistsStr, quoteIdentifier(tableName), strings.Join(colDefs, ", ")))
+	return err
+}
+
+func DropTable(eng sqlengine.SQLEngine, name string, ifExists bool) error {
+	var ifExistsStr string
+	if ifExists {
+		ifExistsStr = "IF EXISTS "
+	}
+	_, err := eng.Exec(fmt.Sprintf("DROP TABLE %s%s", ifExistsStr, quoteIdentifier(GetTableName(name))))
+	return err
+}
+
+func valueToArg(val sqltypes.Value) interface{} {
+	// result sets render absent values as the literal "null"
+	if val.IsNull() || val.ToString() == nullStr {
+		return nil
+	}
+	return val.ToString()
+}
+
+// InsertRows writes rows into a local table in a single transaction,
+// returning the number of rows written.
+func InsertRows(eng sqlengine.SQLEngine, name string, columns []string, rows [][]sqltypes.Value) (int, error) {
+	return insertRows(eng, GetTableName(name), columns, rows)
+}
+
+func InsertScratchRows(eng sqlengine.SQLEngine, tableName string, columns []string, rows [][]sqltypes.Value) (int, error) {
+	return insertRows(eng, tableName, columns, rows)
+}
+
+func insertRows(eng sqlengine.SQLEngine, tableName string, columns []string, rows [][]sqltypes.Value) (int, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	var quotedCols, placeholders []string
+	for _, col := range columns {
+		quotedCols = append(quotedCols, quoteIdentifier(col))
+		placeholders = append(placeholders, "?")
+	}
+	query := fmt.Sprintf("INSERT INTO %s ( %s ) VALUES ( %s )", quoteIdentifier(tableName), strings.Join(quotedCols, ", "), strings.Join(placeholders, ", "))
+	db, err := eng.GetDB()
+	if err != nil {
+		return 0, err
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			txn.Rollback()
+			return 0, fmt.Errorf("disparity in columns to insert and supplied data for table '%s'", tableName)
+		}
+		args := make([]interface{}, len(row))
+		for i, val := range row {
+			args[i] = valueToArg(val)
+		}
+		if _, err := txn.Exec(query, args...); err != nil {
+			txn.Rollback()
+			return 0, err
+		}
+	}
+	return len(rows), txn.Commit()
+}
+
+// ClassifyTableNames reports whether all, and whether any, of the tables
+// referenced by the node are local.  Local table references need no
+// rewriting, since qualified names are rendered as a single quoted
+// identifier that matches the backing table.
+func ClassifyTableNames(node sqlparser.SQLNode) (bool, bool) {
+	allLocal, anyLocal := true, false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.AliasedTableExpr:
+			tn, ok := node.Expr.(sqlparser.TableName)
+			if !ok {
+				return true, nil
+			}
+			if IsLocalTable(tn) {
+				anyLocal = true
+			} else {
+				allLocal = false
+			}
+		}
+		return true, nil
+	}, node)
+	return allLocal, anyLocal
+}
+
+// Query runs a query against local tables and tabulates the result;
+// all values are rendered as text, as per the remainder of the DRM.
+func Query(eng sqlengine.SQLEngine, query string, varArgs ...interface{}) dto.ExecutorOutput {
+	rows, err := eng.Query(query, varArgs...)
+	if err != nil {
+		return dto.NewExecutorOutput(nil, nil, nil, err)
+	}
+	defer rows.Close()
+	colNames, err := rows.Columns()
+	if err != nil {
+		return dto.NewExecutorOutput(nil, nil, nil, err)
+	}
+	res := &sqltypes.Result{
+		Fields: make([]*querypb.Field, len(colNames)),
+	}
+	for i, name := range colNames {
+		res.Fields[i] = &querypb.Field{
+			Name: name,
+		}
+	}
+	for rows.Next() {
+		scanVars := make([]interface{}, len(colNames))
+		for i := range scanVars {
+			scanVars[i] = &sql.NullString{}
+		}
+		if err := rows.Scan(scanVars...); err != nil {
+			return dto.NewExecutorOutput(nil, nil, nil, err)
+		}
+		row := make([]sqltypes.Value, len(colNames))
+		for i, sv := range scanVars {
+			ns := sv.(*sql.NullString)
+			if ns.Valid {
+				row[i], _ = sqltypes.NewValue(querypb.Type_TEXT, []byte(ns.String))
+			} else {
+				row[i], _ = sqltypes.NewValue(querypb.Type_TEXT, []byte(nullStr))
+			}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return dto.NewExecutorOutput(nil, nil, nil, err)
+	}
+	res.RowsAffected = uint64(len(res.Rows))
+	return dto.NewExecutorOutput(res, nil, nil, nil)
+}
@@ -100,6 +100,7 @@ type Resource struct {
 	Title       string            `json:"title"`
 	Description string            `json:"description"`
 	BaseUrl     string            `json:"baseUrl"`
+	BatchUrl    string            `json:"batchUrl,omitempty"`
 	Methods     map[string]Method `json:"methods"`
 }
 
@@ -275,6 +276,7 @@ type Method struct {
 	RequestType  SchemaType                    `json:"request"`
 	ResponseType SchemaType                    `json:"response"`
 	Parameters   map[string]iqlmodel.Parameter `json:"parameters"`
+	Scopes       []string                      `json:"scopes"`
 }
 
 func (m *Method) GetColumnOrder(extended bool) []string {
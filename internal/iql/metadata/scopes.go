@@ -0,0 +1,98 @@
+package metadata
+
+import (
+	"sort"
+	"strings"
+
+	"infraql/internal/iql/constants"
+)
+
+// broadScopes grant access to every API, so are only
+// requested for methods that accept nothing narrower.
+var broadScopes = map[string]bool{
+	constants.GoogleCloudPlatformScope:         true,
+	constants.GoogleCloudPlatformReadOnlyScope: true,
+}
+
+func isReadOnlyScope(scope string) bool {
+	return strings.HasSuffix(scope, ".readonly") || strings.HasSuffix(scope, ".read-only")
+}
+
+// GetAcceptedScopes returns the OAuth scopes that authorise the method,
+// less the broad ones unless there is nothing narrower.  Where
+// preferReadOnly is set, the read only scopes are returned if any.
+func (m *Method) GetAcceptedScopes(preferReadOnly bool) []string {
+	var narrow []string
+	for _, s := range m.Scopes {
+		if !broadScopes[s] {
+			narrow = append(narrow, s)
+		}
+	}
+	accepted := m.Scopes
+	if len(narrow) > 0 {
+		accepted = narrow
+	}
+	if preferReadOnly {
+		var readOnly []string
+		for _, s := range accepted {
+			if isReadOnlyScope(s) {
+				readOnly = append(readOnly, s)
+			}
+		}
+		if len(readOnly) > 0 {
+			return readOnly
+		}
+	}
+	return accepted
+}
+
+// GetMinimalScopes returns a small set of scopes that includes one
+// of each method's accepted scopes.  It is chosen greedily, each round
+// taking the scope accepted by the most methods not yet authorised.
+// If any method lists no scopes the result is nil, meaning that the
+// provider's default scopes should be requested.
+func GetMinimalScopes(accepted [][]string) []string {
+	if len(accepted) == 0 {
+		return nil
+	}
+	for _, a := range accepted {
+		if len(a) == 0 {
+			return nil
+		}
+	}
+	var retVal []string
+	remaining := accepted
+	for len(remaining) > 0 {
+		counts := make(map[string]int)
+		for _, a := range remaining {
+			for _, s := range a {
+				counts[s]++
+			}
+		}
+		var best string
+		for s, c := range counts {
+			if c > counts[best] || (c == counts[best] && s < best) {
+				best = s
+			}
+		}
+		retVal = append(retVal, best)
+		var next [][]string
+		for _, a := range remaining {
+			if !containsScope(a, best) {
+				next = append(next, a)
+			}
+		}
+		remaining = next
+	}
+	sort.Strings(retVal)
+	return retVal
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
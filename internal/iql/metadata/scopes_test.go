@@ -0,0 +1,33 @@
+package metadata_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "infraql/internal/iql/metadata"
+)
+
+const (
+	cloudPlatform    string = "https://www.googleapis.com/auth/cloud-platform"
+	compute          string = "https://www.googleapis.com/auth/compute"
+	computeReadOnly  string = "https://www.googleapis.com/auth/compute.readonly"
+	storageReadWrite string = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+func TestGetMinimalScopes(t *testing.T) {
+	list := &Method{Scopes: []string{cloudPlatform, compute, computeReadOnly}}
+	insert := &Method{Scopes: []string{cloudPlatform, compute}}
+	upload := &Method{Scopes: []string{cloudPlatform, storageReadWrite}}
+
+	scopes := GetMinimalScopes([][]string{list.GetAcceptedScopes(true)})
+	if !reflect.DeepEqual(scopes, []string{computeReadOnly}) {
+		t.Fatalf("Test failed: read only select scopes = %v", scopes)
+	}
+	scopes = GetMinimalScopes([][]string{list.GetAcceptedScopes(false), insert.GetAcceptedScopes(false), upload.GetAcceptedScopes(false)})
+	if !reflect.DeepEqual(scopes, []string{compute, storageReadWrite}) {
+		t.Fatalf("Test failed: mutation scopes = %v", scopes)
+	}
+	if scopes = GetMinimalScopes([][]string{insert.GetAcceptedScopes(false), (&Method{}).GetAcceptedScopes(false)}); scopes != nil {
+		t.Fatalf("Test failed: scopes = %v, expected defaults for a method listing none", scopes)
+	}
+}
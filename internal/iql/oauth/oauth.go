@@ -0,0 +1,431 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	deviceGrantType         string = "urn:ietf:params:oauth:grant-type:device_code"
+	defaultDevicePollSecs   int    = 5
+	tokenFileMode           uint32 = 0600
+	authorizationPendingStr string = "authorization_pending"
+	slowDownStr             string = "slow_down"
+)
+
+// Config describes an OAuth 2.0 client and the authorization server
+// endpoints it talks to.  Endpoints are configurable so that flows may
+// be exercised against a stub server.
+type Config struct {
+	ClientID      string
+	ClientSecret  string
+	AuthURL       string
+	TokenURL      string
+	DeviceAuthURL string
+	RevokeURL     string
+	Scopes        []string
+}
+
+func (c Config) oauth2Config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  c.AuthURL,
+			TokenURL: c.TokenURL,
+		},
+		RedirectURL: redirectURL,
+		Scopes:      c.Scopes,
+	}
+}
+
+// StoredToken is the persisted result of a completed flow.
+type StoredToken struct {
+	Principal string        `json:"principal"`
+	Token     *oauth2.Token `json:"token"`
+}
+
+// NewPKCEVerifier returns a random code verifier per RFC 7636.
+func NewPKCEVerifier() (string, error) {
+	return randomString(32)
+}
+
+// PKCEChallenge returns the S256 code challenge for a verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(byteCount int) (string, error) {
+	b := make([]byte, byteCount)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeFlow runs the authorization code flow with PKCE, receiving
+// the redirect on a loopback listener.  The authorization URL is written
+// to w and handed to openURL, which would typically launch a browser.
+func AuthCodeFlow(ctx context.Context, cfg Config, openURL func(string) error, w io.Writer) (*oauth2.Token, error) {
+	verifier, err := NewPKCEVerifier()
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+	conf := cfg.oauth2Config(fmt.Sprintf("http://%s/", listener.Addr().String()))
+	type callbackResult struct {
+		code string
+		err  error
+	}
+	resultChan := make(chan callbackResult, 1)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			q := req.URL.Query()
+			var res callbackResult
+			switch {
+			case q.Get("state") != state:
+				res.err = fmt.Errorf("oauth callback state mismatch")
+			case q.Get("error") != "":
+				res.err = fmt.Errorf("oauth authorization failed: %s", q.Get("error"))
+			case q.Get("code") == "":
+				res.err = fmt.Errorf("oauth callback carried no authorization code")
+			default:
+				res.code = q.Get("code")
+			}
+			if res.err != nil {
+				http.Error(rw, res.err.Error(), http.StatusBadRequest)
+			} else {
+				fmt.Fprintln(rw, "Authentication complete, you may close this window.")
+			}
+			select {
+			case resultChan <- res:
+			default:
+			}
+		}),
+	}
+	go srv.Serve(listener)
+	defer srv.Close()
+	authURL := conf.AuthCodeURL(
+		state,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("code_challenge", PKCEChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	fmt.Fprintf(w, "Authenticating, visit the following URL if a browser window does not open:\n\n%s\n\n", authURL)
+	if openURL != nil {
+		if err := openURL(authURL); err != nil {
+			fmt.Fprintf(w, "could not open browser: %s\n", err.Error())
+		}
+	}
+	select {
+	case res := <-resultChan:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return conf.Exchange(ctx, res.code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type deviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURL         string `json:"verification_url"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+func (tr tokenResponse) toToken() *oauth2.Token {
+	tok := &oauth2.Token{
+		AccessToken:  tr.AccessToken,
+		TokenType:    tr.TokenType,
+		RefreshToken: tr.RefreshToken,
+	}
+	if tr.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	if tr.IDToken != "" {
+		tok = tok.WithExtra(map[string]interface{}{"id_token": tr.IDToken})
+	}
+	return tok
+}
+
+func getContextClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return http.DefaultClient
+}
+
+func postForm(ctx context.Context, endpoint string, vals url.Values, target interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(vals.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := getContextClient(ctx).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if target != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, target); err != nil {
+			return resp.StatusCode, fmt.Errorf("cannot parse response from '%s': %s", endpoint, err.Error())
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// DeviceFlow runs the device authorization flow, writing the user code
+// and verification URL to w and polling the token endpoint until the
+// user completes authorization elsewhere.
+func DeviceFlow(ctx context.Context, cfg Config, w io.Writer) (*oauth2.Token, error) {
+	if cfg.DeviceAuthURL == "" {
+		return nil, fmt.Errorf("device flow requires a device authorization url")
+	}
+	var dar deviceAuthResponse
+	status, err := postForm(ctx, cfg.DeviceAuthURL, url.Values{
+		"client_id": {cfg.ClientID},
+		"scope":     {strings.Join(cfg.Scopes, " ")},
+	}, &dar)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || dar.DeviceCode == "" {
+		return nil, fmt.Errorf("device authorization request failed with status %d", status)
+	}
+	verificationURL := dar.VerificationURI
+	if verificationURL == "" {
+		verificationURL = dar.VerificationURL
+	}
+	fmt.Fprintf(w, "To authenticate, visit %s and enter the code: %s\n", verificationURL, dar.UserCode)
+	interval := dar.Interval
+	if interval <= 0 {
+		interval = defaultDevicePollSecs
+	}
+	if dar.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(dar.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	vals := url.Values{
+		"client_id":   {cfg.ClientID},
+		"device_code": {dar.DeviceCode},
+		"grant_type":  {deviceGrantType},
+	}
+	if cfg.ClientSecret != "" {
+		vals.Set("client_secret", cfg.ClientSecret)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("device authorization expired before it was completed")
+		case <-time.After(time.Duration(interval) * time.Second):
+		}
+		var tr tokenResponse
+		_, err := postForm(ctx, cfg.TokenURL, vals, &tr)
+		if err != nil {
+			return nil, err
+		}
+		switch tr.Error {
+		case "":
+			if tr.AccessToken == "" {
+				return nil, fmt.Errorf("token response carried no access token")
+			}
+			return tr.toToken(), nil
+		case authorizationPendingStr:
+		case slowDownStr:
+			interval += defaultDevicePollSecs
+		default:
+			return nil, fmt.Errorf("device authorization failed: %s %s", tr.Error, tr.ErrorDesc)
+		}
+	}
+}
+
+// Revoke invalidates a token at the authorization server, preferring
+// the refresh token as revoking it also revokes derived access tokens.
+func Revoke(ctx context.Context, cfg Config, tok *oauth2.Token) error {
+	if cfg.RevokeURL == "" || tok == nil {
+		return nil
+	}
+	t := tok.RefreshToken
+	if t == "" {
+		t = tok.AccessToken
+	}
+	status, err := postForm(ctx, cfg.RevokeURL, url.Values{"token": {t}}, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("token revocation failed with status %d", status)
+	}
+	return nil
+}
+
+// GetPrincipal reads the email claim from the token's id_token, if any.
+// The id_token arrives directly from the token endpoint over TLS, so
+// its signature is not verified here.
+func GetPrincipal(tok *oauth2.Token) string {
+	idToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return ""
+	}
+	segments := strings.Split(idToken, ".")
+	if len(segments) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segments[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.Email
+}
+
+// OpenBrowser attempts to open a URL with the platform's default handler.
+func OpenBrowser(u string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", u).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", u).Start()
+	}
+	return exec.Command("xdg-open", u).Start()
+}
+
+// TokenStore persists a StoredToken as a file readable only by its owner.
+type TokenStore struct {
+	path string
+}
+
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+func (ts *TokenStore) GetPath() string {
+	return ts.path
+}
+
+// Load returns nil and no error where nothing has been stored.
+func (ts *TokenStore) Load() (*StoredToken, error) {
+	b, err := ioutil.ReadFile(ts.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st StoredToken
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("cannot parse stored credentials '%s': %s", ts.path, err.Error())
+	}
+	if st.Token == nil {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (ts *TokenStore) Save(st *StoredToken) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ts.path, b, os.FileMode(tokenFileMode))
+}
+
+func (ts *TokenStore) Delete() error {
+	err := os.Remove(ts.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+type refreshTokenSource struct {
+	ctx       context.Context
+	cfg       Config
+	store     *TokenStore
+	principal string
+	refresh   string
+}
+
+// Token redeems the refresh token for a new access token
+// on every call, saving each new token to the store.
+func (r *refreshTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := r.cfg.oauth2Config("").TokenSource(r.ctx, &oauth2.Token{RefreshToken: r.refresh}).Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok.RefreshToken != "" {
+		r.refresh = tok.RefreshToken
+	}
+	if err := r.store.Save(&StoredToken{Principal: r.principal, Token: tok}); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// RefreshTokenSource returns a source that obtains a new access token
+// from the stored refresh token whenever called, persisting the result.
+// Callers are expected to hold on to tokens until they near expiry.
+func RefreshTokenSource(ctx context.Context, cfg Config, store *TokenStore, st *StoredToken) oauth2.TokenSource {
+	return &refreshTokenSource{
+		ctx:       ctx,
+		cfg:       cfg,
+		store:     store,
+		principal: st.Principal,
+		refresh:   st.Token.RefreshToken,
+	}
+}
@@ -0,0 +1,136 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	. "infraql/internal/iql/oauth"
+)
+
+func getStubIDToken(email string) string {
+	payload, _ := json.Marshal(map[string]string{"email": email})
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// newStubServer mimics an authorization server, issuing
+// tokens only for the expected code and verifier.
+func newStubServer(challenges map[string]string) *httptest.Server {
+	devicePolls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "missing pkce", http.StatusBadRequest)
+			return
+		}
+		challenges["the-code"] = q.Get("code_challenge")
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=the-code&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"device_code":      "dev-code",
+			"user_code":        "ABCD-EFGH",
+			"verification_url": "https://example.com/device",
+			"expires_in":       60,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			if PKCEChallenge(r.Form.Get("code_verifier")) != challenges[r.Form.Get("code")] {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+				return
+			}
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			devicePolls++
+			if devicePolls < 2 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+				return
+			}
+		case "refresh_token":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "refreshed", "token_type": "Bearer", "expires_in": 3600})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token":  "access",
+			"token_type":    "Bearer",
+			"refresh_token": "refresh",
+			"expires_in":    3600,
+			"id_token":      getStubIDToken("someone@example.com"),
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func getStubConfig(srv *httptest.Server) Config {
+	return Config{
+		ClientID:      "client",
+		AuthURL:       srv.URL + "/auth",
+		TokenURL:      srv.URL + "/token",
+		DeviceAuthURL: srv.URL + "/device",
+		Scopes:        []string{"openid", "email"},
+	}
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	srv := newStubServer(make(map[string]string))
+	defer srv.Close()
+	browse := func(u string) error {
+		go http.Get(u)
+		return nil
+	}
+	tok, err := AuthCodeFlow(context.Background(), getStubConfig(srv), browse, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if tok.RefreshToken != "refresh" {
+		t.Fatalf("Test failed: refresh token = '%s', expected 'refresh'", tok.RefreshToken)
+	}
+	if p := GetPrincipal(tok); p != "someone@example.com" {
+		t.Fatalf("Test failed: principal = '%s', expected 'someone@example.com'", p)
+	}
+}
+
+func TestDeviceFlowAndRefresh(t *testing.T) {
+	srv := newStubServer(make(map[string]string))
+	defer srv.Close()
+	cfg := getStubConfig(srv)
+	tok, err := DeviceFlow(context.Background(), cfg, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	store := NewTokenStore(filepath.Join(t.TempDir(), "oauth_token.json"))
+	stored := &StoredToken{Principal: GetPrincipal(tok), Token: tok}
+	if err := store.Save(stored); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	refreshed, err := RefreshTokenSource(context.Background(), cfg, store, stored).Token()
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if refreshed.AccessToken != "refreshed" {
+		t.Fatalf("Test failed: access token = '%s', expected 'refreshed'", refreshed.AccessToken)
+	}
+	reloaded, err := store.Load()
+	if err != nil || reloaded == nil {
+		t.Fatalf("Test failed: stored token not reloaded: %v", err)
+	}
+	if reloaded.Token.AccessToken != "refreshed" || reloaded.Token.RefreshToken != "refresh" || reloaded.Principal != "someone@example.com" {
+		t.Fatalf("Test failed: refreshed token not persisted: %+v", reloaded)
+	}
+}
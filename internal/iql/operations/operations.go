@@ -0,0 +1,246 @@
+package operations
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/httpexec"
+	"infraql/internal/iql/iqlerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Every long running operation begun by a statement is recorded in the
+// operations control table, whether or not the statement awaits it, so
+// that it may be listed with SHOW OPERATIONS and awaited later by id.
+// Unfinished operations are refreshed in the background, including those
+// recorded by earlier sessions sharing the same db file.  Refreshes are
+// never concurrent with a statement, which may alter the auth contexts or
+// the operations themselves, and are not made at all while recording or
+// replaying http, whose exchanges must come in the order of the statements.
+// Operations the provider no longer knows of, eg: those Google has expired,
+// are marked EXPIRED, their outcome unknown, and refreshed no more.
+const (
+	StatusDone    string = "DONE"
+	StatusExpired string = "EXPIRED"
+	StatusFailed  string = "FAILED"
+	StatusPending string = "PENDING"
+	StatusRunning string = "RUNNING"
+)
+
+var finalStatuses []string = []string{StatusDone, StatusFailed, StatusExpired}
+
+var statementMutex sync.Mutex
+
+// Exclusive holds off background refreshes until the returned func
+// is called, eg: for the duration of a statement.
+func Exclusive() func() {
+	statementMutex.Lock()
+	return statementMutex.Unlock
+}
+
+func IsFinal(status string) bool {
+	for _, s := range finalStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPrincipal names the identity behind the auth context, being
+// the impersonated service account where there is one.
+func GetPrincipal(authCtx *dto.AuthCtx) string {
+	if authCtx == nil {
+		return ""
+	}
+	if authCtx.Impersonate != "" {
+		return authCtx.Impersonate
+	}
+	if authCtx.ID != "" {
+		return authCtx.ID
+	}
+	return authCtx.Type
+}
+
+// getStatus reads the status of an operation body, eg: for google
+// compute, "status": "DONE", or for google.longrunning, "done": true;
+// an operation that has failed is reported as such, with its error.
+func getStatus(id string, body map[string]interface{}) (string, string) {
+	if opErr, ok := body["error"]; ok && opErr != nil {
+		var details []string
+		for _, ed := range iqlerror.NewOperationError(id, "", opErr).Errors {
+			details = append(details, ed.String())
+		}
+		return StatusFailed, strings.Join(details, "; ")
+	}
+	if done, ok := body["done"].(bool); ok {
+		if done {
+			return StatusDone, ""
+		}
+		return StatusRunning, ""
+	}
+	if status, ok := body["status"].(string); ok && status != "" {
+		return strings.ToUpper(status), ""
+	}
+	return StatusPending, ""
+}
+
+// NewRecord returns the record of the operation body, filled out from
+// the template, iff the body is an operation that can be polled, either
+// at its selfLink or, for operations without one, the template's.
+func NewRecord(template dto.OperationRecord, body map[string]interface{}) (dto.OperationRecord, bool) {
+	retVal := template
+	selfLink, _ := body["selfLink"].(string)
+	if selfLink == "" {
+		selfLink = template.SelfLink
+	}
+	if selfLink == "" {
+		return retVal, false
+	}
+	retVal.SelfLink = selfLink
+	if name, ok := body["name"].(string); ok && name != "" {
+		retVal.ID = name
+	} else {
+		retVal.ID = selfLink
+	}
+	if target, ok := body["targetLink"].(string); ok {
+		retVal.Target = target
+	}
+	retVal.Status, retVal.Error = getStatus(retVal.ID, body)
+	return retVal, true
+}
+
+// Record stores the operation body iff it is an operation, logging
+// rather than returning any failure, which should not fail the statement.
+func Record(handlerCtx *handler.HandlerContext, template dto.OperationRecord, body map[string]interface{}) {
+	if body == nil {
+		return
+	}
+	op, ok := NewRecord(template, body)
+	if !ok {
+		return
+	}
+	if err := handlerCtx.SQLEngine.OperationStorePut(op); err != nil {
+		log.Warnln(fmt.Sprintf("cannot record operation '%s': %s", op.ID, err.Error()))
+	}
+}
+
+// Get returns the single operation of the given id or selfLink.
+func Get(handlerCtx *handler.HandlerContext, id string) (dto.OperationRecord, error) {
+	ops, err := handlerCtx.SQLEngine.OperationStoreGet(id)
+	if err != nil {
+		return dto.OperationRecord{}, err
+	}
+	switch len(ops) {
+	case 0:
+		return dto.OperationRecord{}, fmt.Errorf("operation '%s' not found, see SHOW OPERATIONS", id)
+	case 1:
+		return ops[0], nil
+	}
+	return dto.OperationRecord{}, fmt.Errorf("operation id '%s' is ambiguous, %d operations match, use the selfLink instead", id, len(ops))
+}
+
+// Poll fetches the operation, with the credentials that began it,
+// counting the request in the current statement's stats.
+func Poll(handlerCtx *handler.HandlerContext, op dto.OperationRecord) (map[string]interface{}, error) {
+	authCtx, err := handlerCtx.GetAuthContextForProfile(op.Provider, op.AuthProfile)
+	if err != nil {
+		return nil, err
+	}
+	return poll(handlerCtx, op, authCtx, handlerCtx.QueryStats)
+}
+
+func poll(handlerCtx *handler.HandlerContext, op dto.OperationRecord, authCtx *dto.AuthCtx, stats *dto.QueryStats) (map[string]interface{}, error) {
+	prov, err := handlerCtx.GetProvider(op.Provider)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := prov.AuthWithScopes(authCtx, nil)
+	if err != nil {
+		return nil, err
+	}
+	rc := httpexec.CreateNonTemplatedHttpContext("GET", op.SelfLink, nil)
+	policy := httpexec.NewRetryPolicy(handlerCtx.RuntimeContext)
+	policy.Stats = stats
+	response, err := httpexec.HTTPApiCallWithRetry(httpClient, rc, policy)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone {
+		response.Body.Close()
+		return nil, expire(handlerCtx, op, response.Status)
+	}
+	return httpexec.ProcessHttpResponse(response)
+}
+
+// expire marks the operation as one that can no longer be polled,
+// returning the error to report in place of its status.
+func expire(handlerCtx *handler.HandlerContext, op dto.OperationRecord, reason string) error {
+	op.Status = StatusExpired
+	op.Error = fmt.Sprintf("operation no longer available, its outcome is unknown: %s", reason)
+	if err := handlerCtx.SQLEngine.OperationStorePut(op); err != nil {
+		log.Warnln(fmt.Sprintf("cannot record operation '%s': %s", op.ID, err.Error()))
+	}
+	return fmt.Errorf("operation '%s' %s", op.ID, op.Error)
+}
+
+// refreshPending polls each unfinished operation once.  Those begun with
+// interactive credentials not yet obtained in this session are skipped,
+// rather than prompt for a login in the background.  Each is polled with a
+// copy of its auth context, so that the statements' own are left untouched.
+func refreshPending(handlerCtx *handler.HandlerContext) {
+	defer Exclusive()()
+	ops, err := handlerCtx.SQLEngine.OperationStoreGetPending(finalStatuses)
+	if err != nil {
+		log.Warnln(fmt.Sprintf("cannot refresh operations: %s", err.Error()))
+		return
+	}
+	for _, op := range ops {
+		authCtx, err := handlerCtx.GetAuthContextForProfile(op.Provider, op.AuthProfile)
+		if err != nil {
+			log.Infoln(fmt.Sprintf("not refreshing operation '%s': %s", op.ID, err.Error()))
+			continue
+		}
+		if authCtx.Type == dto.AuthInteractiveStr && !authCtx.Active {
+			continue
+		}
+		authCopy := *authCtx
+		// background requests are no statement's
+		body, err := poll(handlerCtx, op, &authCopy, nil)
+		if err != nil {
+			log.Infoln(fmt.Sprintf("cannot refresh operation '%s': %s", op.ID, err.Error()))
+			continue
+		}
+		Record(handlerCtx, op, body)
+	}
+}
+
+// StartRefresher refreshes unfinished operations at once and then every
+// RuntimeContext.OperationsRefresh seconds, until the returned func is called;
+// it does nothing while http is recorded or replayed.
+func StartRefresher(handlerCtx *handler.HandlerContext) func() {
+	interval := time.Duration(handlerCtx.RuntimeContext.OperationsRefresh) * time.Second
+	if interval <= 0 || handlerCtx.RuntimeContext.HTTPRecordDir != "" || handlerCtx.RuntimeContext.HTTPReplayDir != "" {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			refreshPending(handlerCtx)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,182 @@
+package operations_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"infraql/internal/iql/dto"
+	. "infraql/internal/iql/operations"
+	"infraql/internal/iql/provider"
+	"infraql/internal/iql/sqlengine"
+	"infraql/internal/test/infraqltestutil"
+	"infraql/internal/test/testhttpapi"
+	"infraql/internal/test/testobjects"
+)
+
+func TestRecordOperation(t *testing.T) {
+	template := dto.OperationRecord{Provider: "google", Resource: "compute.networks", Principal: "sa@project.iam.gserviceaccount.com"}
+	body := map[string]interface{}{
+		"name":       "operation-xxxxx-yyyyy-0001",
+		"selfLink":   "https://compute.googleapis.com/compute/v1/projects/p/global/operations/operation-xxxxx-yyyyy-0001",
+		"targetLink": "https://compute.googleapis.com/compute/v1/projects/p/global/networks/n",
+		"status":     "RUNNING",
+	}
+	op, ok := NewRecord(template, body)
+	if !ok || op.ID != "operation-xxxxx-yyyyy-0001" || op.Status != StatusRunning || IsFinal(op.Status) {
+		t.Fatalf("Test failed: unexpected record %v", op)
+	}
+	if _, ok := NewRecord(template, map[string]interface{}{"name": "n"}); ok {
+		t.Fatalf("Test failed: body without selfLink recorded")
+	}
+
+	se, err := sqlengine.NewSQLEngine(sqlengine.NewSQLEngineConfig(dto.RuntimeCtx{}))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := se.OperationStorePut(op); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	body["status"] = "DONE"
+	body["error"] = map[string]interface{}{"errors": []interface{}{map[string]interface{}{"code": "QUOTA_EXCEEDED", "message": "quota exceeded"}}}
+	op, _ = NewRecord(op, body)
+	if op.Status != StatusFailed || op.Error != "QUOTA_EXCEEDED: quota exceeded" {
+		t.Fatalf("Test failed: unexpected record %v", op)
+	}
+	if err := se.OperationStorePut(op); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	ops, err := se.OperationStoreGet("operation-xxxxx-yyyyy-0001")
+	if err != nil || len(ops) != 1 {
+		t.Fatalf("Test failed: %d operations, err = %v", len(ops), err)
+	}
+	if ops[0].Status != StatusFailed || ops[0].Principal != template.Principal {
+		t.Fatalf("Test failed: unexpected stored record %v", ops[0])
+	}
+	pending, err := se.OperationStoreGetPending([]string{StatusDone, StatusFailed})
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("Test failed: %d pending operations, err = %v", len(pending), err)
+	}
+}
+
+// operationServer stands in for the compute API, counting polls
+// of an operation which completes.
+type operationServer struct {
+	mu    sync.Mutex
+	polls int
+}
+
+func (s *operationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Host == "oauth2.googleapis.com":
+		fmt.Fprint(w, testobjects.GoogleAuthTokenResponse)
+	case r.Method == "GET" && r.URL.Path == "/compute/v1/projects/p/global/operations/op-1":
+		s.mu.Lock()
+		s.polls++
+		s.mu.Unlock()
+		fmt.Fprint(w, `{"name": "op-1", "status": "DONE", "selfLink": "https://www.googleapis.com/compute/v1/projects/p/global/operations/op-1"}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *operationServer) getPolls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls
+}
+
+func TestRefresher(t *testing.T) {
+	server := &operationServer{}
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(server, nil)
+	provider.DummyAuth = true
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+	handlerCtx.RuntimeContext.OperationsRefresh = 1
+	op := dto.OperationRecord{ID: "op-1", SelfLink: "https://www.googleapis.com/compute/v1/projects/p/global/operations/op-1", Provider: "google", Status: StatusRunning}
+	if err := handlerCtx.SQLEngine.OperationStorePut(op); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	authCtx, err := handlerCtx.GetAuthContext("google")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	authBefore := *authCtx
+
+	// recorded or replayed http must come in the order of the statements
+	handlerCtx.RuntimeContext.HTTPReplayDir = t.TempDir()
+	StartRefresher(handlerCtx)()
+	time.Sleep(100 * time.Millisecond)
+	if server.getPolls() != 0 {
+		t.Fatalf("Test failed: operations refreshed while replaying http")
+	}
+	handlerCtx.RuntimeContext.HTTPReplayDir = ""
+
+	// refreshes wait for the statement underway
+	release := Exclusive()
+	stop := StartRefresher(handlerCtx)
+	defer stop()
+	time.Sleep(100 * time.Millisecond)
+	if server.getPolls() != 0 {
+		t.Fatalf("Test failed: operations refreshed during a statement")
+	}
+	release()
+	for deadline := time.Now().Add(5 * time.Second); server.getPolls() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Test failed: operations not refreshed after the statement")
+		}
+	}
+	defer Exclusive()()
+	refreshed, err := Get(handlerCtx, "op-1")
+	if err != nil || refreshed.Status != StatusDone {
+		t.Fatalf("Test failed: refreshed operation %v, err = %v", refreshed, err)
+	}
+	if !reflect.DeepEqual(*authCtx, authBefore) {
+		t.Fatalf("Test failed: refresh altered the shared auth context")
+	}
+}
+
+func TestRefresherExpiresGoneOperations(t *testing.T) {
+	server := &operationServer{}
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(server, nil)
+	provider.DummyAuth = true
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+	handlerCtx.RuntimeContext.OperationsRefresh = 1
+	// the server knows nothing of the operation, as after google expires it
+	op := dto.OperationRecord{ID: "op-gone", SelfLink: "https://www.googleapis.com/compute/v1/projects/p/global/operations/op-gone", Provider: "google", Status: StatusRunning}
+	if err := handlerCtx.SQLEngine.OperationStorePut(op); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	stop := StartRefresher(handlerCtx)
+	defer stop()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		release := Exclusive()
+		refreshed, err := Get(handlerCtx, "op-gone")
+		release()
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		if refreshed.Status == StatusExpired {
+			if !IsFinal(refreshed.Status) || refreshed.Error == "" {
+				t.Fatalf("Test failed: unexpected expired operation %v", refreshed)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Test failed: operation unknown to the server left as %v", refreshed)
+		}
+	}
+	defer Exclusive()()
+	pending, err := handlerCtx.SQLEngine.OperationStoreGetPending([]string{StatusDone, StatusFailed, StatusExpired})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	for _, p := range pending {
+		if p.ID == "op-gone" {
+			t.Fatalf("Test failed: expired operation still pending refresh")
+		}
+	}
+}
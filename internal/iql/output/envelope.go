@@ -0,0 +1,102 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"infraql/internal/iql/constants"
+	"infraql/internal/iql/dto"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	EnvelopeStatusOK     string = "OK"
+	EnvelopeStatusFailed string = "FAILED"
+)
+
+// Envelope carries the results of every statement of a script, so that
+// they may be written as one document rather than one per statement.
+type Envelope struct {
+	Statements []EnvelopeEntry `json:"statements" yaml:"statements"`
+}
+
+type EnvelopeEntry struct {
+	Statement  string                   `json:"statement" yaml:"statement"`
+	Status     string                   `json:"status" yaml:"status"`
+	RowCount   int                      `json:"row_count" yaml:"row_count"`
+	DurationMs int64                    `json:"duration_ms" yaml:"duration_ms"`
+	Messages   []string                 `json:"messages" yaml:"messages"`
+	Error      string                   `json:"error,omitempty" yaml:"error,omitempty"`
+	Outfile    string                   `json:"outfile,omitempty" yaml:"outfile,omitempty"`
+	Rows       []map[string]interface{} `json:"rows" yaml:"rows"`
+}
+
+// IsEnvelopeFormat reports whether the output format can carry an envelope.
+func IsEnvelopeFormat(outputFormat string) bool {
+	switch outputFormat {
+	case constants.JsonStr, constants.NDJSONStr, constants.YAMLStr:
+		return true
+	}
+	return false
+}
+
+func NewEnvelope() *Envelope {
+	return &Envelope{
+		Statements: []EnvelopeEntry{},
+	}
+}
+
+func NewEnvelopeEntry(statement string, response dto.ExecutorOutput, duration time.Duration) EnvelopeEntry {
+	entry := EnvelopeEntry{
+		Statement:  strings.TrimSpace(statement),
+		Status:     EnvelopeStatusOK,
+		DurationMs: duration.Milliseconds(),
+		Messages:   []string{},
+		Rows:       []map[string]interface{}{},
+	}
+	if response.Msg != nil {
+		entry.Messages = append(entry.Messages, response.Msg.WorkingMessages...)
+	}
+	if response.Err != nil {
+		entry.Status = EnvelopeStatusFailed
+		entry.Error = response.Err.Error()
+		return entry
+	}
+	if response.Result != nil {
+		for _, row := range response.Result.Rows {
+			entry.Rows = append(entry.Rows, GetTypedRow(response.Result.Fields, row))
+		}
+		entry.RowCount = len(entry.Rows)
+	}
+	return entry
+}
+
+func (e *Envelope) Add(entry EnvelopeEntry) {
+	e.Statements = append(e.Statements, entry)
+}
+
+// Write writes the envelope in the output format; ndjson as a single line.
+func (e *Envelope) Write(writer io.Writer, outputFormat string) error {
+	var b []byte
+	var err error
+	switch outputFormat {
+	case constants.JsonStr:
+		b, err = json.Marshal(e)
+	case constants.NDJSONStr:
+		b, err = json.Marshal(e)
+		b = append(b, '\n')
+	case constants.YAMLStr:
+		b, err = yaml.Marshal(e)
+	default:
+		return fmt.Errorf("output envelope not supported for output format = '%s', must be (json | ndjson | yaml)", outputFormat)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(b)
+	return err
+}
@@ -0,0 +1,265 @@
+package output
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"infraql/internal/iql/constants"
+
+	_ "github.com/infraql/go-sqlite3"
+	"github.com/xitongsys/parquet-go/parquet"
+	parquetwriter "github.com/xitongsys/parquet-go/writer"
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+const (
+	sqliteResultsTable    string = "results"
+	parquetColumnNamesKey string = "infraql.column_names"
+)
+
+// parquet column names are written into comma separated key=value
+// metadata, so anything beyond word characters is replaced.
+var parquetUnsafeNameChars *regexp.Regexp = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// ParquetWriter writes a parquet file, typed as per the result fields.
+// Columns named other than by word characters, eg: json_extract(x, '$.a'),
+// are given safe names, the originals being kept in the file's key value
+// metadata under "infraql.column_names", in column order.
+type ParquetWriter struct {
+	AbstractTabularWriter
+	writer    io.Writer
+	errWriter io.Writer
+}
+
+// SQLiteWriter writes a table to a standalone sqlite file, being the
+// outfile; a table already present is kept and the next free name used,
+// so that the results of several statements may share a file.
+type SQLiteWriter struct {
+	AbstractTabularWriter
+	writer    io.Writer
+	errWriter io.Writer
+}
+
+// getColumnTypes returns the type of each column, per its field, unless
+// some value does not parse as such, in which case the column is text.
+func getColumnTypes(res *sqltypes.Result) []querypb.Type {
+	retVal := make([]querypb.Type, len(res.Fields))
+	for i, f := range res.Fields {
+		switch f.Type {
+		case querypb.Type_BIT, querypb.Type_INT64, querypb.Type_FLOAT64, querypb.Type_JSON:
+			retVal[i] = f.Type
+		default:
+			retVal[i] = querypb.Type_TEXT
+			continue
+		}
+		// a JSON string decodes to other than itself, invalid JSON does not
+		for _, row := range res.Rows {
+			if s, isStr := getTypedValue(f, row[i]).(string); isStr && (f.Type != querypb.Type_JSON || s == row[i].ToString()) {
+				retVal[i] = querypb.Type_TEXT
+				break
+			}
+		}
+	}
+	return retVal
+}
+
+// getCellValue returns the value as a string, or nil for null.
+func getCellValue(field *querypb.Field, val sqltypes.Value) *string {
+	if getTypedValue(field, val) == nil {
+		return nil
+	}
+	s := val.ToString()
+	return &s
+}
+
+func getParquetMetadata(name string, columnType querypb.Type) string {
+	var typeStr string
+	switch columnType {
+	case querypb.Type_BIT:
+		typeStr = "type=BOOLEAN"
+	case querypb.Type_INT64:
+		typeStr = "type=INT64"
+	case querypb.Type_FLOAT64:
+		typeStr = "type=DOUBLE"
+	case querypb.Type_JSON:
+		typeStr = "type=BYTE_ARRAY, convertedtype=JSON"
+	default:
+		typeStr = "type=BYTE_ARRAY, convertedtype=UTF8"
+	}
+	return fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", name, typeStr)
+}
+
+// getParquetColumnNames returns a safe and distinct name for each
+// column; names differing only in case are not distinct to parquet-go.
+func getParquetColumnNames(fields []*querypb.Field) []string {
+	retVal := make([]string, len(fields))
+	used := make(map[string]bool)
+	for i, f := range fields {
+		name := strings.Trim(parquetUnsafeNameChars.ReplaceAllString(f.Name, "_"), "_")
+		if name == "" {
+			name = fmt.Sprintf("col_%d", i+1)
+		}
+		candidate := name
+		for n := 2; used[strings.ToLower(candidate)]; n++ {
+			candidate = fmt.Sprintf("%s_%d", name, n)
+		}
+		used[strings.ToLower(candidate)] = true
+		retVal[i] = candidate
+	}
+	return retVal
+}
+
+func (pw *ParquetWriter) writeTable(fields []*querypb.Field, columnTypes []querypb.Type, rows [][]sqltypes.Value) error {
+	md := make([]string, len(fields))
+	originalNames := make([]string, len(fields))
+	for i, name := range getParquetColumnNames(fields) {
+		md[i] = getParquetMetadata(name, columnTypes[i])
+		originalNames[i] = fields[i].Name
+	}
+	w, err := parquetwriter.NewCSVWriterFromWriter(md, pw.writer, 1)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(originalNames)
+	if err != nil {
+		return err
+	}
+	namesStr := string(b)
+	w.Footer.KeyValueMetadata = append(w.Footer.KeyValueMetadata, &parquet.KeyValue{Key: parquetColumnNamesKey, Value: &namesStr})
+	for _, row := range rows {
+		rec := make([]*string, len(fields))
+		for j, f := range fields {
+			rec[j] = getCellValue(f, row[j])
+		}
+		if err := w.WriteString(rec); err != nil {
+			return err
+		}
+	}
+	return w.WriteStop()
+}
+
+func (pw *ParquetWriter) Write(res *sqltypes.Result) error {
+	return pw.writeTable(res.Fields, getColumnTypes(res), res.Rows)
+}
+
+func (pw *ParquetWriter) WriteError(err error, errorPresentation string) error {
+	if errorPresentation == stderrPressentationStr {
+		return writeStderrError(pw.errWriter, err)
+	}
+	return pw.writeTable(
+		[]*querypb.Field{{Name: errorKey, Type: querypb.Type_TEXT}},
+		[]querypb.Type{querypb.Type_TEXT},
+		[][]sqltypes.Value{{sqltypes.NewVarChar(err.Error())}},
+	)
+}
+
+func getSQLiteType(columnType querypb.Type) string {
+	switch columnType {
+	case querypb.Type_BIT:
+		return "boolean"
+	case querypb.Type_INT64:
+		return "integer"
+	case querypb.Type_FLOAT64:
+		return "real"
+	default:
+		return "text"
+	}
+}
+
+func (sw *SQLiteWriter) getFilePath() (string, error) {
+	filePath := sw.outputCtx.RuntimeContext.OutfilePath
+	switch filePath {
+	case "", "stdout", "stderr":
+		return "", fmt.Errorf("%s output requires an outfile, eg: -f results.db", constants.SQLiteStr)
+	}
+	return filePath, nil
+}
+
+func (sw *SQLiteWriter) getFreeTableName(db *sql.DB, tableName string) (string, error) {
+	for i := 1; ; i++ {
+		candidate := tableName
+		if i > 1 {
+			candidate = fmt.Sprintf("%s_%d", tableName, i)
+		}
+		var count int
+		if err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, candidate).Scan(&count); err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+}
+
+func (sw *SQLiteWriter) writeTable(fields []*querypb.Field, columnTypes []querypb.Type, rows [][]sqltypes.Value) error {
+	filePath, err := sw.getFilePath()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", filePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tableName, err := sw.getFreeTableName(db, sqliteResultsTable)
+	if err != nil {
+		return err
+	}
+	colDefs := make([]string, len(fields))
+	placeholders := make([]string, len(fields))
+	for i, f := range fields {
+		colDefs[i] = fmt.Sprintf(`"%s" %s`, f.Name, getSQLiteType(columnTypes[i]))
+		placeholders[i] = "?"
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := txn.Exec(fmt.Sprintf(`CREATE TABLE "%s" ( %s )`, tableName, strings.Join(colDefs, ", "))); err != nil {
+		txn.Rollback()
+		return err
+	}
+	stmt, err := txn.Prepare(fmt.Sprintf(`INSERT INTO "%s" VALUES ( %s )`, tableName, strings.Join(placeholders, ", ")))
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		varArgs := make([]interface{}, len(fields))
+		for j, f := range fields {
+			v := getTypedValue(f, row[j])
+			if columnTypes[j] == querypb.Type_JSON || columnTypes[j] == querypb.Type_TEXT {
+				if s := getCellValue(f, row[j]); s != nil {
+					v = *s
+				}
+			}
+			varArgs[j] = v
+		}
+		if _, err := stmt.Exec(varArgs...); err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (sw *SQLiteWriter) Write(res *sqltypes.Result) error {
+	return sw.writeTable(res.Fields, getColumnTypes(res), res.Rows)
+}
+
+func (sw *SQLiteWriter) WriteError(err error, errorPresentation string) error {
+	if errorPresentation == stderrPressentationStr {
+		return writeStderrError(sw.errWriter, err)
+	}
+	return sw.writeTable(
+		[]*querypb.Field{{Name: errorKey, Type: querypb.Type_TEXT}},
+		[]querypb.Type{querypb.Type_TEXT},
+		[][]sqltypes.Value{{sqltypes.NewVarChar(err.Error())}},
+	)
+}
@@ -0,0 +1,169 @@
+package output_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"infraql/internal/iql/dto"
+	. "infraql/internal/iql/output"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func getTypedTestResult() *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "name", Type: querypb.Type_TEXT},
+			{Name: "autoCreateSubnetworks", Type: querypb.Type_BIT},
+			{Name: "mtu", Type: querypb.Type_INT64},
+			{Name: "routingConfig", Type: querypb.Type_JSON},
+			{Name: "description", Type: querypb.Type_INT64},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.NewVarChar("net-1"),
+				sqltypes.NewVarChar("true"),
+				sqltypes.NewVarChar("1460"),
+				sqltypes.NewVarChar(`{"routingMode":"REGIONAL"}`),
+				sqltypes.NewVarChar("not a number"),
+			},
+			{
+				sqltypes.NewVarChar("net-2"),
+				sqltypes.NewVarChar("false"),
+				sqltypes.NewVarChar("null"),
+				sqltypes.NewVarChar(`{"routingMode":"GLOBAL"}`),
+				sqltypes.NewVarChar("null"),
+			},
+		},
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	var out bytes.Buffer
+	w, err := GetOutputWriter(&out, nil, dto.OutputContext{RuntimeContext: dto.RuntimeCtx{OutputFormat: "parquet"}})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := w.Write(getTypedTestResult()); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	pf, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	pr, err := reader.NewParquetReader(pf, nil, 1)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	defer pr.ReadStop()
+	if pr.GetNumRows() != 2 {
+		t.Fatalf("Test failed: expected 2 rows, got %d", pr.GetNumRows())
+	}
+	expectedTypes := []parquet.Type{
+		parquet.Type_BYTE_ARRAY,
+		parquet.Type_BOOLEAN,
+		parquet.Type_INT64,
+		parquet.Type_BYTE_ARRAY,
+		parquet.Type_BYTE_ARRAY,
+	}
+	// the first schema element is the root
+	for i, expected := range expectedTypes {
+		if actual := pr.Footer.Schema[i+1].GetType(); actual != expected {
+			t.Fatalf("Test failed: column %d expected type %v, got %v", i, expected, actual)
+		}
+	}
+	if pr.Footer.Schema[4].GetConvertedType() != parquet.ConvertedType_JSON {
+		t.Fatalf("Test failed: expected JSON converted type for routingConfig")
+	}
+}
+
+func TestParquetWriterColumnNames(t *testing.T) {
+	var out bytes.Buffer
+	w, err := GetOutputWriter(&out, nil, dto.OutputContext{RuntimeContext: dto.RuntimeCtx{OutputFormat: "parquet"}})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	res := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "json_extract(x, '$.a')", Type: querypb.Type_TEXT},
+			{Name: "json_extract_x_a", Type: querypb.Type_TEXT},
+			{Name: "count(*)", Type: querypb.Type_INT64},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewVarChar("a"), sqltypes.NewVarChar("b"), sqltypes.NewVarChar("1")},
+		},
+	}
+	if err := w.Write(res); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	pf, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	pr, err := reader.NewParquetReader(pf, nil, 1)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	defer pr.ReadStop()
+	expectedNames := []string{"json_extract_x_a", "json_extract_x_a_2", "count"}
+	for i, expected := range expectedNames {
+		if actual := pr.SchemaHandler.GetExName(i + 1); actual != expected {
+			t.Fatalf("Test failed: column %d expected name '%s', got '%s'", i, expected, actual)
+		}
+	}
+	var originalNames []string
+	for _, kv := range pr.Footer.KeyValueMetadata {
+		if kv.Key == "infraql.column_names" {
+			json.Unmarshal([]byte(kv.GetValue()), &originalNames)
+		}
+	}
+	if len(originalNames) != 3 || originalNames[0] != "json_extract(x, '$.a')" || originalNames[2] != "count(*)" {
+		t.Fatalf("Test failed: original column names not kept, got %v", originalNames)
+	}
+}
+
+func TestSQLiteWriter(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "results.db")
+	w, err := GetOutputWriter(nil, nil, dto.OutputContext{RuntimeContext: dto.RuntimeCtx{OutputFormat: "sqlite", OutfilePath: filePath}})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.Write(getTypedTestResult()); err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+	}
+	db, err := sql.Open("sqlite3", filePath)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	defer db.Close()
+	for _, table := range []string{"results", "results_2"} {
+		var mtuType, descriptionType string
+		if err := db.QueryRow(`SELECT typeof("mtu"), typeof("description") FROM "`+table+`" WHERE "name" = 'net-1'`).Scan(&mtuType, &descriptionType); err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		if mtuType != "integer" || descriptionType != "text" {
+			t.Fatalf("Test failed: unexpected column types '%s', '%s'", mtuType, descriptionType)
+		}
+	}
+	var mtu sql.NullInt64
+	var routingConfig string
+	if err := db.QueryRow(`SELECT "mtu", "routingConfig" FROM "results" WHERE "name" = 'net-2'`).Scan(&mtu, &routingConfig); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if mtu.Valid || routingConfig != `{"routingMode":"GLOBAL"}` {
+		t.Fatalf("Test failed: unexpected values %v, '%s'", mtu, routingConfig)
+	}
+	stdoutWriter, _ := GetOutputWriter(nil, nil, dto.OutputContext{RuntimeContext: dto.RuntimeCtx{OutputFormat: "sqlite", OutfilePath: "stdout"}})
+	if err := stdoutWriter.Write(getTypedTestResult()); err == nil {
+		t.Fatalf("Test failed: expected error writing sqlite to stdout")
+	}
+}
@@ -10,11 +10,14 @@ import (
 	"infraql/internal/iql/iqlutil"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
 )
 
 const (
@@ -93,6 +96,51 @@ func GetOutputWriter(writer io.Writer, errWriter io.Writer, outputCtx dto.Output
 			errWriter,
 		}
 		return &prettyWriter, nil
+	case constants.YAMLStr:
+		yamlWriter := YAMLWriter{
+			AbstractTabularWriter{
+				outputCtx: outputCtx,
+			},
+			writer,
+			errWriter,
+		}
+		return &yamlWriter, nil
+	case constants.NDJSONStr:
+		ndjsonWriter := NDJSONWriter{
+			AbstractTabularWriter{
+				outputCtx: outputCtx,
+			},
+			writer,
+			errWriter,
+		}
+		return &ndjsonWriter, nil
+	case constants.MarkdownStr:
+		markdownWriter := MarkdownWriter{
+			AbstractTabularWriter{
+				outputCtx: outputCtx,
+			},
+			writer,
+			errWriter,
+		}
+		return &markdownWriter, nil
+	case constants.ParquetStr:
+		parquetWriter := ParquetWriter{
+			AbstractTabularWriter{
+				outputCtx: outputCtx,
+			},
+			writer,
+			errWriter,
+		}
+		return &parquetWriter, nil
+	case constants.SQLiteStr:
+		sqliteWriter := SQLiteWriter{
+			AbstractTabularWriter{
+				outputCtx: outputCtx,
+			},
+			writer,
+			errWriter,
+		}
+		return &sqliteWriter, nil
 	}
 	return nil, fmt.Errorf("unable to create output writer for output format = '%s'", outputCtx.RuntimeContext.OutputFormat)
 }
@@ -131,14 +179,71 @@ type PrettyWriter struct {
 	errWriter io.Writer
 }
 
+// YAMLWriter writes a sequence of rows, each a mapping in column order.
+type YAMLWriter struct {
+	AbstractTabularWriter
+	writer    io.Writer
+	errWriter io.Writer
+}
+
+// NDJSONWriter writes one JSON object per row, per line, as each row is
+// processed, so that output may be consumed by line oriented tools.
+type NDJSONWriter struct {
+	AbstractTabularWriter
+	writer    io.Writer
+	errWriter io.Writer
+}
+
+// MarkdownWriter writes a GitHub flavoured markdown table.
+type MarkdownWriter struct {
+	AbstractTabularWriter
+	writer    io.Writer
+	errWriter io.Writer
+}
+
+// getTypedValue returns the value as typed by its field, falling back to
+// the string representation where the value does not parse as such.
+func getTypedValue(field *querypb.Field, val sqltypes.Value) interface{} {
+	s := val.ToString()
+	if val.IsNull() || s == "null" {
+		return nil
+	}
+	switch field.Type {
+	case querypb.Type_BIT:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case querypb.Type_INT64:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case querypb.Type_FLOAT64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case querypb.Type_JSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			return v
+		}
+	}
+	return s
+}
+
+// GetTypedRow returns the row keyed by field name, with values typed as
+// per their fields, eg: nested objects as such rather than as JSON strings.
+func GetTypedRow(fields []*querypb.Field, row []sqltypes.Value) map[string]interface{} {
+	rowMap := make(map[string]interface{}, len(fields))
+	for j, f := range fields {
+		rowMap[f.Name] = getTypedValue(f, row[j])
+	}
+	return rowMap
+}
+
 func (jw *JsonWriter) writeRowsFromResult(res *sqltypes.Result) error {
 	rows := make([]map[string]interface{}, len(res.Rows))
 	for i, row := range res.Rows {
-		rowMap := make(map[string]interface{})
-		for j, s := range res.Fields {
-			rowMap[s.Name] = row[j].ToString()
-		}
-		rows[i] = rowMap
+		rows[i] = GetTypedRow(res.Fields, row)
 	}
 	return jw.writeRows(rows)
 }
@@ -338,3 +443,113 @@ func (tw *TableWriter) WriteError(err error, errorPresentation string) error {
 	table.Render()
 	return nil
 }
+
+func (yw *YAMLWriter) writeRows(rows []yaml.MapSlice) error {
+	yamlBytes, err := yaml.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	_, err = yw.writer.Write(yamlBytes)
+	return err
+}
+
+func (yw *YAMLWriter) Write(res *sqltypes.Result) error {
+	header := yw.getHeader(res)
+	rows := make([]yaml.MapSlice, len(res.Rows))
+	for i, v := range res.Rows {
+		row := make(yaml.MapSlice, len(header))
+		for j, c := range v {
+			row[j] = yaml.MapItem{Key: header[j], Value: c.ToString()}
+		}
+		rows[i] = row
+	}
+	return yw.writeRows(rows)
+}
+
+func (yw *YAMLWriter) WriteError(err error, errorPresentation string) error {
+	if errorPresentation == stderrPressentationStr {
+		return writeStderrError(yw.errWriter, err)
+	}
+	return yw.writeRows(
+		[]yaml.MapSlice{
+			{
+				{Key: errorKey, Value: err.Error()},
+			},
+		},
+	)
+}
+
+func (nw *NDJSONWriter) writeRow(row map[string]interface{}) error {
+	jsonBytes, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = nw.writer.Write(append(jsonBytes, '\n'))
+	return err
+}
+
+func (nw *NDJSONWriter) Write(res *sqltypes.Result) error {
+	for _, v := range res.Rows {
+		if err := nw.writeRow(GetTypedRow(res.Fields, v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (nw *NDJSONWriter) WriteError(err error, errorPresentation string) error {
+	if errorPresentation == stderrPressentationStr {
+		return writeStderrError(nw.errWriter, err)
+	}
+	return nw.writeRow(
+		map[string]interface{}{
+			errorKey: err.Error(),
+		},
+	)
+}
+
+var markdownCellReplacer *strings.Replacer = strings.NewReplacer(
+	"|", "\\|",
+	"\r\n", "<br>",
+	"\n", "<br>",
+)
+
+func (mw *MarkdownWriter) writeRow(cells []string) {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		escaped[i] = markdownCellReplacer.Replace(c)
+	}
+	mw.writer.Write([]byte(fmt.Sprintf("| %s |%s", strings.Join(escaped, " | "), fmt.Sprintln(""))))
+}
+
+func (mw *MarkdownWriter) writeTable(header []string, rows [][]string) error {
+	mw.writeRow(header)
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	mw.writer.Write([]byte(fmt.Sprintf("|%s|%s", strings.Join(separator, "|"), fmt.Sprintln(""))))
+	for _, row := range rows {
+		mw.writeRow(row)
+	}
+	return nil
+}
+
+func (mw *MarkdownWriter) Write(res *sqltypes.Result) error {
+	rows := make([][]string, len(res.Rows))
+	for i, v := range res.Rows {
+		rowSlice := make([]string, len(v))
+		for j, c := range v {
+			rowSlice[j] = c.ToString()
+		}
+		rows[i] = rowSlice
+	}
+	return mw.writeTable(mw.getHeader(res), rows)
+}
+
+func (mw *MarkdownWriter) WriteError(err error, errorPresentation string) error {
+	if errorPresentation == stderrPressentationStr {
+		return writeStderrError(mw.errWriter, err)
+	}
+	return mw.writeTable([]string{errorKey}, [][]string{{err.Error()}})
+}
@@ -0,0 +1,140 @@
+package output_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"infraql/internal/iql/dto"
+	. "infraql/internal/iql/output"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func getTestResult() *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "description", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewVarChar("net-1"), sqltypes.NewVarChar("a | b")},
+			{sqltypes.NewVarChar("net-2"), sqltypes.NewVarChar("line1\nline2")},
+		},
+	}
+}
+
+func writeTestResult(t *testing.T, format string) (string, string) {
+	var out, errOut bytes.Buffer
+	w, err := GetOutputWriter(&out, &errOut, dto.OutputContext{RuntimeContext: dto.RuntimeCtx{OutputFormat: format}})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := w.Write(getTestResult()); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	expectedOut := out.String()
+	out.Reset()
+	if err := w.WriteError(errors.New("something broke"), "stderr"); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if out.Len() != 0 || errOut.String() != "something broke\n" {
+		t.Fatalf("Test failed: stderr error presentation wrote '%s' to stdout and '%s' to stderr", out.String(), errOut.String())
+	}
+	if err := w.WriteError(errors.New("something broke"), "record"); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return expectedOut, out.String()
+}
+
+func TestYAMLWriter(t *testing.T) {
+	out, errOut := writeTestResult(t, "yaml")
+	expected := "- name: net-1\n  description: a | b\n- name: net-2\n  description: |-\n    line1\n    line2\n"
+	if out != expected {
+		t.Fatalf("Test failed: expected '%s', got '%s'", expected, out)
+	}
+	if errOut != "- error: something broke\n" {
+		t.Fatalf("Test failed: unexpected error output '%s'", errOut)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	out, errOut := writeTestResult(t, "ndjson")
+	expected := "{\"description\":\"a | b\",\"name\":\"net-1\"}\n{\"description\":\"line1\\nline2\",\"name\":\"net-2\"}\n"
+	if out != expected {
+		t.Fatalf("Test failed: expected '%s', got '%s'", expected, out)
+	}
+	if errOut != "{\"error\":\"something broke\"}\n" {
+		t.Fatalf("Test failed: unexpected error output '%s'", errOut)
+	}
+}
+
+func TestMarkdownWriter(t *testing.T) {
+	out, errOut := writeTestResult(t, "markdown")
+	expected := "| name | description |\n|---|---|\n| net-1 | a \\| b |\n| net-2 | line1<br>line2 |\n"
+	if out != expected {
+		t.Fatalf("Test failed: expected '%s', got '%s'", expected, out)
+	}
+	if errOut != "| error |\n|---|\n| something broke |\n" {
+		t.Fatalf("Test failed: unexpected error output '%s'", errOut)
+	}
+}
+
+func TestJsonWriterTypedValues(t *testing.T) {
+	res := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "name", Type: querypb.Type_TEXT},
+			{Name: "autoCreateSubnetworks", Type: querypb.Type_BIT},
+			{Name: "mtu", Type: querypb.Type_INT64},
+			{Name: "ratio", Type: querypb.Type_FLOAT64},
+			{Name: "routingConfig", Type: querypb.Type_JSON},
+			{Name: "subnetworks", Type: querypb.Type_JSON},
+			{Name: "description", Type: querypb.Type_TEXT},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.NewVarChar("net-1"),
+				sqltypes.NewVarChar("true"),
+				sqltypes.NewVarChar("1460"),
+				sqltypes.NewVarChar("0.5"),
+				sqltypes.NewVarChar(`{"routingMode":"REGIONAL"}`),
+				sqltypes.NewVarChar(`["a","b"]`),
+				sqltypes.NewVarChar("null"),
+			},
+		},
+	}
+	var out bytes.Buffer
+	w, err := GetOutputWriter(&out, nil, dto.OutputContext{RuntimeContext: dto.RuntimeCtx{OutputFormat: "json"}})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := w.Write(res); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	expected := `[{"autoCreateSubnetworks":true,"description":null,"mtu":1460,"name":"net-1","ratio":0.5,"routingConfig":{"routingMode":"REGIONAL"},"subnetworks":["a","b"]}]`
+	if out.String() != expected {
+		t.Fatalf("Test failed: expected '%s', got '%s'", expected, out.String())
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	envelope := NewEnvelope()
+	envelope.Add(NewEnvelopeEntry(" select name from google.compute.networks ", dto.NewExecutorOutput(getTestResult(), nil, &dto.BackendMessages{WorkingMessages: []string{"fetched"}}, nil), 1500*time.Millisecond))
+	envelope.Add(NewEnvelopeEntry("select name from google.compute.nonesuch", dto.NewExecutorOutput(nil, nil, nil, errors.New("no such resource")), 0))
+	var out bytes.Buffer
+	if err := envelope.Write(&out, "json"); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	expected := `{"statements":[` +
+		`{"statement":"select name from google.compute.networks","status":"OK","row_count":2,"duration_ms":1500,"messages":["fetched"],"rows":[{"description":"a | b","name":"net-1"},{"description":"line1\nline2","name":"net-2"}]},` +
+		`{"statement":"select name from google.compute.nonesuch","status":"FAILED","row_count":0,"duration_ms":0,"messages":[],"error":"no such resource","rows":[]}` +
+		`]}`
+	if out.String() != expected {
+		t.Fatalf("Test failed: expected '%s', got '%s'", expected, out.String())
+	}
+	if err := envelope.Write(&out, "table"); err == nil {
+		t.Fatalf("Test failed: expected error writing envelope as table")
+	}
+}
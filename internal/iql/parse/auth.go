@@ -0,0 +1,43 @@
+package parse
+
+import (
+	"regexp"
+	"strings"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+const (
+	AuthADCStr         string = "adc"
+	AuthImpersonateStr string = "impersonate"
+)
+
+// The underlying grammar only knows the interactive and service account
+// auth types, so AUTH LOGIN statements naming any other are recognised here.
+var extendedAuthLoginRegex *regexp.Regexp = regexp.MustCompile(`(?is)^\s*(infraql\s+)?auth\s+login\s+([a-z0-9_]+)\s+(adc)\s*;?\s*$`)
+
+var impersonateRegex *regexp.Regexp = regexp.MustCompile(`(?is)^\s*(infraql\s+)?auth\s+(?:login\s+)?([a-z0-9_]+)\s+impersonate\s+'([^']*)'\s*;?\s*$`)
+
+// ParseExtendedAuth returns a non-nil statement iff the command is
+// an AUTH LOGIN of a type unknown to the underlying grammar, or an
+// AUTH ... IMPERSONATE, for which the Auth node's only free text
+// field, KeyFilePath, carries the principal to impersonate.
+func ParseExtendedAuth(cmd string) (sqlparser.Statement, error) {
+	if matches := impersonateRegex.FindStringSubmatch(cmd); matches != nil {
+		return &sqlparser.Auth{
+			SessionAuth: sqlparser.BoolVal(matches[1] != ""),
+			Provider:    matches[2],
+			Type:        AuthImpersonateStr,
+			KeyFilePath: matches[3],
+		}, nil
+	}
+	matches := extendedAuthLoginRegex.FindStringSubmatch(cmd)
+	if matches == nil {
+		return nil, nil
+	}
+	return &sqlparser.Auth{
+		SessionAuth: sqlparser.BoolVal(matches[1] != ""),
+		Provider:    matches[2],
+		Type:        strings.ToLower(matches[3]),
+	}, nil
+}
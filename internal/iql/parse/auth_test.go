@@ -0,0 +1,35 @@
+package parse_test
+
+import (
+	"testing"
+
+	. "infraql/internal/iql/parse"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func TestParseExtendedAuth(t *testing.T) {
+	stmt, err := ParseExtendedAuth("AUTH LOGIN google ADC;")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	auth, ok := stmt.(*sqlparser.Auth)
+	if !ok {
+		t.Fatalf("Test failed: AUTH LOGIN ADC not recognised")
+	}
+	if auth.Provider != "google" || auth.Type != AuthADCStr {
+		t.Fatalf("Test failed: unexpected provider = '%s', type = '%s'", auth.Provider, auth.Type)
+	}
+	stmt, err = ParseExtendedAuth("AUTH google IMPERSONATE 'sa@project.iam.gserviceaccount.com'")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	auth, ok = stmt.(*sqlparser.Auth)
+	if !ok || auth.Type != AuthImpersonateStr || auth.KeyFilePath != "sa@project.iam.gserviceaccount.com" {
+		t.Fatalf("Test failed: AUTH IMPERSONATE not recognised")
+	}
+	stmt, err = ParseExtendedAuth("AUTH LOGIN google interactive")
+	if err != nil || stmt != nil {
+		t.Fatalf("Test failed: grammar auth type unexpectedly intercepted")
+	}
+}
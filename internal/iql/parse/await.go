@@ -0,0 +1,23 @@
+package parse
+
+import (
+	"regexp"
+)
+
+// AWAIT OPERATION is unknown to the underlying grammar, so is recognised
+// here, after any leading comments, eg: /*+ AWAIT(timeout=600) */.
+var awaitOperationRegex *regexp.Regexp = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*await\s+operation\s+'([^']+)'\s*;?\s*$`)
+
+type AwaitOperation struct {
+	ID string
+}
+
+// ParseAwaitOperation returns a non-nil result iff the command
+// is of the form AWAIT OPERATION '<id or selfLink>'.
+func ParseAwaitOperation(cmd string) *AwaitOperation {
+	matches := awaitOperationRegex.FindStringSubmatch(cmd)
+	if matches == nil {
+		return nil
+	}
+	return &AwaitOperation{ID: matches[1]}
+}
@@ -0,0 +1,21 @@
+package parse_test
+
+import (
+	"testing"
+
+	. "infraql/internal/iql/parse"
+)
+
+func TestParseAwaitOperation(t *testing.T) {
+	node := ParseAwaitOperation("AWAIT OPERATION 'operation-xxxxx-yyyyy-0001';")
+	if node == nil || node.ID != "operation-xxxxx-yyyyy-0001" {
+		t.Fatalf("Test failed: AWAIT OPERATION not recognised")
+	}
+	node = ParseAwaitOperation("/*+ AWAIT(timeout=600) */ AWAIT OPERATION 'operation-xxxxx-yyyyy-0001'")
+	if node == nil || node.ID != "operation-xxxxx-yyyyy-0001" {
+		t.Fatalf("Test failed: AWAIT OPERATION with leading directive not recognised")
+	}
+	if ParseAwaitOperation("/*+ AWAIT */ INSERT INTO google.compute.networks(project) SELECT 'p'") != nil {
+		t.Fatalf("Test failed: AWAIT directive mistaken for AWAIT OPERATION")
+	}
+}
@@ -0,0 +1,70 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+const (
+	CreateTableStr string = "table"
+	CreateViewStr  string = "view"
+)
+
+// The underlying grammar only partially parses CREATE ... AS SELECT,
+// discarding the query body, so these statements are recognised up front.
+var createAsSelectRegex *regexp.Regexp = regexp.MustCompile("(?is)^\\s*create\\s+(table|view)\\s+(if\\s+not\\s+exists\\s+)?([a-z0-9_`.]+)\\s+as\\s+(.+?)\\s*;?\\s*$")
+
+type CreateAsSelect struct {
+	Kind        string
+	IfNotExists bool
+	Table       sqlparser.TableName
+	Body        string
+	Select      *sqlparser.Select
+}
+
+func parseTableName(name string) (sqlparser.TableName, error) {
+	parts := strings.Split(strings.ReplaceAll(name, "`", ""), ".")
+	for _, p := range parts {
+		if p == "" {
+			return sqlparser.TableName{}, fmt.Errorf("invalid table name '%s'", name)
+		}
+	}
+	switch len(parts) {
+	case 1:
+		return sqlparser.TableName{Name: sqlparser.NewTableIdent(parts[0])}, nil
+	case 2:
+		return sqlparser.TableName{Qualifier: sqlparser.NewTableIdent(parts[0]), Name: sqlparser.NewTableIdent(parts[1])}, nil
+	}
+	return sqlparser.TableName{}, fmt.Errorf("table name '%s' must have at most two parts", name)
+}
+
+// ParseCreateAsSelect returns a non-nil result iff the command is of the
+// form CREATE {TABLE|VIEW} [IF NOT EXISTS] name AS SELECT ...
+func ParseCreateAsSelect(cmd string) (*CreateAsSelect, error) {
+	matches := createAsSelectRegex.FindStringSubmatch(cmd)
+	if matches == nil {
+		return nil, nil
+	}
+	tn, err := parseTableName(matches[3])
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := ParseQuery(matches[4])
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return nil, fmt.Errorf("CREATE %s AS requires a SELECT statement, got %T", strings.ToUpper(matches[1]), stmt)
+	}
+	return &CreateAsSelect{
+		Kind:        strings.ToLower(matches[1]),
+		IfNotExists: matches[2] != "",
+		Table:       tn,
+		Body:        matches[4],
+		Select:      sel,
+	}, nil
+}
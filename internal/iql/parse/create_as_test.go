@@ -0,0 +1,30 @@
+package parse_test
+
+import (
+	"testing"
+
+	. "infraql/internal/iql/parse"
+)
+
+func TestParseCreateAsSelect(t *testing.T) {
+	cas, err := ParseCreateAsSelect("CREATE TABLE IF NOT EXISTS local.my_inventory AS SELECT id, name FROM google.compute.instances WHERE project = 'p' AND zone = 'z';")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if cas == nil {
+		t.Fatalf("Test failed: CREATE TABLE AS SELECT not recognised")
+	}
+	if cas.Kind != CreateTableStr || !cas.IfNotExists {
+		t.Fatalf("Test failed: unexpected kind = '%s', if not exists = %v", cas.Kind, cas.IfNotExists)
+	}
+	if cas.Table.Qualifier.GetRawVal() != "local" || cas.Table.Name.GetRawVal() != "my_inventory" {
+		t.Fatalf("Test failed: unexpected table name '%s.%s'", cas.Table.Qualifier.GetRawVal(), cas.Table.Name.GetRawVal())
+	}
+	if cas.Select == nil || len(cas.Select.SelectExprs) != 2 {
+		t.Fatalf("Test failed: select body not parsed")
+	}
+	cas, err = ParseCreateAsSelect("SELECT id FROM google.compute.instances WHERE project = 'p' AND zone = 'z'")
+	if err != nil || cas != nil {
+		t.Fatalf("Test failed: plain SELECT unexpectedly recognised")
+	}
+}
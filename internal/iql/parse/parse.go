@@ -3,11 +3,15 @@ package parse
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"vitess.io/vitess/go/vt/sqlparser"
 )
 
+// The underlying grammar knows SHOW PLAN_CACHE, as a single word, only.
+var showPlanCacheRegex *regexp.Regexp = regexp.MustCompile(`(?i)^(\s*show\s+(?:extended\s+)?)plan\s+cache\b`)
+
 func specialiseParserError(err error, cmd string) error {
 	if err != nil {
 		if strings.Count(cmd, ".") > 1 {
@@ -30,6 +34,6 @@ func specialiseParserError(err error, cmd string) error {
 }
 
 func ParseQuery(cmd string) (sqlparser.Statement, error) {
-	statement, err := sqlparser.Parse(cmd)
+	statement, err := sqlparser.Parse(showPlanCacheRegex.ReplaceAllString(cmd, "${1}PLAN_CACHE"))
 	return statement, specialiseParserError(err, cmd)
 }
@@ -0,0 +1,24 @@
+package parse_test
+
+import (
+	"strings"
+	"testing"
+
+	. "infraql/internal/iql/parse"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func TestParseShowPlanCache(t *testing.T) {
+	stmt, err := ParseQuery("SHOW PLAN CACHE LIKE '%networks%';")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	show, ok := stmt.(*sqlparser.Show)
+	if !ok || strings.ToUpper(show.Type) != "PLAN_CACHE" {
+		t.Fatalf("Test failed: SHOW PLAN CACHE not recognised")
+	}
+	if show.ShowTablesOpt == nil || show.ShowTablesOpt.Filter == nil || show.ShowTablesOpt.Filter.Like != "%networks%" {
+		t.Fatalf("Test failed: SHOW PLAN CACHE filter not parsed")
+	}
+}
@@ -0,0 +1,180 @@
+package parserutil
+
+import (
+	"regexp"
+	"strings"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// FunctionDirective represents a comment directive of the form
+// NAME(arg, key=val, ...), eg: /*+ WATCH(60, key=id) */.
+// The bare form NAME is also accepted, with no arguments.
+type FunctionDirective struct {
+	Name   string
+	Args   []string
+	Params map[string]string
+}
+
+func (fd *FunctionDirective) GetParam(key string) (string, bool) {
+	v, ok := fd.Params[key]
+	return v, ok
+}
+
+func (fd *FunctionDirective) GetArg(idx int) (string, bool) {
+	if idx < 0 || idx >= len(fd.Args) {
+		return "", false
+	}
+	return fd.Args[idx], true
+}
+
+func GetStatementComments(stmt sqlparser.Statement) sqlparser.Comments {
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select:
+		return stmt.Comments
+	case *sqlparser.Insert:
+		return stmt.Comments
+	case *sqlparser.Delete:
+		return stmt.Comments
+	case *sqlparser.Exec:
+		return stmt.Comments
+	case *sqlparser.Show:
+		return stmt.Comments
+	}
+	return nil
+}
+
+func ExtractFunctionDirective(comments sqlparser.Comments, name string) (*FunctionDirective, bool) {
+	for _, comment := range comments {
+		body := string(comment)
+		if !strings.HasPrefix(body, "/*+") || !strings.HasSuffix(body, "*/") {
+			continue
+		}
+		body = strings.TrimSpace(body[3 : len(body)-2])
+		if fd, ok := extractFunctionDirectiveFromString(body, name); ok {
+			return fd, true
+		}
+	}
+	return nil, false
+}
+
+var blockCommentRegex *regexp.Regexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// ExtractQueryFunctionDirective finds the directive in the comments of the
+// statement, eg: SELECT /*+ OUTPUT('x.csv') */ ..., or in those preceding
+// it, which are also the only ones found for statements that sqlparser
+// does not parse, eg: AWAIT OPERATION.
+func ExtractQueryFunctionDirective(query string, name string) (*FunctionDirective, bool) {
+	if stmt, err := sqlparser.Parse(query); err == nil {
+		if fd, ok := ExtractFunctionDirective(GetStatementComments(stmt), name); ok {
+			return fd, true
+		}
+	}
+	_, marginComments := sqlparser.SplitMarginComments(query)
+	var comments sqlparser.Comments
+	for _, c := range blockCommentRegex.FindAllString(marginComments.Leading, -1) {
+		comments = append(comments, []byte(c))
+	}
+	return ExtractFunctionDirective(comments, name)
+}
+
+func extractFunctionDirectiveFromString(body string, name string) (*FunctionDirective, bool) {
+	upperBody := strings.ToUpper(body)
+	upperName := strings.ToUpper(name)
+	offset := 0
+	for {
+		idx := strings.Index(upperBody[offset:], upperName)
+		if idx < 0 {
+			return nil, false
+		}
+		start := offset + idx
+		end := start + len(upperName)
+		offset = end
+		if start > 0 && !isDirectiveSeparator(body[start-1]) {
+			continue
+		}
+		if end == len(body) || isDirectiveSeparator(body[end]) {
+			return &FunctionDirective{Name: upperName, Params: make(map[string]string)}, true
+		}
+		if body[end] != '(' {
+			continue
+		}
+		closeIdx := findClosingParen(body, end)
+		if closeIdx < 0 {
+			return nil, false
+		}
+		return parseFunctionDirectiveArgs(upperName, body[end+1:closeIdx]), true
+	}
+}
+
+func isDirectiveSeparator(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func findClosingParen(s string, openIdx int) int {
+	var quote byte
+	for i := openIdx + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ')':
+			return i
+		}
+	}
+	return -1
+}
+
+func splitDirectiveArgs(s string) []string {
+	var retVal []string
+	var quote byte
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			sb.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			sb.WriteByte(c)
+		case c == ',':
+			retVal = append(retVal, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if tail := strings.TrimSpace(sb.String()); tail != "" || len(retVal) > 0 {
+		retVal = append(retVal, tail)
+	}
+	return retVal
+}
+
+func unquoteDirectiveValue(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parseFunctionDirectiveArgs(name string, argStr string) *FunctionDirective {
+	retVal := &FunctionDirective{Name: name, Params: make(map[string]string)}
+	for _, arg := range splitDirectiveArgs(argStr) {
+		eqIdx := strings.Index(arg, "=")
+		quoteIdx := strings.IndexAny(arg, `'"`)
+		if eqIdx > 0 && (quoteIdx < 0 || eqIdx < quoteIdx) {
+			retVal.Params[strings.ToLower(strings.TrimSpace(arg[:eqIdx]))] = unquoteDirectiveValue(arg[eqIdx+1:])
+			continue
+		}
+		retVal.Args = append(retVal.Args, unquoteDirectiveValue(arg))
+	}
+	return retVal
+}
@@ -0,0 +1,56 @@
+package parserutil_test
+
+import (
+	"testing"
+
+	. "infraql/internal/iql/parserutil"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func TestExtractFunctionDirective(t *testing.T) {
+	stmt, err := sqlparser.Parse(`select /*+ SHOWRESULTS WATCH(30, key='id|zone') */ id from google.compute.instances where project = 'p' and zone = 'z'`)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	directive, ok := ExtractFunctionDirective(GetStatementComments(stmt), "WATCH")
+	if !ok {
+		t.Fatalf("Test failed: WATCH directive not found")
+	}
+	if arg, _ := directive.GetArg(0); arg != "30" {
+		t.Fatalf("Test failed: arg 0 = '%s', expected '30'", arg)
+	}
+	if key, _ := directive.GetParam("key"); key != "id|zone" {
+		t.Fatalf("Test failed: param key = '%s', expected 'id|zone'", key)
+	}
+	if _, ok := ExtractFunctionDirective(GetStatementComments(stmt), "AWAIT"); ok {
+		t.Fatalf("Test failed: AWAIT directive unexpectedly found")
+	}
+	bare, ok := ExtractFunctionDirective(GetStatementComments(stmt), "SHOWRESULTS")
+	if !ok || len(bare.Args) != 0 {
+		t.Fatalf("Test failed: bare SHOWRESULTS directive not extracted")
+	}
+}
+
+func TestExtractQueryFunctionDirective(t *testing.T) {
+	queries := []string{
+		`select /*+ OUTPUT(file='instances.csv', format='csv') */ id from google.compute.instances where project = 'p' and zone = 'z'`,
+		`/* inventory */ /*+ OUTPUT(file='instances.csv', format='csv') */ select id from google.compute.instances where project = 'p' and zone = 'z'`,
+		`/*+ OUTPUT(file='instances.csv', format='csv') */ AWAIT OPERATION 'operation-123'`,
+	}
+	for _, q := range queries {
+		directive, ok := ExtractQueryFunctionDirective(q, "OUTPUT")
+		if !ok {
+			t.Fatalf("Test failed: OUTPUT directive not found in '%s'", q)
+		}
+		if file, _ := directive.GetParam("file"); file != "instances.csv" {
+			t.Fatalf("Test failed: param file = '%s', expected 'instances.csv'", file)
+		}
+		if format, _ := directive.GetParam("format"); format != "csv" {
+			t.Fatalf("Test failed: param format = '%s', expected 'csv'", format)
+		}
+	}
+	if _, ok := ExtractQueryFunctionDirective(`select id from google.compute.instances where project = 'p' and zone = 'z'`, "OUTPUT"); ok {
+		t.Fatalf("Test failed: OUTPUT directive unexpectedly found")
+	}
+}
@@ -185,6 +185,22 @@ func ExtractInsertValColumns(insStmt *sqlparser.Insert) (map[int]map[int]interfa
 	return nil, nonValCount, err
 }
 
+// IsInsertFromQuery distinguishes INSERT ... SELECT over actual tables
+// from the static, table-less "SELECT <literals>" form.
+func IsInsertFromQuery(insStmt *sqlparser.Insert) bool {
+	sel, ok := insStmt.Rows.(*sqlparser.Select)
+	if !ok {
+		return false
+	}
+	for _, te := range sel.From {
+		tn, err := GetTableNameFromTableExpr(te)
+		if err != nil || tn.Name.GetRawVal() != "dual" || !tn.Qualifier.IsEmpty() {
+			return true
+		}
+	}
+	return false
+}
+
 func ExtractWhereColNames(statement *sqlparser.Where) ([]string, error) {
 	var whereNames []string
 	var err error
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"infraql/internal/iql/drm"
 	"infraql/internal/iql/dto"
 	"io"
@@ -17,6 +18,7 @@ type IPrimitiveCtx interface {
 	GetWriter() io.Writer
 	GetErrWriter() io.Writer
 	GetCommentDirectives() sqlparser.CommentDirectives
+	GetContext() context.Context
 }
 
 type IPrimitive interface {
@@ -32,13 +34,65 @@ type Plan struct {
 	Original               string                  // Original is the original query.
 	Instructions           IPrimitive              // Instructions contains the instructions needed to fulfil the query.
 	sqlparser.BindVarNeeds                         // Stores BindVars needed to be provided as part of expression rewriting
+	Scopes                 []string                // Scopes are the OAuth scopes sufficient for the API calls the plan makes; nil for the defaults.
 
-	mu           sync.Mutex    // Mutex to protect the fields below
-	ExecCount    uint64        // Count of times this plan was executed
-	ExecTime     time.Duration // Total execution time
-	ShardQueries uint64        // Total number of shard queries
-	Rows         uint64        // Total number of rows
-	Errors       uint64        // Total number of errors
+	mu            sync.Mutex    // Mutex to protect the fields below
+	ExecCount     uint64        // Count of times this plan was executed
+	ExecTime      time.Duration // Total execution time
+	ShardQueries  uint64        // Total number of shard queries
+	Rows          uint64        // Total number of rows
+	Errors        uint64        // Total number of errors
+	HTTPCalls     uint64        // Total number of http requests, including retries
+	Pages         uint64        // Total number of pages fetched
+	BytesReceived uint64        // Total number of bytes received in http responses
+	RowsInserted  uint64        // Total number of rows inserted by the DRM
+	Retries       uint64        // Total number of http requests retried
+}
+
+// Stats is a copy of the accumulated stats of a plan.
+type Stats struct {
+	ExecCount     uint64
+	ExecTime      time.Duration
+	Rows          uint64
+	Errors        uint64
+	HTTPCalls     uint64
+	Pages         uint64
+	BytesReceived uint64
+	RowsInserted  uint64
+	Retries       uint64
+}
+
+// AddStats accumulates the stats of an execution of the plan.
+func (p *Plan) AddStats(execTime time.Duration, rows int, err error, queryStats dto.QueryStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ExecCount++
+	p.ExecTime += execTime
+	p.Rows += uint64(rows)
+	if err != nil {
+		p.Errors++
+	}
+	p.HTTPCalls += queryStats.HTTPCalls
+	p.Pages += queryStats.Pages
+	p.BytesReceived += queryStats.BytesReceived
+	p.RowsInserted += queryStats.RowsInserted
+	p.Retries += queryStats.Retries
+}
+
+func (p *Plan) GetStats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		ExecCount:     p.ExecCount,
+		ExecTime:      p.ExecTime,
+		Rows:          p.Rows,
+		Errors:        p.Errors,
+		HTTPCalls:     p.HTTPCalls,
+		Pages:         p.Pages,
+		BytesReceived: p.BytesReceived,
+		RowsInserted:  p.RowsInserted,
+		Retries:       p.Retries,
+	}
 }
 
 // Size is defined so that Plan can be given to a cache.LRUCache,
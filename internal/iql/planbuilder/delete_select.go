@@ -0,0 +1,266 @@
+package planbuilder
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/httpbuild"
+	"infraql/internal/iql/metadata"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/taxonomy"
+	"infraql/internal/iql/util"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// A DELETE whose WHERE clause is anything other than the delete method's
+// parameters is executed by first selecting the matching rows.  Nothing is
+// deleted if more rows match than the LIMIT, which defaults to
+// defaultDeleteRowLimit unless the statement carries a CONFIRM directive.
+const (
+	confirmDirectiveStr   string = "CONFIRM"
+	defaultDeleteRowLimit int    = 1
+	unlimitedRowLimit     int    = -1
+	selfLinkStr           string = "selfLink"
+)
+
+// getEqualityParams returns the "column = literal" conjuncts of
+// the WHERE clause, and whether there are no other predicates.
+func getEqualityParams(where *sqlparser.Where) (map[string]string, bool) {
+	retVal := make(map[string]string)
+	if where == nil {
+		return retVal, true
+	}
+	isPure := true
+	var visit func(expr sqlparser.Expr)
+	visit = func(expr sqlparser.Expr) {
+		switch expr := expr.(type) {
+		case *sqlparser.AndExpr:
+			visit(expr.Left)
+			visit(expr.Right)
+			return
+		case *sqlparser.ComparisonExpr:
+			col, isCol := expr.Left.(*sqlparser.ColName)
+			val, isVal := expr.Right.(*sqlparser.SQLVal)
+			if expr.Operator == sqlparser.EqualStr && isCol && isVal {
+				retVal[col.Name.GetRawVal()] = string(val.Val)
+				return
+			}
+		}
+		isPure = false
+	}
+	visit(where.Expr)
+	return retVal, isPure
+}
+
+func isDirectDelete(method *metadata.Method, node *sqlparser.Delete) bool {
+	params, isPure := getEqualityParams(node.Where)
+	if !isPure || node.Limit != nil {
+		return false
+	}
+	for k := range params {
+		if _, ok := method.Parameters[k]; !ok {
+			return false
+		}
+	}
+	for k := range method.GetRequiredParameters() {
+		if _, ok := params[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func getDeleteRowLimit(node *sqlparser.Delete, isConfirmed bool) (int, error) {
+	if node.Limit == nil {
+		if isConfirmed {
+			return unlimitedRowLimit, nil
+		}
+		return defaultDeleteRowLimit, nil
+	}
+	if node.Limit.Offset != nil {
+		return 0, fmt.Errorf("DELETE does not support LIMIT with an offset")
+	}
+	val, ok := node.Limit.Rowcount.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return 0, fmt.Errorf("DELETE LIMIT must be an integer")
+	}
+	return strconv.Atoi(string(val.Val))
+}
+
+// getSelfLinkParams matches the path of a resource's selfLink against the
+// delete method's path template, eg: projects/{project}/zones/{zone}/instances/{instance},
+// yielding the value of each parameter in the template.
+func getSelfLinkParams(method *metadata.Method, selfLink string) map[string]string {
+	retVal := make(map[string]string)
+	u, err := url.Parse(selfLink)
+	if err != nil || method.Path == "" {
+		return retVal
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	templateSegments := strings.Split(strings.Trim(method.Path, "/"), "/")
+	offset := len(segments) - len(templateSegments)
+	if offset < 0 {
+		return retVal
+	}
+	params := make(map[string]string)
+	for i, ts := range templateSegments {
+		seg := segments[offset+i]
+		if strings.HasPrefix(ts, "{") && strings.HasSuffix(ts, "}") {
+			name := ts[1 : len(ts)-1]
+			if strings.HasPrefix(name, "+") {
+				return retVal
+			}
+			if v, err := url.PathUnescape(seg); err == nil {
+				seg = v
+			}
+			params[name] = seg
+			continue
+		}
+		if ts != seg {
+			return retVal
+		}
+	}
+	return params
+}
+
+// getDeleteRowParams maps a selected row onto the delete method's required
+// parameters: from values fixed in the WHERE clause, else from the row's
+// selfLink, else from a column of the same name.  A parameter mapped by
+// none of these is an error.
+func getDeleteRowParams(method *metadata.Method, whereParams map[string]string, fields []*querypb.Field, row []sqltypes.Value) (map[string]string, error) {
+	rowVals := make(map[string]string)
+	for i, f := range fields {
+		if i < len(row) && !row[i].IsNull() && row[i].ToString() != "null" {
+			rowVals[f.Name] = row[i].ToString()
+		}
+	}
+	selfLinkParams := getSelfLinkParams(method, rowVals[selfLinkStr])
+	retVal := make(map[string]string)
+	var unresolved []string
+	for k := range method.GetRequiredParameters() {
+		if v, ok := whereParams[k]; ok {
+			retVal[k] = v
+			continue
+		}
+		if v, ok := selfLinkParams[k]; ok && v != "" {
+			retVal[k] = v
+			continue
+		}
+		if v, ok := rowVals[k]; ok && v != "" {
+			// eg: zone is returned as a fully qualified url
+			if strings.HasPrefix(v, "http") {
+				v = v[strings.LastIndex(v, "/")+1:]
+			}
+			retVal[k] = v
+			continue
+		}
+		unresolved = append(unresolved, k)
+	}
+	if len(unresolved) > 0 {
+		sort.Strings(unresolved)
+		return nil, fmt.Errorf("cannot map selected row to delete parameters: %s; supply them in the WHERE clause", strings.Join(unresolved, ", "))
+	}
+	return retVal, nil
+}
+
+func getDeleteRowNode(node *sqlparser.Delete, params map[string]string) *sqlparser.Delete {
+	var keys []string
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var expr sqlparser.Expr
+	for _, k := range keys {
+		comparison := &sqlparser.ComparisonExpr{
+			Operator: sqlparser.EqualStr,
+			Left:     &sqlparser.ColName{Name: sqlparser.NewColIdent(k)},
+			Right:    sqlparser.NewStrVal([]byte(params[k])),
+		}
+		if expr == nil {
+			expr = comparison
+			continue
+		}
+		expr = &sqlparser.AndExpr{Left: expr, Right: comparison}
+	}
+	return &sqlparser.Delete{
+		Comments:   node.Comments,
+		TableExprs: node.TableExprs,
+		Where:      sqlparser.NewWhere(sqlparser.WhereStr, expr),
+	}
+}
+
+// buildDeleteSource selects the rows to delete.  The selfLink, from which
+// the delete parameters are mapped, is an output only field and so not
+// among those selected by '*'; resources without one are selected by '*'.
+func buildDeleteSource(handlerCtx *handler.HandlerContext, node *sqlparser.Delete) (plan.IPrimitive, error) {
+	source, _, err := buildSelect(handlerCtx, &sqlparser.Select{
+		SelectExprs: sqlparser.SelectExprs{&sqlparser.AliasedExpr{Expr: &sqlparser.ColName{Name: sqlparser.NewColIdent(selfLinkStr)}}},
+		From:        node.TableExprs,
+		Where:       node.Where,
+	})
+	if err == nil {
+		return source, nil
+	}
+	source, _, err = buildSelect(handlerCtx, &sqlparser.Select{
+		SelectExprs: sqlparser.SelectExprs{&sqlparser.StarExpr{}},
+		From:        node.TableExprs,
+		Where:       node.Where,
+	})
+	return source, err
+}
+
+func handleDeleteFromQuery(handlerCtx *handler.HandlerContext, node *sqlparser.Delete, heirarchy *taxonomy.HeirarchyObjects) (plan.IPrimitive, error) {
+	primitiveGenerator := newPrimitiveGenerator(node, handlerCtx)
+	primitiveGenerator.parseComments(node.Comments)
+	isConfirmed := primitiveGenerator.PrimitiveBuilder.GetCommentDirectives() != nil && primitiveGenerator.PrimitiveBuilder.GetCommentDirectives().IsSet(confirmDirectiveStr)
+	rowLimit, err := getDeleteRowLimit(node, isConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	prov := heirarchy.Provider
+	method := heirarchy.Method
+	schemaMap, err := prov.GetSchemaMap(heirarchy.HeirarchyIds.ServiceStr, heirarchy.HeirarchyIds.ResourceStr)
+	if err != nil {
+		return nil, err
+	}
+	tbl := taxonomy.NewExtendedTableMetadata(heirarchy)
+	whereParams, _ := getEqualityParams(node.Where)
+	source, err := buildDeleteSource(handlerCtx, node)
+	if err != nil {
+		return nil, err
+	}
+	return wrapSourcePrimitive(
+		source,
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			output := source.Execute(pc)
+			if output.Err != nil {
+				return output
+			}
+			if output.Result == nil || len(output.Result.Rows) == 0 {
+				return messageOutput("no rows match, nothing deleted")
+			}
+			if rowLimit != unlimitedRowLimit && len(output.Result.Rows) > rowLimit {
+				return util.GenerateSimpleErroneousOutput(fmt.Errorf("DELETE matches %d rows, exceeding the limit of %d; nothing deleted, raise the LIMIT or add a /*+ %s */ directive to proceed", len(output.Result.Rows), rowLimit, confirmDirectiveStr))
+			}
+			if err := primeAuth(handlerCtx, prov); err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			fields := output.Result.Fields
+			rows := output.Result.Rows
+			return primitiveGenerator.executeRowRequests(handlerCtx, pc, tbl, prov, len(rows), func(idx int) (*httpbuild.HTTPArmoury, error) {
+				params, err := getDeleteRowParams(method, whereParams, fields, rows[idx])
+				if err != nil {
+					return nil, err
+				}
+				return httpbuild.BuildHTTPRequestCtx(handlerCtx, getDeleteRowNode(node, params), prov, method, schemaMap, nil, nil)
+			})
+		}), nil
+}
@@ -0,0 +1,202 @@
+package planbuilder_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/iqlmodel"
+	"infraql/internal/iql/metadata"
+	. "infraql/internal/iql/planbuilder"
+	"infraql/internal/iql/provider"
+	"infraql/internal/test/infraqltestutil"
+	"infraql/internal/test/testhttpapi"
+	"infraql/internal/test/testobjects"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func getInstanceDeleteMethod() *metadata.Method {
+	return &metadata.Method{
+		Path: "projects/{project}/zones/{zone}/instances/{instance}",
+		Parameters: map[string]iqlmodel.Parameter{
+			"project":   {Required: true},
+			"zone":      {Required: true},
+			"instance":  {Required: true},
+			"requestId": {},
+		},
+	}
+}
+
+func parseDelete(t *testing.T, query string) *sqlparser.Delete {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return stmt.(*sqlparser.Delete)
+}
+
+func TestGetEqualityParams(t *testing.T) {
+	params, isPure := GetEqualityParams(parseDelete(t, "delete from google.compute.instances where project = 'p' and zone = 'z'").Where)
+	if !isPure || !reflect.DeepEqual(params, map[string]string{"project": "p", "zone": "z"}) {
+		t.Fatalf("Test failed: params = %v, pure = %v", params, isPure)
+	}
+	params, isPure = GetEqualityParams(parseDelete(t, "delete from google.compute.instances where project = 'p' and name like 'web%'").Where)
+	if isPure || !reflect.DeepEqual(params, map[string]string{"project": "p"}) {
+		t.Fatalf("Test failed: params = %v, pure = %v", params, isPure)
+	}
+	params, isPure = GetEqualityParams(nil)
+	if !isPure || len(params) != 0 {
+		t.Fatalf("Test failed: params = %v, pure = %v", params, isPure)
+	}
+}
+
+func TestIsDirectDelete(t *testing.T) {
+	method := getInstanceDeleteMethod()
+	for query, expected := range map[string]bool{
+		"delete from google.compute.instances where project = 'p' and zone = 'z' and instance = 'i'":                     true,
+		"delete from google.compute.instances where project = 'p' and zone = 'z' and instance = 'i' and requestId = 'r'": true,
+		"delete from google.compute.instances where project = 'p' and zone = 'z'":                                        false,
+		"delete from google.compute.instances where project = 'p' and zone = 'z' and name = 'i'":                         false,
+		"delete from google.compute.instances where project = 'p' and zone = 'z' and instance like 'i%'":                 false,
+		"delete from google.compute.instances where project = 'p' and zone = 'z' and instance = 'i' limit 1":             false,
+	} {
+		if actual := IsDirectDelete(method, parseDelete(t, query)); actual != expected {
+			t.Fatalf("Test failed: IsDirectDelete('%s') = %v, expected %v", query, actual, expected)
+		}
+	}
+}
+
+func TestGetDeleteRowLimit(t *testing.T) {
+	node := parseDelete(t, "delete from google.compute.instances where project = 'p' and zone = 'z' and name like 'web%'")
+	limit, err := GetDeleteRowLimit(node, false)
+	if err != nil || limit != 1 {
+		t.Fatalf("Test failed: default limit = %d, %v", limit, err)
+	}
+	limit, err = GetDeleteRowLimit(node, true)
+	if err != nil || limit != -1 {
+		t.Fatalf("Test failed: confirmed limit = %d, %v", limit, err)
+	}
+	limited := parseDelete(t, "delete from google.compute.instances where project = 'p' and zone = 'z' and name like 'web%' limit 5")
+	for _, isConfirmed := range []bool{false, true} {
+		limit, err = GetDeleteRowLimit(limited, isConfirmed)
+		if err != nil || limit != 5 {
+			t.Fatalf("Test failed: explicit limit = %d, %v", limit, err)
+		}
+	}
+	if _, err := GetDeleteRowLimit(parseDelete(t, "delete from google.compute.instances where project = 'p' limit 1, 5"), false); err == nil {
+		t.Fatalf("Test failed: expected error for LIMIT with an offset")
+	}
+}
+
+func TestGetDeleteRowParams(t *testing.T) {
+	method := getInstanceDeleteMethod()
+	fields := []*querypb.Field{{Name: "selfLink"}, {Name: "name"}}
+	row := []sqltypes.Value{
+		sqltypes.NewVarChar("https://www.googleapis.com/compute/v1/projects/p/zones/z/instances/web-1"),
+		sqltypes.NewVarChar("web-1"),
+	}
+	params, err := GetDeleteRowParams(method, map[string]string{"project": "p"}, fields, row)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if !reflect.DeepEqual(params, map[string]string{"project": "p", "zone": "z", "instance": "web-1"}) {
+		t.Fatalf("Test failed: unexpected params %v", params)
+	}
+
+	// columns of the parameter's name are used absent a selfLink
+	fields = []*querypb.Field{{Name: "zone"}, {Name: "instance"}}
+	row = []sqltypes.Value{
+		sqltypes.NewVarChar("https://www.googleapis.com/compute/v1/projects/p/zones/z"),
+		sqltypes.NewVarChar("web-2"),
+	}
+	params, err = GetDeleteRowParams(method, map[string]string{"project": "p"}, fields, row)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if !reflect.DeepEqual(params, map[string]string{"project": "p", "zone": "z", "instance": "web-2"}) {
+		t.Fatalf("Test failed: unexpected params %v", params)
+	}
+
+	// the resource name is not guessed from an unrelated column
+	fields = []*querypb.Field{{Name: "name"}}
+	row = []sqltypes.Value{sqltypes.NewVarChar("web-3")}
+	_, err = GetDeleteRowParams(method, map[string]string{"project": "p", "zone": "z"}, fields, row)
+	if err == nil || !strings.Contains(err.Error(), "instance") {
+		t.Fatalf("Test failed: expected unmapped parameter error, got %v", err)
+	}
+}
+
+// networkDeleteServer stands in for the compute API, listing
+// networks and recording those deleted.
+type networkDeleteServer struct {
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (s *networkDeleteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const networksPath = "/compute/v1/projects/testing-project/global/networks"
+	switch {
+	case r.Host == "oauth2.googleapis.com":
+		fmt.Fprint(w, testobjects.GoogleAuthTokenResponse)
+	case r.Method == "GET" && r.URL.Path == networksPath:
+		var items []string
+		for _, name := range []string{"web-1", "web-2", "db-1"} {
+			items = append(items, fmt.Sprintf(`{"name": "%s", "selfLink": "https://www.googleapis.com/compute/v1/projects/testing-project/global/networks/%s"}`, name, name))
+		}
+		fmt.Fprintf(w, `{"kind": "compute#networkList", "items": [%s]}`, strings.Join(items, ", "))
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, networksPath+"/"):
+		name := strings.TrimPrefix(r.URL.Path, networksPath+"/")
+		s.mu.Lock()
+		s.deleted = append(s.deleted, name)
+		s.mu.Unlock()
+		fmt.Fprintf(w, `{"kind": "compute#operation", "name": "op-%s", "operationType": "delete", "status": "DONE", "targetLink": "https://www.googleapis.com/compute/v1/projects/testing-project/global/networks/%s"}`, name, name)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestDeleteFromQuery(t *testing.T) {
+	server := &networkDeleteServer{}
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(server, nil)
+	provider.DummyAuth = true
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+	handlerCtx.RuntimeContext.HTTPBatchMaxParts = 0
+
+	execute := func(query string) dto.ExecutorOutput {
+		handlerCtx.Query = query
+		pl, err := BuildPlanFromContext(handlerCtx)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		return pl.Instructions.Execute(dto.NewBasicPrimitiveContext(nil, nil, handlerCtx.Outfile, handlerCtx.OutErrFile, nil))
+	}
+
+	output := execute("DELETE FROM google.compute.networks WHERE project = 'testing-project' AND name LIKE 'web-%';")
+	if output.Err == nil || !strings.Contains(output.Err.Error(), "exceeding the limit of 1") {
+		t.Fatalf("Test failed: expected the row limit to block the delete, got %v", output.Err)
+	}
+	if len(server.deleted) != 0 {
+		t.Fatalf("Test failed: networks deleted despite the row limit: %v", server.deleted)
+	}
+
+	output = execute("DELETE /*+ CONFIRM */ FROM google.compute.networks WHERE project = 'testing-project' AND name LIKE 'web-%';")
+	if output.Err != nil {
+		t.Fatalf("Test failed: %v", output.Err)
+	}
+	server.mu.Lock()
+	deleted := append([]string{}, server.deleted...)
+	server.mu.Unlock()
+	if len(deleted) != 2 || !((deleted[0] == "web-1" && deleted[1] == "web-2") || (deleted[0] == "web-2" && deleted[1] == "web-1")) {
+		t.Fatalf("Test failed: expected web-1 and web-2 deleted, got %v", deleted)
+	}
+	if output.Result == nil || len(output.Result.Rows) != 2 {
+		t.Fatalf("Test failed: expected a result row per deleted network, got %v", output.Result)
+	}
+}
@@ -0,0 +1,9 @@
+package planbuilder
+
+// The delete select helpers are exported to the planbuilder_test package.
+var (
+	GetEqualityParams  = getEqualityParams
+	IsDirectDelete     = isDirectDelete
+	GetDeleteRowLimit  = getDeleteRowLimit
+	GetDeleteRowParams = getDeleteRowParams
+)
@@ -0,0 +1,114 @@
+package planbuilder
+
+import (
+	"fmt"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/httpbuild"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/primitivebuilder"
+	"infraql/internal/iql/util"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func handleInsertFromQuery(handlerCtx *handler.HandlerContext, node *sqlparser.Insert) (plan.IPrimitive, error) {
+	primitiveGenerator := newPrimitiveGenerator(node, handlerCtx)
+	err := primitiveGenerator.analyzeStatement(handlerCtx, node)
+	if err != nil {
+		return nil, err
+	}
+	source, _, err := buildSelect(handlerCtx, node.Rows.(*sqlparser.Select))
+	if err != nil {
+		return nil, err
+	}
+	return primitiveGenerator.insertFromQueryExecutor(handlerCtx, node, source)
+}
+
+func (pb *primitiveGenerator) insertFromQueryExecutor(handlerCtx *handler.HandlerContext, node *sqlparser.Insert, source plan.IPrimitive) (plan.IPrimitive, error) {
+	tbl, err := pb.PrimitiveBuilder.GetTable(node)
+	if err != nil {
+		return nil, err
+	}
+	prov, err := tbl.GetProvider()
+	if err != nil {
+		return nil, err
+	}
+	m, err := tbl.GetMethod()
+	if err != nil {
+		return nil, err
+	}
+	schemaMap := pb.PrimitiveBuilder.GetInsertSchemaMap()
+	ex := func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+		output := source.Execute(pc)
+		if output.Err != nil {
+			return output
+		}
+		if output.Result == nil || len(output.Result.Rows) == 0 {
+			return messageOutput("source query returned no rows, nothing inserted")
+		}
+		rowNode := *node
+		if len(rowNode.Columns) == 0 {
+			for _, f := range output.Result.Fields {
+				rowNode.Columns = append(rowNode.Columns, sqlparser.NewColIdent(f.Name))
+			}
+		}
+		if len(rowNode.Columns) != len(output.Result.Fields) {
+			return util.GenerateSimpleErroneousOutput(fmt.Errorf("disparity in fields to insert (%d) and columns returned by query (%d)", len(rowNode.Columns), len(output.Result.Fields)))
+		}
+		if err := primeAuth(handlerCtx, prov); err != nil {
+			return util.GenerateSimpleErroneousOutput(err)
+		}
+		rows := output.Result.Rows
+		return pb.executeRowRequests(handlerCtx, pc, tbl, prov, len(rows), func(idx int) (*httpbuild.HTTPArmoury, error) {
+			return httpbuild.BuildHTTPRequestCtx(handlerCtx, &rowNode, prov, m, schemaMap, getInsertRowValues(rows[idx]), nil)
+		})
+	}
+	return wrapSourcePrimitive(source, ex), nil
+}
+
+// insertFromValuesExecutor inserts each of many rows of VALUES with
+// a request of its own, as for rows returned by a query.
+func (pb *primitiveGenerator) insertFromValuesExecutor(handlerCtx *handler.HandlerContext, node *sqlparser.Insert) (plan.IPrimitive, error) {
+	tbl, err := pb.PrimitiveBuilder.GetTable(node)
+	if err != nil {
+		return nil, err
+	}
+	prov, err := tbl.GetProvider()
+	if err != nil {
+		return nil, err
+	}
+	m, err := tbl.GetMethod()
+	if err != nil {
+		return nil, err
+	}
+	schemaMap := pb.PrimitiveBuilder.GetInsertSchemaMap()
+	valRows := pb.PrimitiveBuilder.GetInsertValOnlyRows()
+	return primitivebuilder.NewHTTPRestPrimitive(
+		prov,
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			if err := primeAuth(handlerCtx, prov); err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			return pb.executeRowRequests(handlerCtx, pc, tbl, prov, len(valRows), func(idx int) (*httpbuild.HTTPArmoury, error) {
+				return httpbuild.BuildHTTPRequestCtx(handlerCtx, node, prov, m, schemaMap, map[int]map[int]interface{}{0: valRows[idx]}, nil)
+			})
+		},
+		nil,
+		nil,
+	), nil
+}
+
+func getInsertRowValues(row []sqltypes.Value) map[int]map[int]interface{} {
+	valRow := make(map[int]interface{})
+	for i, val := range row {
+		if val.IsNull() || val.ToString() == "null" {
+			valRow[i] = nil
+			continue
+		}
+		valRow[i] = val.ToString()
+	}
+	return map[int]map[int]interface{}{0: valRow}
+}
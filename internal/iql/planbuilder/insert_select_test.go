@@ -0,0 +1,160 @@
+package planbuilder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/localtable"
+	. "infraql/internal/iql/planbuilder"
+	"infraql/internal/iql/provider"
+	"infraql/internal/test/infraqltestutil"
+	"infraql/internal/test/testhttpapi"
+	"infraql/internal/test/testobjects"
+
+	"vitess.io/vitess/go/sqltypes"
+)
+
+const (
+	networkOperationTemplate string = `{
+		"kind": "compute#operation",
+		"name": "op-%s",
+		"operationType": "insert",
+		"status": "%s",
+		"targetLink": "https://www.googleapis.com/compute/v1/projects/testing-project/global/networks/%s",
+		"selfLink": "https://www.googleapis.com/compute/v1/projects/testing-project/global/operations/op-%s"
+	}`
+	networkInsertErrorResponse string = `{"error": {"code": 400, "message": "Invalid value for field 'resource.name': 'Bad_Net'."}}`
+)
+
+// networkInsertServer stands in for the compute API, recording the
+// greatest number of network inserts in flight at once.
+type networkInsertServer struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	inserted    []string
+	polled      map[string]int
+}
+
+func (s *networkInsertServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Host == "oauth2.googleapis.com":
+		fmt.Fprint(w, testobjects.GoogleAuthTokenResponse)
+	case r.Method == "POST" && r.URL.Path == "/compute/v1/projects/testing-project/global/networks":
+		body := make(map[string]interface{})
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		name, _ := body["name"].(string)
+		s.mu.Lock()
+		s.inFlight++
+		if s.inFlight > s.maxInFlight {
+			s.maxInFlight = s.inFlight
+		}
+		s.inserted = append(s.inserted, name)
+		s.mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+		if name == "Bad_Net" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, networkInsertErrorResponse)
+			return
+		}
+		fmt.Fprintf(w, networkOperationTemplate, name, "RUNNING", name, name)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/compute/v1/projects/testing-project/global/operations/op-"):
+		name := strings.TrimPrefix(r.URL.Path, "/compute/v1/projects/testing-project/global/operations/op-")
+		s.mu.Lock()
+		s.polled[name]++
+		s.mu.Unlock()
+		fmt.Fprintf(w, networkOperationTemplate, name, "DONE", name, name)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestInsertFromQuery(t *testing.T) {
+	server := &networkInsertServer{polled: make(map[string]int)}
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(server, nil)
+	provider.DummyAuth = true
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+	handlerCtx.RuntimeContext.ConcurrencyLimit = 2
+	handlerCtx.RuntimeContext.HTTPBatchMaxParts = 0
+
+	names := []string{"net-a", "net-b", "Bad_Net", "net-c", "net-d"}
+	err := localtable.CreateTable(handlerCtx.SQLEngine, "nets", []localtable.Column{localtable.NewColumn("name", "text")}, false)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	var rows [][]sqltypes.Value
+	for _, name := range names {
+		rows = append(rows, []sqltypes.Value{sqltypes.NewVarChar(name)})
+	}
+	if _, err := localtable.InsertRows(handlerCtx.SQLEngine, "nets", []string{"name"}, rows); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	handlerCtx.Query = "INSERT /*+ AWAIT(interval=0.05) */ INTO google.compute.networks(project, data__name) SELECT 'testing-project', name FROM local.nets ORDER BY rowid;"
+	pl, err := BuildPlanFromContext(handlerCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	output := pl.Instructions.Execute(dto.NewBasicPrimitiveContext(nil, nil, handlerCtx.Outfile, handlerCtx.OutErrFile, nil))
+	if output.Err != nil {
+		t.Fatalf("Test failed: %v", output.Err)
+	}
+
+	if len(server.inserted) != len(names) {
+		t.Fatalf("Test failed: expected %d insert requests, got %v", len(names), server.inserted)
+	}
+	if server.maxInFlight > 2 {
+		t.Fatalf("Test failed: %d requests in flight, exceeding the concurrency limit of 2", server.maxInFlight)
+	}
+	for _, name := range names {
+		if name == "Bad_Net" {
+			if server.polled[name] != 0 {
+				t.Fatalf("Test failed: failed insert of '%s' was awaited", name)
+			}
+			continue
+		}
+		if server.polled[name] == 0 {
+			t.Fatalf("Test failed: insert of '%s' was not awaited", name)
+		}
+	}
+
+	if output.Result == nil || len(output.Result.Rows) != len(names) {
+		t.Fatalf("Test failed: expected a result row per source row, got %v", output.Result)
+	}
+	colIdx := make(map[string]int)
+	for i, f := range output.Result.Fields {
+		colIdx[f.Name] = i
+	}
+	for i, row := range output.Result.Rows {
+		name := names[i]
+		if row[colIdx["row"]].ToString() != fmt.Sprintf("%d", i+1) {
+			t.Fatalf("Test failed: result row %d reports source row '%s'", i, row[colIdx["row"]].ToString())
+		}
+		status := row[colIdx["status"]].ToString()
+		errStr := row[colIdx["error"]].ToString()
+		if name == "Bad_Net" {
+			if status != "failed" || !strings.Contains(errStr, "Invalid value for field") {
+				t.Fatalf("Test failed: expected failure for '%s', got status '%s', error '%s'", name, status, errStr)
+			}
+			continue
+		}
+		if status != "succeeded" || errStr != "" {
+			t.Fatalf("Test failed: expected success for '%s', got status '%s', error '%s'", name, status, errStr)
+		}
+		if !strings.HasSuffix(row[colIdx["target"]].ToString(), "/networks/"+name) {
+			t.Fatalf("Test failed: unexpected target '%s' for '%s'", row[colIdx["target"]].ToString(), name)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package planbuilder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"infraql/internal/iql/drm"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/localtable"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/util"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// A select joining local tables with provider tables is executed by
+// selecting from each provider table, with the predicates that bind to
+// it alone, materialising the rows into a scratch table in the DRM, and
+// running the select, rewritten to read the scratch tables, in SQLite.
+
+var scratchTableCounter int64
+
+type joinedProviderTable struct {
+	ate       *sqlparser.AliasedTableExpr
+	table     sqlparser.TableName
+	alias     string
+	nullable  bool
+	conjuncts []sqlparser.Expr
+	columns   []string
+	isStar    bool
+}
+
+type joinCondition struct {
+	node  *sqlparser.JoinTableExpr
+	left  []*joinedProviderTable
+	right []*joinedProviderTable
+}
+
+type localJoinAnalyzer struct {
+	handlerCtx     *handler.HandlerContext
+	providerTables []*joinedProviderTable
+	joins          []joinCondition
+	localColumns   map[string]bool
+}
+
+// compositePrimitive executes over several provider selects, each of
+// which needs a transaction id of its own when a cached plan is reused.
+type compositePrimitive struct {
+	handlerCtx *handler.HandlerContext
+	sources    []plan.IPrimitive
+	executor   func(pc plan.IPrimitiveCtx) dto.ExecutorOutput
+}
+
+func (cp *compositePrimitive) Execute(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+	return cp.executor(pc)
+}
+
+func (cp *compositePrimitive) GetPreparedStatementContext() *drm.PreparedStatementCtx {
+	return nil
+}
+
+func (cp *compositePrimitive) SetTxnId(id int) {
+	for i, source := range cp.sources {
+		if i > 0 {
+			id = cp.handlerCtx.TxnCounterMgr.GetNextTxnId()
+		}
+		source.SetTxnId(id)
+	}
+}
+
+func splitConjuncts(expr sqlparser.Expr) []sqlparser.Expr {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		return append(splitConjuncts(expr.Left), splitConjuncts(expr.Right)...)
+	case nil:
+		return nil
+	}
+	return []sqlparser.Expr{expr}
+}
+
+func joinConjuncts(exprs []sqlparser.Expr) sqlparser.Expr {
+	var retVal sqlparser.Expr
+	for _, expr := range exprs {
+		if retVal == nil {
+			retVal = expr
+			continue
+		}
+		retVal = &sqlparser.AndExpr{Left: retVal, Right: expr}
+	}
+	return retVal
+}
+
+func (a *localJoinAnalyzer) visitTableExpr(te sqlparser.TableExpr, nullable bool) error {
+	switch te := te.(type) {
+	case *sqlparser.AliasedTableExpr:
+		tn, ok := te.Expr.(sqlparser.TableName)
+		if !ok {
+			return fmt.Errorf("subqueries are not supported in selects joining '%s' tables with provider tables", localtable.Qualifier)
+		}
+		if localtable.IsLocalTable(tn) {
+			cols, err := localtable.GetColumnNames(a.handlerCtx.SQLEngine, tn.Name.GetRawVal())
+			if err != nil {
+				return err
+			}
+			for _, col := range cols {
+				a.localColumns[strings.ToLower(col)] = true
+			}
+			return nil
+		}
+		alias := te.As.GetRawVal()
+		if alias == "" {
+			alias = tn.Name.GetRawVal()
+		}
+		a.providerTables = append(a.providerTables, &joinedProviderTable{ate: te, table: tn, alias: alias, nullable: nullable})
+	case *sqlparser.ParenTableExpr:
+		for _, expr := range te.Exprs {
+			if err := a.visitTableExpr(expr, nullable); err != nil {
+				return err
+			}
+		}
+	case *sqlparser.JoinTableExpr:
+		leftNullable, rightNullable := nullable, nullable
+		switch te.Join {
+		case sqlparser.LeftJoinStr, sqlparser.NaturalLeftJoinStr:
+			rightNullable = true
+		case sqlparser.RightJoinStr, sqlparser.NaturalRightJoinStr:
+			leftNullable = true
+		}
+		n := len(a.providerTables)
+		if err := a.visitTableExpr(te.LeftExpr, leftNullable); err != nil {
+			return err
+		}
+		m := len(a.providerTables)
+		if err := a.visitTableExpr(te.RightExpr, rightNullable); err != nil {
+			return err
+		}
+		a.joins = append(a.joins, joinCondition{node: te, left: a.providerTables[n:m], right: a.providerTables[m:]})
+	default:
+		return fmt.Errorf("cannot join '%s' tables with provider tables in a FROM clause of type %T", localtable.Qualifier, te)
+	}
+	return nil
+}
+
+// resolveColumn returns the provider table a column belongs to; an
+// unqualified column is taken as belonging to the sole provider table,
+// unless some local table has a column of the same name.
+func (a *localJoinAnalyzer) resolveColumn(col *sqlparser.ColName) *joinedProviderTable {
+	if !col.Qualifier.IsEmpty() {
+		for _, pt := range a.providerTables {
+			if col.Qualifier.Qualifier.IsEmpty() && strings.EqualFold(col.Qualifier.Name.GetRawVal(), pt.alias) {
+				return pt
+			}
+			if sqlparser.String(col.Qualifier) == sqlparser.String(pt.table) {
+				return pt
+			}
+		}
+		return nil
+	}
+	if len(a.providerTables) == 1 && !a.localColumns[strings.ToLower(col.Name.GetRawVal())] {
+		return a.providerTables[0]
+	}
+	return nil
+}
+
+// bind returns the provider table to which every column of the
+// predicate belongs, if there is such a table.
+func (a *localJoinAnalyzer) bind(expr sqlparser.Expr) *joinedProviderTable {
+	var retVal *joinedProviderTable
+	isBound := true
+	hasColumns := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			isBound = false
+			return false, nil
+		case *sqlparser.ColName:
+			hasColumns = true
+			pt := a.resolveColumn(node)
+			if pt == nil || (retVal != nil && retVal != pt) {
+				isBound = false
+				return false, nil
+			}
+			retVal = pt
+		}
+		return true, nil
+	}, expr)
+	if !isBound || !hasColumns {
+		return nil
+	}
+	return retVal
+}
+
+func (pt *joinedProviderTable) addColumn(name string) {
+	for _, col := range pt.columns {
+		if strings.EqualFold(col, name) {
+			return
+		}
+	}
+	pt.columns = append(pt.columns, name)
+}
+
+// collectColumns records the columns the statement, less the predicates
+// pushed down, reads from each provider table, so that its select projects
+// those alone and not the parameters it is filtered by; a star, or a
+// column that cannot be placed, has every column selected.
+func (a *localJoinAnalyzer) collectColumns(node *sqlparser.Select) {
+	sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		switch n := n.(type) {
+		case *sqlparser.StarExpr:
+			for _, pt := range a.providerTables {
+				if n.TableName.IsEmpty() || strings.EqualFold(n.TableName.Name.GetRawVal(), pt.alias) || sqlparser.String(n.TableName) == sqlparser.String(pt.table) {
+					pt.isStar = true
+				}
+			}
+		case *sqlparser.ColName:
+			if pt := a.resolveColumn(n); pt != nil {
+				pt.addColumn(n.Name.GetRawVal())
+				return true, nil
+			}
+			if n.Qualifier.IsEmpty() && !a.localColumns[strings.ToLower(n.Name.GetRawVal())] {
+				for _, pt := range a.providerTables {
+					pt.isStar = true
+				}
+			}
+		}
+		return true, nil
+	}, node)
+}
+
+func containsProviderTable(pts []*joinedProviderTable, pt *joinedProviderTable) bool {
+	for _, candidate := range pts {
+		if candidate == pt {
+			return true
+		}
+	}
+	return false
+}
+
+// pushDown moves predicates binding to a single provider table into its
+// select.  WHERE predicates are moved unless the table is on the nullable
+// side of an outer join; ON predicates are moved for inner joins and for
+// the nullable side of outer joins, which they filter ahead of the join.
+func (a *localJoinAnalyzer) pushDown(node *sqlparser.Select) {
+	for _, jc := range a.joins {
+		if jc.node.Condition.On == nil {
+			continue
+		}
+		var kept []sqlparser.Expr
+		for _, expr := range splitConjuncts(jc.node.Condition.On) {
+			pt := a.bind(expr)
+			isPushable := pt != nil
+			switch jc.node.Join {
+			case sqlparser.LeftJoinStr:
+				isPushable = isPushable && containsProviderTable(jc.right, pt)
+			case sqlparser.RightJoinStr:
+				isPushable = isPushable && containsProviderTable(jc.left, pt)
+			}
+			if isPushable {
+				pt.conjuncts = append(pt.conjuncts, expr)
+				continue
+			}
+			kept = append(kept, expr)
+		}
+		jc.node.Condition.On = joinConjuncts(kept)
+	}
+	if node.Where == nil {
+		return
+	}
+	var kept []sqlparser.Expr
+	for _, expr := range splitConjuncts(node.Where.Expr) {
+		if pt := a.bind(expr); pt != nil && !pt.nullable {
+			pt.conjuncts = append(pt.conjuncts, expr)
+			continue
+		}
+		kept = append(kept, expr)
+	}
+	node.Where = nil
+	if len(kept) > 0 {
+		node.Where = sqlparser.NewWhere(sqlparser.WhereStr, joinConjuncts(kept))
+	}
+}
+
+func (pt *joinedProviderTable) getSelect() *sqlparser.Select {
+	retVal := &sqlparser.Select{
+		SelectExprs: sqlparser.SelectExprs{&sqlparser.StarExpr{}},
+		From:        sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: pt.table}},
+	}
+	if !pt.isStar && len(pt.columns) > 0 {
+		retVal.SelectExprs = nil
+		for _, col := range pt.columns {
+			retVal.SelectExprs = append(retVal.SelectExprs, &sqlparser.AliasedExpr{Expr: &sqlparser.ColName{Name: sqlparser.NewColIdent(col)}})
+		}
+	}
+	if len(pt.conjuncts) == 0 {
+		return retVal
+	}
+	for _, expr := range pt.conjuncts {
+		sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			if col, ok := node.(*sqlparser.ColName); ok {
+				col.Qualifier = sqlparser.TableName{}
+			}
+			return true, nil
+		}, expr)
+	}
+	retVal.Where = sqlparser.NewWhere(sqlparser.WhereStr, joinConjuncts(pt.conjuncts))
+	return retVal
+}
+
+func getScratchColumns(res *sqltypes.Result, colTypes map[string]string) []localtable.Column {
+	if res != nil && len(res.Fields) > 0 {
+		return getLocalColumns(res.Fields, colTypes)
+	}
+	var names []string
+	for k := range colTypes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var retVal []localtable.Column
+	for _, name := range names {
+		retVal = append(retVal, localtable.NewColumn(name, colTypes[name]))
+	}
+	return retVal
+}
+
+func getColumnNames(columns []localtable.Column) []string {
+	var retVal []string
+	for _, col := range columns {
+		retVal = append(retVal, col.Name)
+	}
+	return retVal
+}
+
+func localJoinSelectExecutor(handlerCtx *handler.HandlerContext, node *sqlparser.Select) (plan.IPrimitive, error) {
+	a := &localJoinAnalyzer{
+		handlerCtx:   handlerCtx,
+		localColumns: make(map[string]bool),
+	}
+	for _, te := range node.From {
+		if err := a.visitTableExpr(te, false); err != nil {
+			return nil, err
+		}
+	}
+	a.pushDown(node)
+	a.collectColumns(node)
+	var sources []plan.IPrimitive
+	var sourceColTypes []map[string]string
+	var scratchTableNames []string
+	for _, pt := range a.providerTables {
+		source, colTypes, err := buildSelect(handlerCtx, pt.getSelect())
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+		sourceColTypes = append(sourceColTypes, colTypes)
+		scratchTableId := int(atomic.AddInt64(&scratchTableCounter, 1))
+		scratchTableNames = append(scratchTableNames, localtable.GetScratchTableName(scratchTableId))
+		pt.ate.Expr = localtable.GetScratchTable(scratchTableId)
+		pt.ate.As = sqlparser.NewTableIdent(pt.alias)
+	}
+	query := sqlparser.String(node)
+	return &compositePrimitive{
+		handlerCtx: handlerCtx,
+		sources:    sources,
+		executor: func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			defer func() {
+				for _, scratchTableName := range scratchTableNames {
+					localtable.DropScratchTable(handlerCtx.SQLEngine, scratchTableName)
+				}
+			}()
+			for i, source := range sources {
+				output := source.Execute(pc)
+				if output.Err != nil {
+					return output
+				}
+				columns := getScratchColumns(output.Result, sourceColTypes[i])
+				if err := localtable.CreateScratchTable(handlerCtx.SQLEngine, scratchTableNames[i], columns); err != nil {
+					return util.GenerateSimpleErroneousOutput(err)
+				}
+				if output.Result == nil {
+					continue
+				}
+				if _, err := localtable.InsertScratchRows(handlerCtx.SQLEngine, scratchTableNames[i], getColumnNames(columns), output.Result.Rows); err != nil {
+					return util.GenerateSimpleErroneousOutput(err)
+				}
+			}
+			return localtable.Query(handlerCtx.SQLEngine, query)
+		},
+	}, nil
+}
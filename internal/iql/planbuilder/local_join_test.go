@@ -0,0 +1,68 @@
+package planbuilder_test
+
+import (
+	"testing"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/localtable"
+	. "infraql/internal/iql/planbuilder"
+	"infraql/internal/test/infraqltestutil"
+
+	"vitess.io/vitess/go/sqltypes"
+)
+
+func TestLocalJoinProviderTable(t *testing.T) {
+	infraqltestutil.SetupSimpleSelectGoogleComputeInstance(t)
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+
+	err := localtable.CreateTable(handlerCtx.SQLEngine, "x", []localtable.Column{localtable.NewColumn("name", "text"), localtable.NewColumn("owner", "text")}, false)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	_, err = localtable.InsertRows(
+		handlerCtx.SQLEngine,
+		"x",
+		[]string{"name", "owner"},
+		[][]sqltypes.Value{
+			{sqltypes.NewVarChar("demo-vm-tt1"), sqltypes.NewVarChar("alice")},
+			{sqltypes.NewVarChar("demo-vm-absent"), sqltypes.NewVarChar("bob")},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	handlerCtx.Query = "SELECT l.owner, i.name, i.zone FROM local.x l JOIN google.compute.instances i ON l.name = i.name WHERE i.project = 'testing-project' AND i.zone = 'australia-southeast1-b';"
+	pl, err := BuildPlanFromContext(handlerCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	output := pl.Instructions.Execute(dto.NewBasicPrimitiveContext(nil, nil, handlerCtx.Outfile, handlerCtx.OutErrFile, nil))
+	if output.Err != nil {
+		t.Fatalf("Test failed: %v", output.Err)
+	}
+	if output.Result == nil || len(output.Result.Rows) != 1 {
+		t.Fatalf("Test failed: expected exactly one joined row, got %v", output.Result)
+	}
+	row := output.Result.Rows[0]
+	if row[0].ToString() != "alice" || row[1].ToString() != "demo-vm-tt1" || row[2].ToString() != "https://www.googleapis.com/compute/v1/projects/testing-project/zones/australia-southeast1-b" {
+		t.Fatalf("Test failed: unexpected joined row %v", row)
+	}
+
+	rows, err := handlerCtx.SQLEngine.Query(`SELECT name FROM sqlite_schema WHERE type = 'table' AND name LIKE 'scratch.%'`)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	defer rows.Close()
+	var leftovers []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		leftovers = append(leftovers, name)
+	}
+	if len(leftovers) > 0 {
+		t.Fatalf("Test failed: scratch tables not dropped: %v", leftovers)
+	}
+}
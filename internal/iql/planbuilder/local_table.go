@@ -0,0 +1,209 @@
+package planbuilder
+
+import (
+	"fmt"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/iqlerror"
+	"infraql/internal/iql/localtable"
+	"infraql/internal/iql/parse"
+	"infraql/internal/iql/parserutil"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/primitivebuilder"
+	"infraql/internal/iql/util"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// wrapSourcePrimitive ensures that a cached plan wrapping a provider
+// select still receives fresh transaction ids.
+func wrapSourcePrimitive(source plan.IPrimitive, ex func(pc plan.IPrimitiveCtx) dto.ExecutorOutput) plan.IPrimitive {
+	if source != nil {
+		if psc := source.GetPreparedStatementContext(); psc != nil {
+			return primitivebuilder.NewHTTPRestPrimitive(nil, ex, source.GetPreparedStatementContext, psc.TxnCtrlCtrs)
+		}
+		if _, ok := source.(*compositePrimitive); ok {
+			return &compositePrimitive{sources: []plan.IPrimitive{source}, executor: ex}
+		}
+	}
+	return primitivebuilder.NewLocalPrimitive(ex)
+}
+
+func localTableSelectExecutor(handlerCtx *handler.HandlerContext, node *sqlparser.Select) plan.IPrimitive {
+	query := sqlparser.String(node)
+	return primitivebuilder.NewLocalPrimitive(
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			return localtable.Query(handlerCtx.SQLEngine, query)
+		})
+}
+
+func getLocalColumns(fields []*querypb.Field, colTypes map[string]string) []localtable.Column {
+	var retVal []localtable.Column
+	for _, f := range fields {
+		retVal = append(retVal, localtable.NewColumn(f.Name, colTypes[f.Name]))
+	}
+	return retVal
+}
+
+func getFieldNames(fields []*querypb.Field) []string {
+	var retVal []string
+	for _, f := range fields {
+		retVal = append(retVal, f.Name)
+	}
+	return retVal
+}
+
+func messageOutput(msg string) dto.ExecutorOutput {
+	return dto.NewExecutorOutput(nil, nil, &dto.BackendMessages{WorkingMessages: []string{msg}}, nil)
+}
+
+func handleCreateAsSelect(handlerCtx *handler.HandlerContext, node *parse.CreateAsSelect) (plan.IPrimitive, error) {
+	switch node.Kind {
+	case parse.CreateViewStr:
+		return handleCreateView(handlerCtx, node)
+	case parse.CreateTableStr:
+	default:
+		return nil, iqlerror.GetStatementNotSupportedError(fmt.Sprintf("CREATE %s AS SELECT", node.Kind))
+	}
+	if !localtable.IsLocalTable(node.Table) {
+		return nil, fmt.Errorf("CREATE TABLE AS SELECT is only supported for tables in the '%s' namespace, eg: %s.my_table", localtable.Qualifier, localtable.Qualifier)
+	}
+	tableName := node.Table.Name.GetRawVal()
+	source, colTypes, err := buildSelect(handlerCtx, node.Select)
+	if err != nil {
+		return nil, err
+	}
+	return wrapSourcePrimitive(
+		source,
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			exists, err := localtable.TableExists(handlerCtx.SQLEngine, tableName)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			if exists {
+				if node.IfNotExists {
+					return messageOutput(fmt.Sprintf("local table '%s' already exists, nothing written", tableName))
+				}
+				return util.GenerateSimpleErroneousOutput(fmt.Errorf("local table '%s' already exists", tableName))
+			}
+			output := source.Execute(pc)
+			if output.Err != nil {
+				return output
+			}
+			if output.Result == nil || len(output.Result.Fields) == 0 {
+				return util.GenerateSimpleErroneousOutput(fmt.Errorf("cannot infer columns for local table '%s' from an empty result", tableName))
+			}
+			err = localtable.CreateTable(handlerCtx.SQLEngine, tableName, getLocalColumns(output.Result.Fields, colTypes), false)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			n, err := localtable.InsertRows(handlerCtx.SQLEngine, tableName, getFieldNames(output.Result.Fields), output.Result.Rows)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			return messageOutput(fmt.Sprintf("local table '%s' created with %d rows", tableName, n))
+		}), nil
+}
+
+func getLocalInsertValues(node *sqlparser.Insert) ([][]sqltypes.Value, error) {
+	valRows, nonValCols, err := parserutil.ExtractInsertValColumns(node)
+	if err != nil {
+		return nil, err
+	}
+	if nonValCols > 0 {
+		return nil, fmt.Errorf("insert into local table not supported for anything but static values or a SELECT: found %d non-static values", nonValCols)
+	}
+	retVal := make([][]sqltypes.Value, len(valRows))
+	for i := 0; i < len(valRows); i++ {
+		valRow := valRows[i]
+		row := make([]sqltypes.Value, len(valRow))
+		for j := 0; j < len(valRow); j++ {
+			row[j], _ = sqltypes.NewValue(querypb.Type_TEXT, util.InterfaceToBytes(valRow[j], false))
+		}
+		retVal[i] = row
+	}
+	return retVal, nil
+}
+
+func handleLocalInsert(handlerCtx *handler.HandlerContext, node *sqlparser.Insert) (plan.IPrimitive, error) {
+	tableName := node.Table.Name.GetRawVal()
+	columns, err := parserutil.ExtractInsertColumnNames(node)
+	if err != nil {
+		return nil, err
+	}
+	var source plan.IPrimitive
+	var staticRows [][]sqltypes.Value
+	switch rows := node.Rows.(type) {
+	case *sqlparser.Select:
+		source, _, err = buildSelect(handlerCtx, rows)
+	case sqlparser.Values:
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("insert of static values into local table '%s' requires a column list", tableName)
+		}
+		staticRows, err = getLocalInsertValues(node)
+	default:
+		return nil, iqlerror.GetStatementNotSupportedError(fmt.Sprintf("INSERT into local table from %T", rows))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return wrapSourcePrimitive(
+		source,
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			exists, err := localtable.TableExists(handlerCtx.SQLEngine, tableName)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			if !exists {
+				return util.GenerateSimpleErroneousOutput(fmt.Errorf("local table '%s' does not exist, create it with CREATE TABLE %s.%s AS SELECT ...", tableName, localtable.Qualifier, tableName))
+			}
+			insertCols := columns
+			rows := staticRows
+			if source != nil {
+				output := source.Execute(pc)
+				if output.Err != nil {
+					return output
+				}
+				if output.Result == nil {
+					return messageOutput(fmt.Sprintf("0 rows inserted into local table '%s'", tableName))
+				}
+				if len(insertCols) == 0 {
+					insertCols = getFieldNames(output.Result.Fields)
+				}
+				rows = output.Result.Rows
+			}
+			n, err := localtable.InsertRows(handlerCtx.SQLEngine, tableName, insertCols, rows)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			return messageOutput(fmt.Sprintf("%d rows inserted into local table '%s'", n, tableName))
+		}), nil
+}
+
+func handleDDL(handlerCtx *handler.HandlerContext, node *sqlparser.DDL) (plan.IPrimitive, error) {
+	if node.Action != sqlparser.DropStr || len(node.FromTables) == 0 {
+		return nil, iqlerror.GetStatementNotSupportedError("DDL")
+	}
+	if isDropView(handlerCtx.Query) {
+		return handleDropView(handlerCtx, node)
+	}
+	var tableNames []string
+	for _, tn := range node.FromTables {
+		if !localtable.IsLocalTable(tn) {
+			return nil, fmt.Errorf("DROP TABLE is only supported for tables in the '%s' namespace", localtable.Qualifier)
+		}
+		tableNames = append(tableNames, tn.Name.GetRawVal())
+	}
+	return primitivebuilder.NewLocalPrimitive(
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			for _, tableName := range tableNames {
+				if err := localtable.DropTable(handlerCtx.SQLEngine, tableName, node.IfExists); err != nil {
+					return util.GenerateSimpleErroneousOutput(err)
+				}
+			}
+			return dto.NewExecutorOutput(nil, nil, nil, nil)
+		}), nil
+}
@@ -0,0 +1,156 @@
+package planbuilder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"infraql/internal/iql/asyncmonitor"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/iqlutil"
+	"infraql/internal/iql/operations"
+	"infraql/internal/iql/parse"
+	"infraql/internal/iql/parserutil"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/primitivebuilder"
+	"infraql/internal/iql/taxonomy"
+	"infraql/internal/iql/util"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+var (
+	operationColumns         []string = []string{"id", "status", "resource", "target", "principal", "error", "created", "updated"}
+	operationExtendedColumns []string = []string{"auth_profile", "self_link"}
+)
+
+// recordOperations wraps the executor so that any operation it returns is
+// recorded, iff the method is one that begins long running operations.
+func (pb *primitiveGenerator) recordOperations(handlerCtx *handler.HandlerContext, tbl taxonomy.ExtendedTableMetadata, executor func(pc plan.IPrimitiveCtx) dto.ExecutorOutput) func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+	strategy := asyncmonitor.GetOperationStrategy(tbl.HeirarchyObjects)
+	if strategy == nil {
+		return executor
+	}
+	prov, err := tbl.GetProvider()
+	if err != nil {
+		return executor
+	}
+	ids := tbl.HeirarchyObjects.HeirarchyIds
+	authProfile := handlerCtx.AuthProfile
+	return func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+		output := executor(pc)
+		// the principal is known only once authenticated
+		authCtx, _ := handlerCtx.GetAuthContextForProfile(prov.GetProviderString(), authProfile)
+		template := dto.OperationRecord{
+			Provider:    prov.GetProviderString(),
+			Resource:    fmt.Sprintf("%s.%s", ids.ServiceStr, ids.ResourceStr),
+			Principal:   operations.GetPrincipal(authCtx),
+			AuthProfile: authProfile,
+		}
+		if output.OutputBody != nil {
+			template.SelfLink, _ = strategy.GetPollUrl(tbl.HeirarchyObjects, output.OutputBody)
+		}
+		operations.Record(handlerCtx, template, output.OutputBody)
+		return output
+	}
+}
+
+// handleAwaitOperation polls the recorded operation as governed by the
+// statement's AWAIT directive, if any, eg:
+// /*+ AWAIT(timeout=600) */ AWAIT OPERATION 'operation-xxxxx-yyyyy-0001'.
+func handleAwaitOperation(handlerCtx *handler.HandlerContext, node *parse.AwaitOperation) (plan.IPrimitive, error) {
+	directive, _ := parserutil.ExtractQueryFunctionDirective(handlerCtx.Query, asyncmonitor.AwaitDirectiveStr)
+	params, err := asyncmonitor.ParseMonitorParams(directive)
+	if err != nil {
+		return nil, err
+	}
+	return primitivebuilder.NewLocalPrimitive(
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			op, err := operations.Get(handlerCtx, node.ID)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			prov, err := handlerCtx.GetProvider(op.Provider)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			authCtx, err := handlerCtx.GetAuthContextForProfile(op.Provider, op.AuthProfile)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			asm, err := asyncmonitor.NewAsyncMonitor(prov, handlerCtx.RuntimeContext, handlerCtx.QueryStats)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			precursor := primitivebuilder.NewHTTPRestPrimitive(
+				prov,
+				func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+					body, err := operations.Poll(handlerCtx, op)
+					return dto.NewExecutorOutput(nil, body, nil, err)
+				},
+				nil,
+				nil,
+			)
+			initialCtx := dto.NewBasicPrimitiveContext(
+				nil,
+				authCtx,
+				handlerCtx.GetStatusFile(),
+				handlerCtx.OutErrFile,
+				nil,
+			)
+			monitor, err := asm.GetOperationMonitorPrimitive(precursor, initialCtx, params, op.SelfLink)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			output := monitor.Execute(pc)
+			operations.Record(handlerCtx, op, output.OutputBody)
+			return output
+		}), nil
+}
+
+func showOperations(handlerCtx *handler.HandlerContext, node *sqlparser.Show) (map[string]map[string]interface{}, []string, error) {
+	var likeRegexp *regexp.Regexp
+	if node.ShowTablesOpt != nil && node.ShowTablesOpt.Filter != nil {
+		if node.ShowTablesOpt.Filter.Filter != nil {
+			return nil, nil, fmt.Errorf("SHOW OPERATIONS supports only a LIKE filter")
+		}
+		var err error
+		likeRegexp, err = regexp.Compile(iqlutil.TranslateLikeToRegexPattern(node.ShowTablesOpt.Filter.Like))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot compile like string '%s': %s", node.ShowTablesOpt.Filter.Like, err.Error())
+		}
+	}
+	ops, err := handlerCtx.SQLEngine.OperationStoreGetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	extended := strings.TrimSpace(strings.ToUpper(node.Extended)) == "EXTENDED"
+	columnOrder := operationColumns
+	if extended {
+		columnOrder = append(append([]string{}, operationColumns...), operationExtendedColumns...)
+	}
+	keys := make(map[string]map[string]interface{})
+	for _, op := range ops {
+		if likeRegexp != nil && !likeRegexp.MatchString(op.ID) {
+			continue
+		}
+		row := map[string]interface{}{
+			"id":        op.ID,
+			"status":    op.Status,
+			"resource":  op.Resource,
+			"target":    op.Target,
+			"principal": op.Principal,
+			"error":     op.Error,
+			"created":   op.CreatedDttm,
+			"updated":   op.UpdatedDttm,
+		}
+		if extended {
+			row["auth_profile"] = op.AuthProfile
+			row["self_link"] = op.SelfLink
+		}
+		keys[strconv.Itoa(len(keys))] = row
+	}
+	return keys, columnOrder, nil
+}
@@ -0,0 +1,81 @@
+package planbuilder_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"infraql/internal/iql/asyncmonitor"
+	"infraql/internal/iql/dto"
+	. "infraql/internal/iql/planbuilder"
+	"infraql/internal/iql/provider"
+	"infraql/internal/test/infraqltestutil"
+	"infraql/internal/test/testhttpapi"
+	"infraql/internal/test/testobjects"
+)
+
+const awaitedOperationSelfLink string = "https://www.googleapis.com/compute/v1/projects/testing-project/global/operations/op-slow"
+
+// runningOperationServer stands in for the compute API,
+// reporting an operation forever in progress.
+type runningOperationServer struct {
+	mu    sync.Mutex
+	polls int
+}
+
+func (s *runningOperationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Host == "oauth2.googleapis.com":
+		fmt.Fprint(w, testobjects.GoogleAuthTokenResponse)
+	case r.Method == "GET" && r.URL.Path == "/compute/v1/projects/testing-project/global/operations/op-slow":
+		s.mu.Lock()
+		s.polls++
+		s.mu.Unlock()
+		fmt.Fprintf(w, networkOperationTemplate, "slow", "RUNNING", "slow", "slow")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestAwaitOperationDirective(t *testing.T) {
+	server := &runningOperationServer{}
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(server, nil)
+	provider.DummyAuth = true
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+	handlerCtx.RuntimeContext.HTTPBatchMaxParts = 0
+
+	op := dto.OperationRecord{ID: "op-slow", SelfLink: awaitedOperationSelfLink, Provider: "google", Resource: "compute.networks", Status: "RUNNING"}
+	if err := handlerCtx.SQLEngine.OperationStorePut(op); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	handlerCtx.Query = "/*+ AWAIT(timeout=0.3, interval=0.05) */ AWAIT OPERATION 'op-slow'"
+	pl, err := BuildPlanFromContext(handlerCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	start := time.Now()
+	output := pl.Instructions.Execute(dto.NewBasicPrimitiveContext(nil, nil, handlerCtx.Outfile, handlerCtx.OutErrFile, nil))
+	var timeoutErr *asyncmonitor.AwaitTimeoutError
+	if !errors.As(output.Err, &timeoutErr) {
+		t.Fatalf("Test failed: expected await timeout, got %v", output.Err)
+	}
+	if timeoutErr.SelfLink != awaitedOperationSelfLink || timeoutErr.Timeout != 300*time.Millisecond {
+		t.Fatalf("Test failed: unexpected timeout error %+v", timeoutErr)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Test failed: timeout of 300ms took %v", elapsed)
+	}
+	// the default interval would allow no more than the initial poll
+	if server.polls < 3 {
+		t.Fatalf("Test failed: operation polled %d times, expected the directive's interval", server.polls)
+	}
+
+	handlerCtx.Query = "/*+ AWAIT(deadline=60) */ AWAIT OPERATION 'op-slow'"
+	if _, err := BuildPlanFromContext(handlerCtx); err == nil {
+		t.Fatalf("Test failed: invalid AWAIT directive accepted")
+	}
+}
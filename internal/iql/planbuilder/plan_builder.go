@@ -6,10 +6,14 @@ import (
 	"infraql/internal/iql/dto"
 	"infraql/internal/iql/handler"
 	"infraql/internal/iql/iqlerror"
+	"infraql/internal/iql/localtable"
 	"infraql/internal/iql/parse"
+	"infraql/internal/iql/parserutil"
 	"infraql/internal/iql/plan"
 	"infraql/internal/iql/primitivebuilder"
+	"infraql/internal/iql/taxonomy"
 	"infraql/internal/iql/util"
+	"infraql/internal/iql/views"
 	"strings"
 
 	"vitess.io/vitess/go/vt/sqlparser"
@@ -30,7 +34,7 @@ func createInstructionFor(handlerCtx *handler.HandlerContext, stmt sqlparser.Sta
 	case *sqlparser.DBDDL:
 		return nil, iqlerror.GetStatementNotSupportedError(fmt.Sprintf("unsupported: Database DDL %v", sqlparser.String(stmt)))
 	case *sqlparser.DDL:
-		return nil, iqlerror.GetStatementNotSupportedError("DDL")
+		return handleDDL(handlerCtx, stmt)
 	case *sqlparser.Delete:
 		return handleDelete(handlerCtx, stmt)
 	case *sqlparser.DescribeTable:
@@ -90,6 +94,17 @@ func handleAuth(handlerCtx *handler.HandlerContext, node *sqlparser.Auth) (plan.
 		prov,
 		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
 			authType := strings.ToLower(node.Type)
+			if authType == parse.AuthImpersonateStr {
+				// the context is left unchanged if the base
+				// credential may not impersonate the principal
+				previous := authCtx.Impersonate
+				authCtx.Impersonate = node.KeyFilePath
+				_, err := prov.Auth(authCtx, authCtx.Type, false)
+				if err != nil {
+					authCtx.Impersonate = previous
+				}
+				return dto.NewExecutorOutput(nil, nil, nil, err)
+			}
 			if node.KeyFilePath != "" {
 				authCtx.KeyFilePath = node.KeyFilePath
 			}
@@ -151,28 +166,61 @@ func handleDescribe(handlerCtx *handler.HandlerContext, node *sqlparser.Describe
 }
 
 func handleSelect(handlerCtx *handler.HandlerContext, node *sqlparser.Select) (plan.IPrimitive, error) {
+	primitive, _, err := buildSelect(handlerCtx, node)
+	return primitive, err
+}
+
+// buildSelect additionally returns the relational types of the
+// selected columns, where known.
+func buildSelect(handlerCtx *handler.HandlerContext, node *sqlparser.Select) (plan.IPrimitive, map[string]string, error) {
+	node, err := views.ExpandSelect(handlerCtx.SQLEngine, node)
+	if err != nil {
+		return nil, nil, err
+	}
+	if allLocal, anyLocal := localtable.ClassifyTableNames(node); anyLocal {
+		if !allLocal {
+			primitive, err := localJoinSelectExecutor(handlerCtx, node)
+			return primitive, nil, err
+		}
+		return localTableSelectExecutor(handlerCtx, node), nil, nil
+	}
 	if !handlerCtx.RuntimeContext.TestWithoutApiCalls {
 		primitiveGenerator := newPrimitiveGenerator(node, handlerCtx)
 		err := primitiveGenerator.analyzeStatement(handlerCtx, node)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		isLocallyExecutable := true
 		for _, val := range primitiveGenerator.PrimitiveBuilder.GetTables() {
 			isLocallyExecutable = isLocallyExecutable && val.IsLocallyExecutable
 		}
 		if isLocallyExecutable {
-			return primitiveGenerator.localSelectExecutor(handlerCtx, node, util.DefaultRowSort)
+			primitive, err := primitiveGenerator.localSelectExecutor(handlerCtx, node, util.DefaultRowSort)
+			return primitive, nil, err
 		}
-		return primitiveGenerator.selectExecutor(handlerCtx, node, util.DefaultRowSort)
+		colTypes := make(map[string]string)
+		if psc := primitiveGenerator.PrimitiveBuilder.GetSelectPreparedStatementCtx(); psc != nil {
+			for _, col := range psc.NonControlColumns {
+				colTypes[col.Column.GetIdentifier()] = col.Coupling.RelationalType
+			}
+		}
+		primitive, err := primitiveGenerator.selectExecutor(handlerCtx, node, util.DefaultRowSort)
+		return primitive, colTypes, err
 	}
-	return primitivebuilder.NewLocalPrimitive(nil), nil
+	return primitivebuilder.NewLocalPrimitive(nil), nil, nil
 }
 
 func handleDelete(handlerCtx *handler.HandlerContext, node *sqlparser.Delete) (plan.IPrimitive, error) {
 	if !handlerCtx.RuntimeContext.TestWithoutApiCalls {
+		heirarchy, err := taxonomy.GetHeirarchyFromStatement(handlerCtx, node)
+		if err != nil {
+			return nil, err
+		}
+		if !isDirectDelete(heirarchy.Method, node) {
+			return handleDeleteFromQuery(handlerCtx, node, heirarchy)
+		}
 		primitiveGenerator := newPrimitiveGenerator(node, handlerCtx)
-		err := primitiveGenerator.analyzeStatement(handlerCtx, node)
+		err = primitiveGenerator.analyzeStatement(handlerCtx, node)
 		if err != nil {
 			return nil, err
 		}
@@ -184,12 +232,21 @@ func handleDelete(handlerCtx *handler.HandlerContext, node *sqlparser.Delete) (p
 }
 
 func handleInsert(handlerCtx *handler.HandlerContext, node *sqlparser.Insert) (plan.IPrimitive, error) {
+	if localtable.IsLocalTable(node.Table) {
+		return handleLocalInsert(handlerCtx, node)
+	}
 	if !handlerCtx.RuntimeContext.TestWithoutApiCalls {
+		if parserutil.IsInsertFromQuery(node) {
+			return handleInsertFromQuery(handlerCtx, node)
+		}
 		primitiveGenerator := newPrimitiveGenerator(node, handlerCtx)
 		err := primitiveGenerator.analyzeStatement(handlerCtx, node)
 		if err != nil {
 			return nil, err
 		}
+		if len(primitiveGenerator.PrimitiveBuilder.GetInsertValOnlyRows()) > 1 {
+			return primitiveGenerator.insertFromValuesExecutor(handlerCtx, node)
+		}
 		return primitiveGenerator.insertExecutor(handlerCtx, node, util.DefaultRowSort)
 	} else {
 		return primitivebuilder.NewHTTPRestPrimitive(nil, nil, nil, nil), nil
@@ -278,7 +335,37 @@ func BuildPlanFromContext(handlerCtx *handler.HandlerContext) (*plan.Plan, error
 	var err error
 	var rowSort func(map[string]map[string]interface{}) []string
 	var statement sqlparser.Statement
-	statement, err = parse.ParseQuery(handlerCtx.Query)
+	createAs, err := parse.ParseCreateAsSelect(handlerCtx.Query)
+	if err != nil {
+		return createErroneousPlan(handlerCtx, qPlan, rowSort, err)
+	}
+	if createAs != nil {
+		qPlan.Type = sqlparser.StmtDDL
+		qPlan.Instructions, err = handleCreateAsSelect(handlerCtx, createAs)
+		if qPlan.Instructions != nil {
+			handlerCtx.LRUCache.Set(planKey, qPlan)
+		}
+		return qPlan, err
+	}
+	if awaitOp := parse.ParseAwaitOperation(handlerCtx.Query); awaitOp != nil {
+		qPlan.Type = sqlparser.StmtOther
+		qPlan.Instructions, err = handleAwaitOperation(handlerCtx, awaitOp)
+		if qPlan.Instructions != nil {
+			handlerCtx.LRUCache.Set(planKey, qPlan)
+		}
+		return qPlan, err
+	}
+	statement, err = parse.ParseExtendedAuth(handlerCtx.Query)
+	if err != nil {
+		return createErroneousPlan(handlerCtx, qPlan, rowSort, err)
+	}
+	if statement == nil {
+		statement, err = parse.ParseQuery(handlerCtx.Query)
+		if err != nil {
+			return createErroneousPlan(handlerCtx, qPlan, rowSort, err)
+		}
+	}
+	statement, err = views.Expand(handlerCtx.SQLEngine, statement)
 	if err != nil {
 		return createErroneousPlan(handlerCtx, qPlan, rowSort, err)
 	}
@@ -313,6 +400,9 @@ func BuildPlanFromContext(handlerCtx *handler.HandlerContext) (*plan.Plan, error
 	}
 
 	qPlan.Instructions = instructions
+	if instructions != nil && !handlerCtx.RuntimeContext.TestWithoutApiCalls {
+		qPlan.Scopes = getStatementScopes(handlerCtx, result.AST)
+	}
 
 	if instructions != nil {
 		handlerCtx.LRUCache.Set(planKey, qPlan)
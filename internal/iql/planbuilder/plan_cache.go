@@ -0,0 +1,57 @@
+package planbuilder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/iqlutil"
+	"infraql/internal/iql/plan"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+var planCacheColumns []string = []string{"query", "type", "exec_count", "exec_time_ms", "rows", "errors", "http_calls", "pages", "bytes_received", "rows_inserted", "retries"}
+
+// showPlanCache lists the cached plans, most recently used first,
+// with the stats accumulated over their executions.
+func showPlanCache(handlerCtx *handler.HandlerContext, node *sqlparser.Show) (map[string]map[string]interface{}, []string, error) {
+	var likeRegexp *regexp.Regexp
+	if node.ShowTablesOpt != nil && node.ShowTablesOpt.Filter != nil {
+		if node.ShowTablesOpt.Filter.Filter != nil {
+			return nil, nil, fmt.Errorf("SHOW PLAN CACHE supports only a LIKE filter")
+		}
+		var err error
+		likeRegexp, err = regexp.Compile(iqlutil.TranslateLikeToRegexPattern(node.ShowTablesOpt.Filter.Like))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot compile like string '%s': %s", node.ShowTablesOpt.Filter.Like, err.Error())
+		}
+	}
+	keys := make(map[string]map[string]interface{})
+	for _, item := range handlerCtx.LRUCache.Items() {
+		qPlan, ok := item.Value.(*plan.Plan)
+		if !ok {
+			continue
+		}
+		if likeRegexp != nil && !likeRegexp.MatchString(item.Key) {
+			continue
+		}
+		stats := qPlan.GetStats()
+		keys[strconv.Itoa(len(keys))] = map[string]interface{}{
+			"query":          strings.TrimSpace(item.Key),
+			"type":           qPlan.Type.String(),
+			"exec_count":     stats.ExecCount,
+			"exec_time_ms":   stats.ExecTime.Milliseconds(),
+			"rows":           stats.Rows,
+			"errors":         stats.Errors,
+			"http_calls":     stats.HTTPCalls,
+			"pages":          stats.Pages,
+			"bytes_received": stats.BytesReceived,
+			"rows_inserted":  stats.RowsInserted,
+			"retries":        stats.Retries,
+		}
+	}
+	return keys, planCacheColumns, nil
+}
@@ -0,0 +1,87 @@
+package planbuilder_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"infraql/internal/iql/provider"
+	"infraql/internal/iql/querysubmit"
+	"infraql/internal/test/infraqltestutil"
+	"infraql/internal/test/testhttpapi"
+	"infraql/internal/test/testobjects"
+
+	lrucache "vitess.io/vitess/go/cache"
+)
+
+// instanceListServer stands in for the compute API, listing two instances.
+func instanceListServer(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Host == "oauth2.googleapis.com":
+		fmt.Fprint(w, testobjects.GoogleAuthTokenResponse)
+	case r.Method == "GET" && r.URL.Path == "/compute/v1/projects/testing-project/zones/australia-southeast1-b/instances":
+		fmt.Fprint(w, testobjects.SimpleSelectGoogleComputeInstanceResponse)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPlanCacheStats(t *testing.T) {
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(http.HandlerFunc(instanceListServer), nil)
+	provider.DummyAuth = true
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+	handlerCtx.RuntimeContext.HTTPBatchMaxParts = 0
+	handlerCtx.LRUCache = lrucache.NewLRUCache(100)
+
+	query := "SELECT name FROM google.compute.instances WHERE project = 'testing-project' AND zone = 'australia-southeast1-b'"
+	for i := 0; i < 2; i++ {
+		handlerCtx.Query = query
+		output := querysubmit.SubmitQuery(handlerCtx)
+		if output.Err != nil {
+			t.Fatalf("Test failed: %v", output.Err)
+		}
+		if output.Result == nil || len(output.Result.Rows) != 2 {
+			t.Fatalf("Test failed: expected two instances, got %v", output.Result)
+		}
+	}
+	handlerCtx.Query = "SELECT name FROM google.compute.instances WHERE project = 'testing-project' AND zone = 'nowhere'"
+	if output := querysubmit.SubmitQuery(handlerCtx); output.Err == nil {
+		t.Fatalf("Test failed: expected error listing instances of an unknown zone")
+	}
+
+	handlerCtx.Query = "SHOW PLAN CACHE LIKE '%instances%'"
+	output := querysubmit.SubmitQuery(handlerCtx)
+	if output.Err != nil {
+		t.Fatalf("Test failed: %v", output.Err)
+	}
+	if output.Result == nil {
+		t.Fatalf("Test failed: no plans in the cache")
+	}
+	colIdx := make(map[string]int)
+	for i, f := range output.Result.Fields {
+		colIdx[f.Name] = i
+	}
+	found := 0
+	for _, row := range output.Result.Rows {
+		var expected map[string]string
+		switch strings.TrimSpace(row[colIdx["query"]].ToString()) {
+		case query:
+			// stats are those of both executions
+			expected = map[string]string{"exec_count": "2", "rows": "4", "errors": "0", "http_calls": "2", "pages": "2"}
+		case strings.Replace(query, "australia-southeast1-b", "nowhere", 1):
+			expected = map[string]string{"exec_count": "1", "rows": "0", "errors": "1"}
+		default:
+			continue
+		}
+		found++
+		for col, val := range expected {
+			if row[colIdx[col]].ToString() != val {
+				t.Fatalf("Test failed: %s of '%s' is %s, expected %s", col, row[colIdx["query"]].ToString(), row[colIdx[col]].ToString(), val)
+			}
+		}
+	}
+	if found != 2 {
+		t.Fatalf("Test failed: expected both plans in the cache, got %v", output.Result.Rows)
+	}
+}
@@ -206,6 +206,8 @@ func (pb *primitiveGenerator) inferProviderForShow(node *sqlparser.Show, handler
 		pb.PrimitiveBuilder.SetProvider(prov)
 	case "PROVIDERS":
 		// no provider, might create some dummy object dunno
+	case "OPERATIONS", "PLAN_CACHE", "VIEWS":
+		// operations, plans and views are held locally
 	case "RESOURCES":
 		prov, err := handlerCtx.GetProvider(node.OnTable.Qualifier.GetRawVal())
 		if err != nil {
@@ -235,6 +237,10 @@ func (pb *primitiveGenerator) showInstructionExecutor(node *sqlparser.Show, hand
 	switch nodeTypeUpperCase {
 	case "AUTH":
 		log.Infoln(fmt.Sprintf("Show For node.Type = '%s'", node.Type))
+		if authCtxs := handlerCtx.GetAuthContexts(pb.PrimitiveBuilder.GetProvider().GetProviderString()); len(authCtxs) > 1 {
+			keys, columnOrder = showAuthProfiles(pb.PrimitiveBuilder.GetProvider(), authCtxs)
+			return util.PrepareResultSet(dto.NewPrepareResultSetDTO(nil, keys, columnOrder, numericRowSort, nil, nil))
+		}
 		if err == nil {
 			authCtx, err := handlerCtx.GetAuthContext(pb.PrimitiveBuilder.GetProvider().GetProviderString())
 			if err == nil {
@@ -324,6 +330,14 @@ func (pb *primitiveGenerator) showInstructionExecutor(node *sqlparser.Show, hand
 		for k, v := range resources {
 			keys[k] = v.ToMap(extended)
 		}
+	case "OPERATIONS":
+		keys, columnOrder, err = showOperations(handlerCtx, node)
+		return util.PrepareResultSet(dto.NewPrepareResultSetDTO(nil, keys, columnOrder, numericRowSort, err, nil))
+	case "PLAN_CACHE":
+		keys, columnOrder, err = showPlanCache(handlerCtx, node)
+		return util.PrepareResultSet(dto.NewPrepareResultSetDTO(nil, keys, columnOrder, numericRowSort, err, nil))
+	case "VIEWS":
+		keys, columnOrder, err = showViews(handlerCtx, node)
 	case "SERVICES":
 		log.Infoln(fmt.Sprintf("Show For node.Type = '%s': Displaying services for provider = '%s'", node.Type, pb.PrimitiveBuilder.GetProvider().GetProviderString()))
 		var services map[string]metadata.Service
@@ -401,7 +415,7 @@ func (pb *primitiveGenerator) insertExecutor(handlerCtx *handler.HandlerContext,
 	}
 	ex := func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
 		var err error
-		response, apiErr := httpmiddleware.HttpApiCall(*handlerCtx, prov, tbl.HttpArmoury.Context)
+		response, apiErr := httpmiddleware.HttpApiCall(*handlerCtx, prov, tbl.HeirarchyObjects.HeirarchyIds, tbl.HttpArmoury.Context)
 		if apiErr != nil {
 			return util.PrepareResultSet(dto.NewPrepareResultSetDTO(nil, nil, nil, rowSort, apiErr, nil))
 		}
@@ -438,7 +452,7 @@ func (pb *primitiveGenerator) insertExecutor(handlerCtx *handler.HandlerContext,
 	}
 	insertPrimitive := primitivebuilder.NewHTTPRestPrimitive(
 		prov,
-		ex,
+		pb.recordOperations(handlerCtx, tbl, ex),
 		nil,
 		nil,
 	)
@@ -486,7 +500,7 @@ func (pb *primitiveGenerator) deleteExecutor(handlerCtx *handler.HandlerContext,
 		return nil, err
 	}
 	ex := func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
-		response, apiErr := httpmiddleware.HttpApiCall(*handlerCtx, prov, tbl.HttpArmoury.Context)
+		response, apiErr := httpmiddleware.HttpApiCall(*handlerCtx, prov, tbl.HeirarchyObjects.HeirarchyIds, tbl.HttpArmoury.Context)
 		if apiErr != nil {
 			return util.PrepareResultSet(dto.NewPrepareResultSetDTO(nil, nil, nil, nil, apiErr, nil))
 		}
@@ -523,7 +537,7 @@ func (pb *primitiveGenerator) deleteExecutor(handlerCtx *handler.HandlerContext,
 	}
 	deletePrimitive := primitivebuilder.NewHTTPRestPrimitive(
 		prov,
-		ex,
+		pb.recordOperations(handlerCtx, tbl, ex),
 		nil,
 		nil,
 	)
@@ -569,7 +583,7 @@ func (pb *primitiveGenerator) execExecutor(handlerCtx *handler.HandlerContext, n
 	ex := func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
 		var err error
 		var columnOrder []string
-		response, apiErr := httpmiddleware.HttpApiCall(*handlerCtx, prov, tbl.HttpArmoury.Context)
+		response, apiErr := httpmiddleware.HttpApiCall(*handlerCtx, prov, tbl.HeirarchyObjects.HeirarchyIds, tbl.HttpArmoury.Context)
 		if apiErr != nil {
 			return util.PrepareResultSet(dto.NewPrepareResultSetDTO(nil, nil, nil, nil, apiErr, nil))
 		}
@@ -612,7 +626,7 @@ func (pb *primitiveGenerator) execExecutor(handlerCtx *handler.HandlerContext, n
 	}
 	execPrimitive := primitivebuilder.NewHTTPRestPrimitive(
 		prov,
-		ex,
+		pb.recordOperations(handlerCtx, tbl, ex),
 		nil,
 		nil,
 	)
@@ -627,7 +641,11 @@ func (pb *primitiveGenerator) composeAsyncMonitor(handlerCtx *handler.HandlerCon
 	if err != nil {
 		return nil, err
 	}
-	asm, err := asyncmonitor.NewAsyncMonitor(prov)
+	asm, err := asyncmonitor.NewAsyncMonitor(prov, handlerCtx.RuntimeContext, handlerCtx.QueryStats)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asyncmonitor.ParseMonitorParams(pb.PrimitiveBuilder.GetAwaitDirective())
 	if err != nil {
 		return nil, err
 	}
@@ -638,13 +656,16 @@ func (pb *primitiveGenerator) composeAsyncMonitor(handlerCtx *handler.HandlerCon
 	pl := dto.NewBasicPrimitiveContext(
 		nil,
 		authCtx,
-		handlerCtx.Outfile,
+		handlerCtx.GetStatusFile(),
 		handlerCtx.OutErrFile,
 		pb.PrimitiveBuilder.GetCommentDirectives(),
 	)
-	primitive, err := asm.GetMonitorPrimitive(meta.HeirarchyObjects, precursor, pl)
+	primitive, err := asm.GetMonitorPrimitive(meta.HeirarchyObjects, precursor, pl, params)
 	if err != nil {
 		return nil, err
 	}
+	if monitor, ok := primitive.(*asyncmonitor.AsyncHttpMonitorPrimitive); ok && monitor.Executor != nil {
+		monitor.Executor = pb.recordOperations(handlerCtx, meta, monitor.Executor)
+	}
 	return primitive, err
 }
@@ -0,0 +1,181 @@
+package planbuilder
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/httpbuild"
+	"infraql/internal/iql/httpexec"
+	"infraql/internal/iql/httpmiddleware"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/primitivebuilder"
+	"infraql/internal/iql/provider"
+	"infraql/internal/iql/taxonomy"
+	"infraql/internal/iql/util"
+)
+
+// Statements driven by a query, eg: INSERT ... SELECT, or by many rows of
+// VALUES issue one request per source row and report the outcome of each
+// as a row of its own.
+const (
+	rowRequestRowStr       string = "row"
+	rowRequestStatusStr    string = "status"
+	rowRequestOperationStr string = "operation"
+	rowRequestTargetStr    string = "target"
+	rowRequestErrorStr     string = "error"
+	rowRequestSucceededStr string = "succeeded"
+	rowRequestFailedStr    string = "failed"
+)
+
+var rowRequestColumns []string = []string{
+	rowRequestRowStr,
+	rowRequestStatusStr,
+	rowRequestOperationStr,
+	rowRequestTargetStr,
+	rowRequestErrorStr,
+}
+
+func numericRowSort(rowMap map[string]map[string]interface{}) []string {
+	keys := util.DefaultRowSort(rowMap)
+	sort.SliceStable(keys, func(i, j int) bool {
+		l, _ := strconv.Atoi(keys[i])
+		r, _ := strconv.Atoi(keys[j])
+		return l < r
+	})
+	return keys
+}
+
+// primeAuth resolves credentials before any row requests
+// are issued, so that a failure is reported only once.
+func primeAuth(handlerCtx *handler.HandlerContext, prov provider.IProvider) error {
+	_, err := handlerCtx.GetAuthenticatedClient(prov)
+	return err
+}
+
+// rowRequestBuilder builds the request for the source row of the index.
+type rowRequestBuilder func(idx int) (*httpbuild.HTTPArmoury, error)
+
+// executeRowRequests builds the requests for rowCount rows then makes them, with
+// at most the configured number in flight.  Where the service accepts batch
+// requests and batching is not disabled, the requests are instead sent in
+// batches, and only the awaiting of their operations is concurrent.
+func (pb *primitiveGenerator) executeRowRequests(handlerCtx *handler.HandlerContext, pc plan.IPrimitiveCtx, tbl taxonomy.ExtendedTableMetadata, prov provider.IProvider, rowCount int, buildFunc rowRequestBuilder) dto.ExecutorOutput {
+	armouries := make([]*httpbuild.HTTPArmoury, rowCount)
+	buildErrs := make([]error, rowCount)
+	var requestCtxs []httpexec.IHttpContext
+	for i := 0; i < rowCount; i++ {
+		armouries[i], buildErrs[i] = buildFunc(i)
+		if buildErrs[i] == nil {
+			requestCtxs = append(requestCtxs, armouries[i].Context)
+		}
+	}
+	var batchResults []httpexec.BatchResult
+	if batchUrl := getBatchUrl(tbl); batchUrl != "" && handlerCtx.RuntimeContext.HTTPBatchMaxParts > 1 && len(requestCtxs) > 1 {
+		batchResults = httpmiddleware.HttpApiCallBatch(*handlerCtx, prov, tbl.HeirarchyObjects.HeirarchyIds, batchUrl, requestCtxs)
+	}
+	concurrencyLimit := handlerCtx.RuntimeContext.ConcurrencyLimit
+	if concurrencyLimit < 1 {
+		concurrencyLimit = 1
+	}
+	results := make([]map[string]interface{}, rowCount)
+	sem := make(chan struct{}, concurrencyLimit)
+	var wg sync.WaitGroup
+	requestIdx := 0
+	for i := 0; i < rowCount; i++ {
+		if buildErrs[i] != nil {
+			results[i] = getRowRequestResult(nil, buildErrs[i])
+			results[i][rowRequestRowStr] = i + 1
+			continue
+		}
+		call := func(requestCtx httpexec.IHttpContext) func() (*http.Response, error) {
+			return func() (*http.Response, error) {
+				return httpmiddleware.HttpApiCall(*handlerCtx, prov, tbl.HeirarchyObjects.HeirarchyIds, requestCtx)
+			}
+		}(armouries[i].Context)
+		if batchResults != nil {
+			batchResult := batchResults[requestIdx]
+			call = func() (*http.Response, error) {
+				return batchResult.Response, batchResult.Err
+			}
+		}
+		requestIdx++
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = pb.executeRowRequest(handlerCtx, pc, tbl, prov, call)
+			results[i][rowRequestRowStr] = i + 1
+		}(i)
+	}
+	wg.Wait()
+	keys := make(map[string]map[string]interface{})
+	for i, res := range results {
+		keys[strconv.Itoa(i)] = res
+	}
+	return util.PrepareResultSet(dto.NewPrepareResultSetDTO(nil, keys, rowRequestColumns, numericRowSort, nil, nil))
+}
+
+func getBatchUrl(tbl taxonomy.ExtendedTableMetadata) string {
+	if tbl.HeirarchyObjects == nil || tbl.HeirarchyObjects.Resource == nil {
+		return ""
+	}
+	return tbl.HeirarchyObjects.Resource.BatchUrl
+}
+
+func (pb *primitiveGenerator) executeRowRequest(handlerCtx *handler.HandlerContext, pc plan.IPrimitiveCtx, tbl taxonomy.ExtendedTableMetadata, prov provider.IProvider, call func() (*http.Response, error)) map[string]interface{} {
+	var rowPrimitive plan.IPrimitive = primitivebuilder.NewHTTPRestPrimitive(
+		prov,
+		pb.recordOperations(handlerCtx, tbl, func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			response, apiErr := call()
+			if apiErr != nil {
+				return dto.NewExecutorOutput(nil, nil, nil, apiErr)
+			}
+			target, err := httpexec.ProcessHttpResponse(response)
+			return dto.NewExecutorOutput(nil, target, nil, err)
+		}),
+		nil,
+		nil,
+	)
+	if pb.PrimitiveBuilder.IsAwait() {
+		monitor, err := pb.composeAsyncMonitor(handlerCtx, rowPrimitive, tbl)
+		if err != nil {
+			return getRowRequestResult(nil, err)
+		}
+		if monitor != nil {
+			rowPrimitive = monitor
+		}
+	}
+	output := rowPrimitive.Execute(pc)
+	return getRowRequestResult(output.OutputBody, output.Err)
+}
+
+func getRowRequestResult(body map[string]interface{}, err error) map[string]interface{} {
+	retVal := map[string]interface{}{
+		rowRequestStatusStr:    rowRequestSucceededStr,
+		rowRequestOperationStr: "",
+		rowRequestTargetStr:    "",
+		rowRequestErrorStr:     "",
+	}
+	if body != nil {
+		if name, ok := body["name"].(string); ok {
+			retVal[rowRequestOperationStr] = name
+		}
+		if target, ok := body["targetLink"].(string); ok {
+			retVal[rowRequestTargetStr] = target
+		}
+		if opErr, ok := body["error"]; ok && err == nil {
+			err = fmt.Errorf("%s", string(util.InterfaceToBytes(opErr, true)))
+		}
+	}
+	if err != nil {
+		retVal[rowRequestStatusStr] = rowRequestFailedStr
+		retVal[rowRequestErrorStr] = err.Error()
+	}
+	return retVal
+}
@@ -0,0 +1,64 @@
+package planbuilder
+
+import (
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/localtable"
+	"infraql/internal/iql/metadata"
+	"infraql/internal/iql/parserutil"
+	"infraql/internal/iql/taxonomy"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// getStatementScopes returns the fewest OAuth scopes, per the discovery
+// documents, that authorise every API method the statement calls.  The
+// result is nil, requesting the default scopes, for statements that call
+// no methods and wherever a method cannot be resolved.
+func getStatementScopes(handlerCtx *handler.HandlerContext, stmt sqlparser.Statement) []string {
+	readOnly := handlerCtx.RuntimeContext.ReadOnlyScopes
+	var accepted [][]string
+	addMethod := func(node sqlparser.SQLNode, preferReadOnly bool) bool {
+		heirarchy, err := taxonomy.GetHeirarchyFromStatement(handlerCtx, node)
+		if err != nil || heirarchy.Method == nil {
+			return false
+		}
+		accepted = append(accepted, heirarchy.Method.GetAcceptedScopes(preferReadOnly))
+		return true
+	}
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select:
+		if _, anyLocal := localtable.ClassifyTableNames(stmt); anyLocal {
+			return nil
+		}
+		if !addMethod(stmt, readOnly) {
+			return nil
+		}
+	case *sqlparser.Insert:
+		if localtable.IsLocalTable(stmt.Table) || !addMethod(stmt, false) {
+			return nil
+		}
+		if parserutil.IsInsertFromQuery(stmt) {
+			sel, ok := stmt.Rows.(*sqlparser.Select)
+			if !ok || !addMethod(sel, readOnly) {
+				return nil
+			}
+		}
+	case *sqlparser.Delete:
+		heirarchy, err := taxonomy.GetHeirarchyFromStatement(handlerCtx, stmt)
+		if err != nil || heirarchy.Method == nil {
+			return nil
+		}
+		accepted = append(accepted, heirarchy.Method.GetAcceptedScopes(false))
+		// rows not named outright are found by selecting them first
+		if !isDirectDelete(heirarchy.Method, stmt) && !addMethod(&sqlparser.Select{From: stmt.TableExprs, Where: stmt.Where}, readOnly) {
+			return nil
+		}
+	case *sqlparser.Exec:
+		if !addMethod(stmt, false) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return metadata.GetMinimalScopes(accepted)
+}
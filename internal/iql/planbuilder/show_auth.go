@@ -0,0 +1,46 @@
+package planbuilder
+
+import (
+	"strconv"
+	"strings"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/metadata"
+	"infraql/internal/iql/provider"
+)
+
+const (
+	authProfileStr          string = "profile"
+	authStatusStr           string = "status"
+	defaultAuthProfileStr   string = "default"
+	authActiveStr           string = "active"
+	authNotAuthenticatedStr string = "not authenticated"
+)
+
+// showAuthProfiles describes every auth context for the provider,
+// one row apiece, whether or not it is presently authenticated.
+func showAuthProfiles(prov provider.IProvider, authCtxs []*dto.AuthCtx) (map[string]map[string]interface{}, []string) {
+	columnOrder := (&metadata.AuthMetadata{}).GetHeaders()
+	keys := make(map[string]map[string]interface{})
+	for i, authCtx := range authCtxs {
+		profile := authCtx.Profile
+		if profile == "" {
+			profile = defaultAuthProfileStr
+		}
+		row := map[string]interface{}{
+			"principal": "",
+			"type":      strings.ToUpper(authCtx.Type),
+			"source":    "",
+		}
+		authMeta, err := prov.ShowAuth(authCtx)
+		if err == nil && authMeta != nil {
+			row = authMeta.ToMap()
+			row[authStatusStr] = authActiveStr
+		} else {
+			row[authStatusStr] = authNotAuthenticatedStr
+		}
+		row[authProfileStr] = profile
+		keys[strconv.Itoa(i)] = row
+	}
+	return keys, append(append([]string{authProfileStr}, columnOrder...), authStatusStr)
+}
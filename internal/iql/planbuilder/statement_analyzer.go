@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"infraql/internal/iql/asyncmonitor"
 	"infraql/internal/iql/constants"
 	"infraql/internal/iql/dto"
 	"infraql/internal/iql/handler"
@@ -108,7 +109,7 @@ func (p *primitiveGenerator) analyzeAuthRevoke(handlerCtx *handler.HandlerContex
 		return authErr
 	}
 	switch strings.ToLower(authCtx.Type) {
-	case dto.AuthServiceAccountStr, dto.AuthInteractiveStr:
+	case dto.AuthServiceAccountStr, dto.AuthInteractiveStr, dto.AuthADCStr:
 		return nil
 	}
 	return fmt.Errorf(`Auth revoke for Google Failed; improper auth method: "%s" specified`, authCtx.Type)
@@ -448,7 +449,11 @@ func extractVarDefFromExec(node *sqlparser.Exec, argName string) (*sqlparser.Exe
 func (p *primitiveGenerator) parseComments(comments sqlparser.Comments) {
 	if comments != nil {
 		p.PrimitiveBuilder.SetCommentDirectives(sqlparser.ExtractCommentDirectives(comments))
-		p.PrimitiveBuilder.SetAwait(p.PrimitiveBuilder.GetCommentDirectives().IsSet("AWAIT"))
+		// AWAIT may carry parameters, eg: AWAIT(timeout=600), which
+		// the plain comment directives do not recognise
+		awaitDirective, isAwait := parserutil.ExtractFunctionDirective(comments, asyncmonitor.AwaitDirectiveStr)
+		p.PrimitiveBuilder.SetAwait(isAwait)
+		p.PrimitiveBuilder.SetAwaitDirective(awaitDirective)
 	}
 }
 
@@ -963,13 +968,16 @@ func (p *primitiveGenerator) analyzeInsert(handlerCtx *handler.HandlerContext, n
 	if err != nil {
 		return err
 	}
-	insertValOnlyRows, nonValCols, err := parserutil.ExtractInsertValColumns(node)
-	if err != nil {
-		return err
-	}
-	p.PrimitiveBuilder.SetInsertValOnlyRows(insertValOnlyRows)
-	if nonValCols > 0 {
-		return fmt.Errorf("insert not supported for anything but static values: found %d non-static values", nonValCols)
+	isFromQuery := parserutil.IsInsertFromQuery(node)
+	if !isFromQuery {
+		insertValOnlyRows, nonValCols, err := parserutil.ExtractInsertValColumns(node)
+		if err != nil {
+			return err
+		}
+		p.PrimitiveBuilder.SetInsertValOnlyRows(insertValOnlyRows)
+		if nonValCols > 0 {
+			return fmt.Errorf("insert not supported for anything but static values or a SELECT: found %d non-static values", nonValCols)
+		}
 	}
 
 	p.parseComments(node.Comments)
@@ -984,6 +992,13 @@ func (p *primitiveGenerator) analyzeInsert(handlerCtx *handler.HandlerContext, n
 		return err
 	}
 
+	if isFromQuery || len(p.PrimitiveBuilder.GetInsertValOnlyRows()) > 1 {
+		// request contexts are built per source row, at execution time
+		p.PrimitiveBuilder.SetInsertSchemaMap(sm)
+		p.PrimitiveBuilder.SetTable(node, tbl)
+		return nil
+	}
+
 	err = p.buildRequestContext(handlerCtx, node, &tbl, sm, nil)
 	if err != nil {
 		return err
@@ -1210,6 +1225,8 @@ func (p *primitiveGenerator) analyzeShow(handlerCtx *handler.HandlerContext, nod
 		return nil
 	case "PROVIDERS":
 		// TODO
+	case "OPERATIONS", "PLAN_CACHE", "VIEWS":
+		// filtering is applied at execution time
 	case "RESOURCES":
 		prov, err := handlerCtx.GetProvider(node.OnTable.Qualifier.GetRawVal())
 		if err != nil {
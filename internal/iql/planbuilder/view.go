@@ -0,0 +1,103 @@
+package planbuilder
+
+import (
+	"fmt"
+	"regexp"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/iqlutil"
+	"infraql/internal/iql/localtable"
+	"infraql/internal/iql/parse"
+	"infraql/internal/iql/plan"
+	"infraql/internal/iql/primitivebuilder"
+	"infraql/internal/iql/util"
+	"infraql/internal/iql/views"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// DROP VIEW and DROP TABLE are indistinguishable once parsed.
+var dropViewRegex *regexp.Regexp = regexp.MustCompile(`(?is)^\s*drop\s+view\s`)
+
+func isDropView(query string) bool {
+	return dropViewRegex.MatchString(query)
+}
+
+func handleCreateView(handlerCtx *handler.HandlerContext, node *parse.CreateAsSelect) (plan.IPrimitive, error) {
+	if localtable.IsLocalTable(node.Table) {
+		return nil, fmt.Errorf("views cannot be created in the '%s' namespace, which is reserved for local tables", localtable.Qualifier)
+	}
+	viewName := views.GetViewName(node.Table)
+	viewDDL := node.Body
+	return primitivebuilder.NewLocalPrimitive(
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			_, exists, err := views.Get(handlerCtx.SQLEngine, viewName)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			if exists {
+				if node.IfNotExists {
+					return messageOutput(fmt.Sprintf("view '%s' already exists, nothing written", viewName))
+				}
+				return util.GenerateSimpleErroneousOutput(fmt.Errorf("view '%s' already exists", viewName))
+			}
+			err = handlerCtx.SQLEngine.ViewStorePut(viewName, viewDDL)
+			if err != nil {
+				return util.GenerateSimpleErroneousOutput(err)
+			}
+			// cached plans may reference a table now shadowed by the view
+			handlerCtx.LRUCache.Clear()
+			return messageOutput(fmt.Sprintf("view '%s' created", viewName))
+		}), nil
+}
+
+func handleDropView(handlerCtx *handler.HandlerContext, node *sqlparser.DDL) (plan.IPrimitive, error) {
+	var viewNames []string
+	for _, tn := range node.FromTables {
+		viewNames = append(viewNames, views.GetViewName(tn))
+	}
+	return primitivebuilder.NewLocalPrimitive(
+		func(pc plan.IPrimitiveCtx) dto.ExecutorOutput {
+			for _, viewName := range viewNames {
+				deleted, err := handlerCtx.SQLEngine.ViewStoreDelete(viewName)
+				if err != nil {
+					return util.GenerateSimpleErroneousOutput(err)
+				}
+				if !deleted && !node.IfExists {
+					return util.GenerateSimpleErroneousOutput(fmt.Errorf("view '%s' does not exist", viewName))
+				}
+			}
+			handlerCtx.LRUCache.Clear()
+			return dto.NewExecutorOutput(nil, nil, nil, nil)
+		}), nil
+}
+
+func showViews(handlerCtx *handler.HandlerContext, node *sqlparser.Show) (map[string]map[string]interface{}, []string, error) {
+	var likeRegexp *regexp.Regexp
+	if node.ShowTablesOpt != nil && node.ShowTablesOpt.Filter != nil {
+		if node.ShowTablesOpt.Filter.Filter != nil {
+			return nil, nil, fmt.Errorf("SHOW VIEWS supports only a LIKE filter")
+		}
+		var err error
+		likeRegexp, err = regexp.Compile(iqlutil.TranslateLikeToRegexPattern(node.ShowTablesOpt.Filter.Like))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot compile like string '%s': %s", node.ShowTablesOpt.Filter.Like, err.Error())
+		}
+	}
+	viewList, err := handlerCtx.SQLEngine.ViewStoreGetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := make(map[string]map[string]interface{})
+	for _, kv := range viewList {
+		if likeRegexp != nil && !likeRegexp.MatchString(kv.K) {
+			continue
+		}
+		keys[kv.K] = map[string]interface{}{
+			"name":       kv.K,
+			"definition": string(kv.V),
+		}
+	}
+	return keys, []string{"name", "definition"}, nil
+}
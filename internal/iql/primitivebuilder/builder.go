@@ -96,7 +96,7 @@ func (ss *SingleSelect) Build() error {
 				ss.tableMeta.HttpArmoury.Context.SetQueryParam("maxResults", strconv.Itoa(ss.handlerCtx.RuntimeContext.HTTPMaxResults))
 			}
 		}
-		response, apiErr := httpmiddleware.HttpApiCall(*(ss.handlerCtx), prov, ss.tableMeta.HttpArmoury.Context)
+		response, apiErr := httpmiddleware.HttpApiCall(*(ss.handlerCtx), prov, ss.tableMeta.HeirarchyObjects.HeirarchyIds, ss.tableMeta.HttpArmoury.Context)
 		housekeepingDone := false
 		for {
 			if apiErr != nil {
@@ -113,6 +113,7 @@ func (ss *SingleSelect) Build() error {
 					nil,
 				))
 			}
+			ss.handlerCtx.QueryStats.AddPage()
 			log.Infoln(fmt.Sprintf("target = %v", target))
 			items, ok := target[ss.tableMeta.SelectItemsKey]
 			keys := make(map[string]map[string]interface{})
@@ -142,6 +143,9 @@ func (ss *SingleSelect) Build() error {
 							log.Infoln(fmt.Sprintf("running insert with control parameters: %v", ss.insertPreparedStatementCtx.TxnCtrlCtrs))
 							r, err := ss.drmCfg.ExecuteInsertDML(ss.handlerCtx.SQLEngine, ss.insertPreparedStatementCtx, item)
 							log.Infoln(fmt.Sprintf("insert result = %v, error = %v", r, err))
+							if err == nil {
+								ss.handlerCtx.QueryStats.AddRowInserted()
+							}
 							keys[strconv.Itoa(i)] = item
 						}
 					}
@@ -163,7 +167,7 @@ func (ss *SingleSelect) Build() error {
 				break
 			}
 			ss.tableMeta.HttpArmoury.Context.SetQueryParam(nptKey.Name, tk)
-			response, apiErr = httpmiddleware.HttpApiCall(*(ss.handlerCtx), prov, ss.tableMeta.HttpArmoury.Context)
+			response, apiErr = httpmiddleware.HttpApiCall(*(ss.handlerCtx), prov, ss.tableMeta.HeirarchyObjects.HeirarchyIds, ss.tableMeta.HttpArmoury.Context)
 		}
 		log.Infoln(fmt.Sprintf("running select with control parameters: %v", ss.selectPreparedStatementCtx.TxnCtrlCtrs))
 		r, sqlErr := ss.drmCfg.QueryDML(ss.handlerCtx.SQLEngine, ss.selectPreparedStatementCtx, nil)
@@ -233,6 +237,11 @@ func (ss *SingleSelect) Build() error {
 				}
 			}
 		}
+		if rv.Result != nil {
+			for f := range rv.Result.Fields {
+				rv.Result.Fields[f].Type = ss.selectPreparedStatementCtx.NonControlColumns[f].GetQueryType()
+			}
+		}
 		// rv.Result.Rows = rows
 		return rv
 	}
@@ -17,7 +17,8 @@ import (
 )
 
 type PrimitiveBuilder struct {
-	await bool
+	await          bool
+	awaitDirective *parserutil.FunctionDirective
 
 	ast sqlparser.Statement
 
@@ -216,6 +217,14 @@ func (pb *PrimitiveBuilder) SetAwait(await bool) {
 	pb.await = await
 }
 
+func (pb *PrimitiveBuilder) GetAwaitDirective() *parserutil.FunctionDirective {
+	return pb.awaitDirective
+}
+
+func (pb *PrimitiveBuilder) SetAwaitDirective(awaitDirective *parserutil.FunctionDirective) {
+	pb.awaitDirective = awaitDirective
+}
+
 func (pb PrimitiveBuilder) GetTable(node sqlparser.SQLNode) (taxonomy.ExtendedTableMetadata, error) {
 	return pb.tables.GetTable(node)
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"infraql/internal/iql/constants"
+	"infraql/internal/iql/credentialcache"
 	"infraql/internal/iql/discovery"
 	"infraql/internal/iql/dto"
 	sdk "infraql/internal/iql/google_sdk"
@@ -15,13 +16,17 @@ import (
 	"infraql/internal/iql/metadata"
 	"infraql/internal/iql/methodselect"
 	"infraql/internal/iql/netutils"
+	"infraql/internal/iql/oauth"
 	"infraql/internal/iql/relational"
 	"infraql/internal/iql/sqlengine"
 	"infraql/internal/iql/sqltypeutil"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -51,6 +56,7 @@ type GoogleProvider struct {
 	discoveryAdapter discovery.IDiscoveryAdapter
 	apiVersion       string
 	methodSelector   methodselect.IMethodSelector
+	credentialCache  *credentialcache.Cache
 }
 
 func (gp *GoogleProvider) GetDefaultKeyForSelectItems() string {
@@ -87,6 +93,8 @@ func (gp *GoogleProvider) inferAuthType(authCtx dto.AuthCtx, authTypeRequested s
 		return dto.AuthServiceAccountStr
 	case dto.AuthInteractiveStr:
 		return dto.AuthInteractiveStr
+	case dto.AuthADCStr:
+		return dto.AuthADCStr
 	}
 	if authCtx.KeyFilePath != "" {
 		return dto.AuthServiceAccountStr
@@ -94,22 +102,90 @@ func (gp *GoogleProvider) inferAuthType(authCtx dto.AuthCtx, authTypeRequested s
 	return dto.AuthInteractiveStr
 }
 
+// Auth returns the cached client for the auth context, only resolving
+// credentials afresh when there is none or when a login is enforced.
 func (gp *GoogleProvider) Auth(authCtx *dto.AuthCtx, authTypeRequested string, enforceRevokeFirst bool) (*http.Client, error) {
-	switch gp.inferAuthType(*authCtx, authTypeRequested) {
+	authType := gp.inferAuthType(*authCtx, authTypeRequested)
+	return gp.credentialCache.GetOrCreate(authCtx, authType, authCtx.Scopes, enforceRevokeFirst, func() (*http.Client, error) {
+		client, err := gp.baseAuth(authCtx, authType, enforceRevokeFirst)
+		if err != nil || authCtx.Impersonate == "" {
+			return client, err
+		}
+		return gp.impersonate(authCtx, client)
+	})
+}
+
+// AuthWithScopes returns a client whose tokens carry just the scopes
+// given, where the credential can be narrowed; tokens from an interactive
+// login carry whatever scopes were consented to, so are used as they are.
+// Responses replayed from a recording need no credentials at all.
+func (gp *GoogleProvider) AuthWithScopes(authCtx *dto.AuthCtx, scopes []string) (*http.Client, error) {
+	if httpexec.IsReplaying() {
+		return netutils.GetHttpClient(gp.runtimeCtx, nil), nil
+	}
+	authType := gp.inferAuthType(*authCtx, authCtx.Type)
+	if len(scopes) == 0 || (authType == dto.AuthInteractiveStr && authCtx.Impersonate == "") {
+		return gp.Auth(authCtx, authCtx.Type, false)
+	}
+	return gp.credentialCache.GetOrCreate(authCtx, authType, scopes, false, func() (*http.Client, error) {
+		scopedCtx := *authCtx
+		scopedCtx.Scopes = scopes
+		if authCtx.Impersonate == "" {
+			client, err := gp.baseAuth(&scopedCtx, authType, false)
+			if err == nil {
+				copyActivation(authCtx, &scopedCtx)
+			}
+			return client, err
+		}
+		// the base credential calls the IAM Credentials
+		// API, so keeps the auth context's own scopes
+		baseCtx := *authCtx
+		client, err := gp.baseAuth(&baseCtx, authType, false)
+		if err != nil {
+			return nil, err
+		}
+		client, err = gp.impersonate(&scopedCtx, client)
+		if err == nil {
+			copyActivation(authCtx, &baseCtx)
+		}
+		return client, err
+	})
+}
+
+func (gp *GoogleProvider) baseAuth(authCtx *dto.AuthCtx, authType string, enforceRevokeFirst bool) (*http.Client, error) {
+	switch authType {
 	case dto.AuthServiceAccountStr:
 		return gp.keyFileAuth(authCtx)
 	case dto.AuthInteractiveStr:
 		return gp.oAuth(authCtx, enforceRevokeFirst)
+	case dto.AuthADCStr:
+		return gp.adcAuth(authCtx)
 	}
 	return nil, fmt.Errorf("Could not infer auth type")
 }
 
 func (gp *GoogleProvider) AuthRevoke(authCtx *dto.AuthCtx) error {
+	gp.credentialCache.Delete(authCtx)
+	// revoking an impersonation reverts to the base credential
+	if authCtx.Impersonate != "" {
+		authCtx.Impersonate = ""
+		return nil
+	}
 	switch strings.ToLower(authCtx.Type) {
 	case dto.AuthServiceAccountStr:
 		return errors.New(constants.ServiceAccountRevokeErrStr)
+	case dto.AuthADCStr:
+		return errors.New(constants.ADCRevokeErrStr)
 	case dto.AuthInteractiveStr:
-		err := sdk.RevokeGoogleAuth()
+		var err error
+		if gp.isGcloudAuth() {
+			err = sdk.CheckInstalled()
+			if err == nil {
+				err = sdk.RevokeGoogleAuth()
+			}
+		} else {
+			err = gp.revokeOAuth(authCtx)
+		}
 		if err == nil {
 			deactivateAuth(authCtx)
 		}
@@ -180,19 +256,6 @@ func (gp *GoogleProvider) GetObjectSchema(serviceName string, resourceName strin
 	return &s, nil
 }
 
-type transport struct {
-	token               []byte
-	underlyingTransport http.RoundTripper
-}
-
-func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Add(
-		"Authorization",
-		fmt.Sprintf("Bearer %s", string(t.token)),
-	)
-	return t.underlyingTransport.RoundTrip(req)
-}
-
 func activateAuth(authCtx *dto.AuthCtx, principal string, authType string) {
 	authCtx.Active = true
 	authCtx.Type = authType
@@ -201,6 +264,15 @@ func activateAuth(authCtx *dto.AuthCtx, principal string, authType string) {
 	}
 }
 
+// copyActivation records on authCtx the outcome of
+// authenticating a copy of it.
+func copyActivation(authCtx *dto.AuthCtx, from *dto.AuthCtx) {
+	authCtx.Active = from.Active
+	authCtx.Type = from.Type
+	authCtx.ID = from.ID
+	authCtx.Source = from.Source
+}
+
 func deactivateAuth(authCtx *dto.AuthCtx) {
 	authCtx.Active = false
 }
@@ -222,64 +294,165 @@ func (gp *GoogleProvider) ShowAuth(authCtx *dto.AuthCtx) (*metadata.AuthMetadata
 			retVal = &authObj
 			activateAuth(authCtx, sa.Email, dto.AuthServiceAccountStr)
 		}
+	case dto.AuthADCStr:
+		creds, adcErr := gp.findADC(authCtx)
+		if adcErr == nil {
+			authObj = metadata.AuthMetadata{
+				Principal: creds.principal,
+				Type:      strings.ToUpper(dto.AuthADCStr),
+				Source:    creds.detail,
+			}
+			retVal = &authObj
+			activateAuth(authCtx, creds.principal, dto.AuthADCStr)
+			authCtx.Source = creds.source
+		} else {
+			log.Infoln(adcErr)
+			err = errors.New(constants.NotAuthenticatedShowStr)
+		}
 	case dto.AuthInteractiveStr:
-		principal, sdkErr := sdk.GetCurrentAuthUser()
-		if sdkErr == nil {
-			principalStr := string(principal)
-			if principalStr != "" {
-				authObj = metadata.AuthMetadata{
-					Principal: principalStr,
-					Type:      strings.ToUpper(dto.AuthInteractiveStr),
-					Source:    "OAuth",
-				}
-				retVal = &authObj
-				activateAuth(authCtx, principalStr, dto.AuthInteractiveStr)
-			} else {
-				err = errors.New(constants.NotAuthenticatedShowStr)
+		if gp.isGcloudAuth() {
+			retVal, err = gp.gcloudShowAuth(authCtx)
+			break
+		}
+		stored, loadErr := gp.getTokenStore(authCtx).Load()
+		if loadErr == nil && stored != nil {
+			authObj = metadata.AuthMetadata{
+				Principal: stored.Principal,
+				Type:      strings.ToUpper(dto.AuthInteractiveStr),
+				Source:    "OAuth",
 			}
+			retVal = &authObj
+			activateAuth(authCtx, stored.Principal, dto.AuthInteractiveStr)
 		} else {
-			log.Infoln(sdkErr)
+			if loadErr != nil {
+				log.Infoln(loadErr)
+			}
 			err = errors.New(constants.NotAuthenticatedShowStr)
 		}
 	default:
 		err = errors.New(constants.NotAuthenticatedShowStr)
 	}
+	if retVal != nil && authCtx.Impersonate != "" {
+		retVal = getImpersonationAuthMetadata(authCtx, retVal)
+	}
 	return retVal, err
 }
 
-func (gp *GoogleProvider) oAuth(authCtx *dto.AuthCtx, enforceRevokeFirst bool) (*http.Client, error) {
+func (gp *GoogleProvider) getScopes(authCtx *dto.AuthCtx) []string {
+	scopes := authCtx.Scopes
+	if scopes == nil {
+		scopes = []string{
+			constants.GoogleCloudPlatformScope,
+		}
+	}
+	return scopes
+}
+
+func (gp *GoogleProvider) getOAuthScopes(authCtx *dto.AuthCtx) []string {
+	// openid and email yield an id_token naming the principal
+	return append([]string{"openid", "email"}, gp.getScopes(authCtx)...)
+}
+
+func (gp *GoogleProvider) getOAuthConfig(authCtx *dto.AuthCtx) oauth.Config {
+	return oauth.Config{
+		ClientID:      gp.runtimeCtx.OAuthClientID,
+		ClientSecret:  gp.runtimeCtx.OAuthClientSecret,
+		AuthURL:       gp.runtimeCtx.OAuthAuthURL,
+		TokenURL:      gp.runtimeCtx.OAuthTokenURL,
+		DeviceAuthURL: gp.runtimeCtx.OAuthDeviceAuthURL,
+		RevokeURL:     gp.runtimeCtx.OAuthRevokeURL,
+		Scopes:        gp.getOAuthScopes(authCtx),
+	}
+}
+
+// getTokenStore keeps each credential profile's interactive login apart.
+func (gp *GoogleProvider) getTokenStore(authCtx *dto.AuthCtx) *oauth.TokenStore {
+	fileName := constants.OAuthTokenFileName
+	if authCtx.Profile != "" {
+		fileName = authCtx.Profile + "_" + fileName
+	}
+	return oauth.NewTokenStore(filepath.Join(gp.runtimeCtx.ProviderRootPath, googleProviderName, fileName))
+}
+
+func (gp *GoogleProvider) getOAuthContext() context.Context {
+	return context.WithValue(context.Background(), oauth2.HTTPClient, netutils.GetHttpClient(gp.runtimeCtx, nil))
+}
+
+func (gp *GoogleProvider) runOAuthFlow(authCtx *dto.AuthCtx) (*oauth.StoredToken, error) {
+	if gp.runtimeCtx.OAuthClientID == "" {
+		return nil, fmt.Errorf("interactive auth requires an OAuth client id and this build has none; supply one with --%s, or use --%s=%s to authenticate with the Google Cloud SDK", dto.OAuthClientIDKey, dto.OAuthFlowKey, dto.OAuthFlowGcloudStr)
+	}
+	ctx, cancel := context.WithTimeout(gp.getOAuthContext(), 10*time.Minute)
+	defer cancel()
+	cfg := gp.getOAuthConfig(authCtx)
+	var tok *oauth2.Token
 	var err error
-	var tokenBytes []byte
-	tokenBytes, err = sdk.GetAccessToken()
-	if enforceRevokeFirst && authCtx.Type == dto.AuthInteractiveStr && err == nil {
-		return nil, fmt.Errorf(constants.OAuthInteractiveAuthErrStr)
+	switch strings.ToLower(gp.runtimeCtx.OAuthFlow) {
+	case dto.OAuthFlowDeviceStr:
+		tok, err = oauth.DeviceFlow(ctx, cfg, os.Stderr)
+	case dto.OAuthFlowAuthCodeStr, "":
+		tok, err = oauth.AuthCodeFlow(ctx, cfg, oauth.OpenBrowser, os.Stderr)
+	default:
+		return nil, fmt.Errorf("unsupported oauth flow '%s', must be one of {'%s', '%s', '%s'}", gp.runtimeCtx.OAuthFlow, dto.OAuthFlowAuthCodeStr, dto.OAuthFlowDeviceStr, dto.OAuthFlowGcloudStr)
 	}
 	if err != nil {
-		err = sdk.OAuthToGoogle()
-		if err == nil {
-			tokenBytes, err = sdk.GetAccessToken()
-		}
+		return nil, err
 	}
+	stored := &oauth.StoredToken{
+		Principal: oauth.GetPrincipal(tok),
+		Token:     tok,
+	}
+	return stored, gp.getTokenStore(authCtx).Save(stored)
+}
+
+func (gp *GoogleProvider) revokeOAuth(authCtx *dto.AuthCtx) error {
+	store := gp.getTokenStore(authCtx)
+	stored, err := store.Load()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if stored == nil {
+		return errors.New(constants.NotAuthenticatedShowStr)
 	}
-	activateAuth(authCtx, "", dto.AuthInteractiveStr)
-	client := netutils.GetHttpClient(gp.runtimeCtx, nil)
-	client.Transport = &transport{
-		token:               tokenBytes,
-		underlyingTransport: client.Transport,
+	fmt.Fprintln(os.Stderr, "Revoking Google credentials...")
+	revokeErr := oauth.Revoke(gp.getOAuthContext(), gp.getOAuthConfig(&dto.AuthCtx{}), stored.Token)
+	if err := store.Delete(); err != nil {
+		return err
 	}
-	return client, nil
+	if revokeErr != nil {
+		return fmt.Errorf("Auth revoke for Google Failed: local credentials removed, but %s", revokeErr.Error())
+	}
+	return nil
 }
 
-func (gp *GoogleProvider) keyFileAuth(authCtx *dto.AuthCtx) (*http.Client, error) {
-	scopes := authCtx.Scopes
-	if scopes == nil {
-		scopes = []string{
-			"https://www.googleapis.com/auth/cloud-platform",
+func (gp *GoogleProvider) oAuth(authCtx *dto.AuthCtx, enforceRevokeFirst bool) (*http.Client, error) {
+	if gp.isGcloudAuth() {
+		return gp.gcloudAuth(authCtx, enforceRevokeFirst)
+	}
+	store := gp.getTokenStore(authCtx)
+	stored, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if enforceRevokeFirst && authCtx.Type == dto.AuthInteractiveStr && stored != nil {
+		return nil, fmt.Errorf(constants.OAuthInteractiveAuthErrStr)
+	}
+	if stored == nil {
+		stored, err = gp.runOAuthFlow(authCtx)
+		if err != nil {
+			return nil, err
 		}
 	}
-	return serviceAccount(authCtx, scopes, gp.runtimeCtx)
+	activateAuth(authCtx, stored.Principal, dto.AuthInteractiveStr)
+	return newAuthenticatedClient(
+		gp.runtimeCtx,
+		oauth.RefreshTokenSource(gp.getOAuthContext(), gp.getOAuthConfig(authCtx), store, stored),
+		stored.Token,
+	), nil
+}
+
+func (gp *GoogleProvider) keyFileAuth(authCtx *dto.AuthCtx) (*http.Client, error) {
+	return serviceAccount(authCtx, gp.getScopes(authCtx), gp.runtimeCtx)
 }
 
 func (gp *GoogleProvider) getServiceType(service metadata.Service) string {
@@ -519,7 +692,25 @@ func serviceAccount(authCtx *dto.AuthCtx, scopes []string, runtimeCtx dto.Runtim
 	if DummyAuth {
 		// return httpClient, nil
 	}
-	return config.Client(context.WithValue(oauth2.NoContext, oauth2.HTTPClient, httpClient)), nil
+	ctx := context.WithValue(oauth2.NoContext, oauth2.HTTPClient, httpClient)
+	return newAuthenticatedClient(
+		runtimeCtx,
+		credentialcache.TokenSourceFunc(func() (*oauth2.Token, error) {
+			return config.TokenSource(ctx).Token()
+		}),
+		nil,
+	), nil
+}
+
+// newAuthenticatedClient returns a client presenting tokens from mint,
+// which is only consulted as the token in hand nears expiry.
+func newAuthenticatedClient(runtimeCtx dto.RuntimeCtx, mint oauth2.TokenSource, initial *oauth2.Token) *http.Client {
+	client := netutils.GetHttpClient(runtimeCtx, http.DefaultClient)
+	client.Transport = &oauth2.Transport{
+		Source: credentialcache.NewRefreshingTokenSource(mint, initial, credentialcache.DefaultRefreshMargin),
+		Base:   client.Transport,
+	}
+	return client
 }
 
 func (gp *GoogleProvider) GenerateHTTPRestInstruction(httpContext httpexec.IHttpContext) (httpexec.IHttpContext, error) {
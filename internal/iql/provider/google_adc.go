@@ -0,0 +1,217 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"infraql/internal/iql/credentialcache"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/netutils"
+	"infraql/internal/iql/oauth"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Application Default Credentials are resolved in the conventional order:
+// the file named by GOOGLE_APPLICATION_CREDENTIALS, the well known file
+// written by "gcloud auth application-default login", and finally the
+// GCE / GKE metadata server.
+const (
+	adcEnvVar              string = "GOOGLE_APPLICATION_CREDENTIALS"
+	adcWellKnownFileName   string = "application_default_credentials.json"
+	adcMetadataProbeSecs   int    = 2
+	metadataFlavorHeader   string = "Metadata-Flavor"
+	metadataFlavorGoogle   string = "Google"
+	metadataServiceAccount string = "/computeMetadata/v1/instance/service-accounts/default"
+	// user credentials, as written by "gcloud auth application-default login",
+	// name no principal; the placeholder stands in where no token names one
+	adcAuthorizedUserType      string = "authorized_user"
+	adcAuthorizedUserPrincipal string = "adc:authorized_user"
+)
+
+type adcCredentials struct {
+	tokenSource oauth2.TokenSource
+	token       *oauth2.Token
+	principal   string
+	source      string
+	detail      string
+}
+
+type adcCredentialsFile struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+}
+
+func getADCWellKnownFilePath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", adcWellKnownFileName)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", adcWellKnownFileName)
+}
+
+func (gp *GoogleProvider) getADCFromFile(path string, scopes []string) (*adcCredentials, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cf adcCredentialsFile
+	if err := json.Unmarshal(b, &cf); err != nil {
+		return nil, fmt.Errorf("cannot parse credentials file '%s': %s", path, err.Error())
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, netutils.GetHttpClient(gp.runtimeCtx, http.DefaultClient))
+	initialCreds, err := google.CredentialsFromJSON(ctx, b, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	principal := cf.ClientEmail
+	var tok *oauth2.Token
+	if cf.Type == adcAuthorizedUserType {
+		// the user is named by the id_token accompanying an access token,
+		// which is then kept for the first requests
+		principal = adcAuthorizedUserPrincipal
+		if tok, err = initialCreds.TokenSource.Token(); err == nil {
+			if email := oauth.GetPrincipal(tok); email != "" {
+				principal = email
+			}
+		} else {
+			log.Infoln(err)
+			tok = nil
+		}
+	}
+	return &adcCredentials{
+		// a fresh token source holds no token, so always fetches one
+		tokenSource: credentialcache.TokenSourceFunc(func() (*oauth2.Token, error) {
+			creds, err := google.CredentialsFromJSON(ctx, b, scopes...)
+			if err != nil {
+				return nil, err
+			}
+			return creds.TokenSource.Token()
+		}),
+		token:     tok,
+		principal: principal,
+		detail:    fmt.Sprintf("%s (%s)", cf.Type, path),
+	}, nil
+}
+
+type metadataTokenSource struct {
+	client   *http.Client
+	tokenURL string
+}
+
+func (m *metadataTokenSource) Token() (*oauth2.Token, error) {
+	b, err := getFromMetadataServer(m.client, m.tokenURL)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, fmt.Errorf("cannot parse metadata server token: %s", err.Error())
+	}
+	if resp.AccessToken == "" {
+		return nil, fmt.Errorf("metadata server returned no access token")
+	}
+	return &oauth2.Token{
+		AccessToken: resp.AccessToken,
+		TokenType:   resp.TokenType,
+		Expiry:      time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+	}, nil
+}
+
+func getFromMetadataServer(client *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(metadataFlavorHeader, metadataFlavorGoogle)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata server request '%s' failed with status %d", u, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// getADCFromMetadataServer probes the metadata server for the default
+// service account, so that its absence is detected promptly.  The
+// metadata server is link local and never reached through a proxy.
+func (gp *GoogleProvider) getADCFromMetadataServer(scopes []string) (*adcCredentials, error) {
+	baseURL := strings.TrimSuffix(gp.runtimeCtx.GCEMetadataURL, "/")
+	probeClient := &http.Client{Timeout: time.Duration(adcMetadataProbeSecs) * time.Second}
+	email, err := getFromMetadataServer(probeClient, baseURL+metadataServiceAccount+"/email")
+	if err != nil {
+		return nil, err
+	}
+	tokenURL := baseURL + metadataServiceAccount + "/token"
+	if len(scopes) > 0 {
+		tokenURL += "?" + url.Values{"scopes": {strings.Join(scopes, ",")}}.Encode()
+	}
+	return &adcCredentials{
+		tokenSource: &metadataTokenSource{
+			client:   &http.Client{Timeout: time.Second * time.Duration(gp.runtimeCtx.APIRequestTimeout)},
+			tokenURL: tokenURL,
+		},
+		principal: strings.TrimSpace(string(email)),
+		source:    dto.ADCSourceMetadataStr,
+		detail:    fmt.Sprintf("metadata server (%s)", baseURL),
+	}, nil
+}
+
+func (gp *GoogleProvider) findADC(authCtx *dto.AuthCtx) (*adcCredentials, error) {
+	scopes := gp.getScopes(authCtx)
+	if path := os.Getenv(adcEnvVar); path != "" {
+		creds, err := gp.getADCFromFile(path, scopes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use credentials named by %s: %s", adcEnvVar, err.Error())
+		}
+		creds.source = dto.ADCSourceEnvStr
+		creds.detail = adcEnvVar + ": " + creds.detail
+		return creds, nil
+	}
+	path := getADCWellKnownFilePath()
+	creds, err := gp.getADCFromFile(path, scopes)
+	if err == nil {
+		creds.source = dto.ADCSourceWellKnownStr
+		return creds, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot use well known credentials file: %s", err.Error())
+	}
+	creds, err = gp.getADCFromMetadataServer(scopes)
+	if err != nil {
+		return nil, fmt.Errorf("application default credentials not found; set %s, create %s or run on a host with a metadata server: %s", adcEnvVar, path, err.Error())
+	}
+	return creds, nil
+}
+
+func (gp *GoogleProvider) adcAuth(authCtx *dto.AuthCtx) (*http.Client, error) {
+	creds, err := gp.findADC(authCtx)
+	if err != nil {
+		return nil, err
+	}
+	activateAuth(authCtx, creds.principal, dto.AuthADCStr)
+	authCtx.Source = creds.source
+	return newAuthenticatedClient(gp.runtimeCtx, creds.tokenSource, creds.token), nil
+}
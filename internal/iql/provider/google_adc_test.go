@@ -0,0 +1,230 @@
+package provider_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"infraql/internal/iql/config"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/entryutil"
+	. "infraql/internal/iql/provider"
+	"infraql/internal/iql/util"
+	"infraql/internal/test/infraqltestutil"
+	"infraql/internal/test/testhttpapi"
+)
+
+const (
+	metadataServiceAccountEmail string = "vm-sa@testing-project.iam.gserviceaccount.com"
+	metadataAccessToken         string = "metadata-token"
+)
+
+// metadataServer stands in for the GCE metadata server,
+// counting the requests made of it.
+type metadataServer struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (ms *metadataServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ms.mu.Lock()
+	ms.requests++
+	ms.mu.Unlock()
+	if r.Header.Get("Metadata-Flavor") != "Google" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.URL.Path {
+	case "/computeMetadata/v1/instance/service-accounts/default/email":
+		fmt.Fprint(w, metadataServiceAccountEmail)
+	case "/computeMetadata/v1/instance/service-accounts/default/token":
+		fmt.Fprintf(w, `{"access_token": "%s", "expires_in": 3600, "token_type": "Bearer"}`, metadataAccessToken)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (ms *metadataServer) getRequestCount() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.requests
+}
+
+// writeServiceAccountKey writes the dummy service account key
+// to the path given, with the client email given.
+func writeServiceAccountKey(t *testing.T, path string, email string) {
+	keyPath, err := util.GetFilePathFromRepositoryRoot("test/assets/credentials/dummy/google/dummy-sa-key.json")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	b, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	key := make(map[string]interface{})
+	if err := json.Unmarshal(b, &key); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	key["client_email"] = email
+	b, err = json.Marshal(key)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+func getTestProvider(t *testing.T, metadataURL string, iamCredentialsURL string) IProvider {
+	runtimeCtx, err := infraqltestutil.GetRuntimeCtx(config.GetGoogleProviderString(), "text")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	runtimeCtx.GCEMetadataURL = metadataURL
+	runtimeCtx.IAMCredentialsURL = iamCredentialsURL
+	runtimeCtx.APIRequestTimeout = 5
+	sqlEngine, err := entryutil.BuildSQLEngine(*runtimeCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	prov, err := NewGoogleProvider(*runtimeCtx, config.GetGoogleProviderString(), sqlEngine)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return prov
+}
+
+func TestADCChainOrder(t *testing.T) {
+	ms := &metadataServer{}
+	server := httptest.NewServer(ms)
+	defer server.Close()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	wellKnownPath := filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+	envPath := filepath.Join(t.TempDir(), "env-key.json")
+
+	// the metadata server is the last resort
+	prov := getTestProvider(t, server.URL, "")
+	authCtx := &dto.AuthCtx{Type: dto.AuthADCStr}
+	authMeta, err := prov.ShowAuth(authCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if authMeta.Principal != metadataServiceAccountEmail || authCtx.Source != dto.ADCSourceMetadataStr || !strings.Contains(authMeta.Source, server.URL) {
+		t.Fatalf("Test failed: metadata server credentials reported as %v, source '%s'", authMeta, authCtx.Source)
+	}
+
+	// tokens from the metadata server authorise requests
+	var authHeader string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+	}))
+	defer api.Close()
+	client, err := prov.Auth(&dto.AuthCtx{Type: dto.AuthADCStr}, dto.AuthADCStr, false)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	resp, err := client.Get(api.URL)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	resp.Body.Close()
+	if authHeader != "Bearer "+metadataAccessToken {
+		t.Fatalf("Test failed: request authorised with '%s'", authHeader)
+	}
+
+	// the well known file is preferred to the metadata server
+	writeServiceAccountKey(t, wellKnownPath, "wellknown-sa@testing-project.iam.gserviceaccount.com")
+	requestCount := ms.getRequestCount()
+	authCtx = &dto.AuthCtx{Type: dto.AuthADCStr}
+	authMeta, err = prov.ShowAuth(authCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if authMeta.Principal != "wellknown-sa@testing-project.iam.gserviceaccount.com" || authCtx.Source != dto.ADCSourceWellKnownStr || !strings.Contains(authMeta.Source, wellKnownPath) {
+		t.Fatalf("Test failed: well known file credentials reported as %v, source '%s'", authMeta, authCtx.Source)
+	}
+	if ms.getRequestCount() != requestCount {
+		t.Fatalf("Test failed: metadata server consulted despite the well known file")
+	}
+
+	// the file named by the environment is preferred to the well known file
+	writeServiceAccountKey(t, envPath, "env-sa@testing-project.iam.gserviceaccount.com")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", envPath)
+	authCtx = &dto.AuthCtx{Type: dto.AuthADCStr}
+	authMeta, err = prov.ShowAuth(authCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if authMeta.Principal != "env-sa@testing-project.iam.gserviceaccount.com" || authCtx.Source != dto.ADCSourceEnvStr || !strings.Contains(authMeta.Source, "GOOGLE_APPLICATION_CREDENTIALS") {
+		t.Fatalf("Test failed: environment credentials reported as %v, source '%s'", authMeta, authCtx.Source)
+	}
+
+	// a missing file named by the environment is an error, not skipped
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "absent.json"))
+	if _, err := prov.ShowAuth(&dto.AuthCtx{Type: dto.AuthADCStr}); err == nil {
+		t.Fatalf("Test failed: expected error for missing credentials file named by the environment")
+	}
+}
+
+// userTokenServer stands in for the Google token endpoint, refreshing user
+// credentials with an id_token naming the user where idTokenEmail is set.
+type userTokenServer struct {
+	idTokenEmail string
+}
+
+func (s *userTokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Host != "oauth2.googleapis.com" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	resp := map[string]interface{}{"access_token": "user-token", "expires_in": 3600, "token_type": "Bearer"}
+	if s.idTokenEmail != "" {
+		claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"email": "%s"}`, s.idTokenEmail)))
+		resp["id_token"] = "e30." + claims + ".c2ln"
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestADCAuthorizedUserPrincipal(t *testing.T) {
+	defer func(rt http.RoundTripper) { http.DefaultClient.Transport = rt }(http.DefaultClient.Transport)
+	keyPath := filepath.Join(t.TempDir(), "user-key.json")
+	userKey := `{"type": "authorized_user", "client_id": "client-id", "client_secret": "client-secret", "refresh_token": "refresh-token"}`
+	if err := ioutil.WriteFile(keyPath, []byte(userKey), 0600); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", keyPath)
+	prov := getTestProvider(t, "", "")
+
+	// the user is named by the id_token of the refreshed credentials
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(&userTokenServer{idTokenEmail: "someone@example.com"}, nil)
+	authMeta, err := prov.ShowAuth(&dto.AuthCtx{Type: dto.AuthADCStr})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if authMeta.Principal != "someone@example.com" {
+		t.Fatalf("Test failed: user credentials reported as %v", authMeta)
+	}
+
+	// absent an id_token, the principal is a placeholder rather than empty
+	http.DefaultClient.Transport = testhttpapi.NewHandlerTransport(&userTokenServer{}, nil)
+	authMeta, err = prov.ShowAuth(&dto.AuthCtx{Type: dto.AuthADCStr})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if authMeta.Principal != "adc:authorized_user" {
+		t.Fatalf("Test failed: user credentials without id_token reported as %v", authMeta)
+	}
+}
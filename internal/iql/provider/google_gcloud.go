@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"infraql/internal/iql/constants"
+	"infraql/internal/iql/dto"
+	sdk "infraql/internal/iql/google_sdk"
+	"infraql/internal/iql/metadata"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// With --oauth.flow=gcloud, interactive auth is delegated to the Google
+// Cloud SDK, as it was before the native OAuth flows; gcloud must then
+// be installed.
+
+// gcloud does not say when its tokens expire, so each is taken to expire
+// after gcloudTokenLifetime, whereupon gcloud is asked again and hands out
+// the same token or, near its real expiry, a refreshed one.
+const gcloudTokenLifetime time.Duration = 15 * time.Minute
+
+func (gp *GoogleProvider) isGcloudAuth() bool {
+	return strings.ToLower(gp.runtimeCtx.OAuthFlow) == dto.OAuthFlowGcloudStr
+}
+
+type gcloudTokenSource struct{}
+
+func newGcloudToken(tokenBytes []byte) *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken: string(tokenBytes),
+		TokenType:   "Bearer",
+		Expiry:      time.Now().Add(gcloudTokenLifetime),
+	}
+}
+
+func (gts gcloudTokenSource) Token() (*oauth2.Token, error) {
+	tokenBytes, err := sdk.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return newGcloudToken(tokenBytes), nil
+}
+
+func (gp *GoogleProvider) gcloudAuth(authCtx *dto.AuthCtx, enforceRevokeFirst bool) (*http.Client, error) {
+	if err := sdk.CheckInstalled(); err != nil {
+		return nil, err
+	}
+	tokenBytes, err := sdk.GetAccessToken()
+	if enforceRevokeFirst && authCtx.Type == dto.AuthInteractiveStr && err == nil {
+		return nil, fmt.Errorf(constants.OAuthInteractiveAuthErrStr)
+	}
+	if err != nil {
+		err = sdk.OAuthToGoogle()
+		if err == nil {
+			tokenBytes, err = sdk.GetAccessToken()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	principal, _ := sdk.GetCurrentAuthUser()
+	activateAuth(authCtx, string(principal), dto.AuthInteractiveStr)
+	return newAuthenticatedClient(
+		gp.runtimeCtx,
+		gcloudTokenSource{},
+		newGcloudToken(tokenBytes),
+	), nil
+}
+
+func (gp *GoogleProvider) gcloudShowAuth(authCtx *dto.AuthCtx) (*metadata.AuthMetadata, error) {
+	if err := sdk.CheckInstalled(); err != nil {
+		return nil, err
+	}
+	principal, sdkErr := sdk.GetCurrentAuthUser()
+	if sdkErr != nil {
+		log.Infoln(sdkErr)
+		return nil, errors.New(constants.NotAuthenticatedShowStr)
+	}
+	principalStr := string(principal)
+	if principalStr == "" {
+		return nil, errors.New(constants.NotAuthenticatedShowStr)
+	}
+	activateAuth(authCtx, principalStr, dto.AuthInteractiveStr)
+	return &metadata.AuthMetadata{
+		Principal: principalStr,
+		Type:      strings.ToUpper(dto.AuthInteractiveStr),
+		Source:    "gcloud",
+	}, nil
+}
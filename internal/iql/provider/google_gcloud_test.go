@@ -0,0 +1,54 @@
+package provider_test
+
+import (
+	"strings"
+	"testing"
+
+	"infraql/internal/iql/config"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/entryutil"
+	. "infraql/internal/iql/provider"
+	"infraql/internal/test/infraqltestutil"
+)
+
+func getInteractiveTestProvider(t *testing.T, oauthFlow string, oauthClientID string) IProvider {
+	runtimeCtx, err := infraqltestutil.GetRuntimeCtx(config.GetGoogleProviderString(), "text")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	runtimeCtx.ProviderRootPath = t.TempDir()
+	runtimeCtx.OAuthFlow = oauthFlow
+	runtimeCtx.OAuthClientID = oauthClientID
+	sqlEngine, err := entryutil.BuildSQLEngine(*runtimeCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	prov, err := NewGoogleProvider(*runtimeCtx, config.GetGoogleProviderString(), sqlEngine)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return prov
+}
+
+func TestInteractiveAuthFlowSelection(t *testing.T) {
+	// no gcloud to be found
+	t.Setenv("PATH", t.TempDir())
+
+	prov := getInteractiveTestProvider(t, dto.OAuthFlowGcloudStr, "some-client-id")
+	_, err := prov.Auth(&dto.AuthCtx{Type: dto.AuthInteractiveStr}, dto.AuthInteractiveStr, false)
+	if err == nil || !strings.Contains(err.Error(), "gcloud was not found") {
+		t.Fatalf("Test failed: expected an error stating gcloud is not installed, got %v", err)
+	}
+	if _, err := prov.ShowAuth(&dto.AuthCtx{Type: dto.AuthInteractiveStr}); err == nil || !strings.Contains(err.Error(), "gcloud was not found") {
+		t.Fatalf("Test failed: expected an error stating gcloud is not installed, got %v", err)
+	}
+
+	// the native flows are not delegated to gcloud, client id or not
+	for _, flow := range []string{"", dto.OAuthFlowAuthCodeStr, dto.OAuthFlowDeviceStr} {
+		prov = getInteractiveTestProvider(t, flow, "")
+		_, err = prov.Auth(&dto.AuthCtx{Type: dto.AuthInteractiveStr}, dto.AuthInteractiveStr, false)
+		if err == nil || !strings.Contains(err.Error(), dto.OAuthClientIDKey) {
+			t.Fatalf("Test failed: expected an error naming --%s for flow '%s', got %v", dto.OAuthClientIDKey, flow, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/metadata"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	impersonationLifetimeSecs int = 3600
+)
+
+type generateAccessTokenRequest struct {
+	Scope    []string `json:"scope"`
+	Lifetime string   `json:"lifetime"`
+}
+
+type generateAccessTokenResponse struct {
+	AccessToken string    `json:"accessToken"`
+	ExpireTime  time.Time `json:"expireTime"`
+}
+
+// impersonationTokenSource exchanges the base credential, by way of
+// the IAM Credentials API, for tokens belonging to the target principal.
+type impersonationTokenSource struct {
+	baseClient *http.Client
+	url        string
+	scopes     []string
+}
+
+func (its *impersonationTokenSource) Token() (*oauth2.Token, error) {
+	b, err := json.Marshal(generateAccessTokenRequest{
+		Scope:    its.scopes,
+		Lifetime: fmt.Sprintf("%ds", impersonationLifetimeSecs),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := its.baseClient.Post(its.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("impersonation failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var gat generateAccessTokenResponse
+	if err := json.Unmarshal(body, &gat); err != nil {
+		return nil, fmt.Errorf("cannot parse impersonation response: %s", err.Error())
+	}
+	return &oauth2.Token{
+		AccessToken: gat.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      gat.ExpireTime,
+	}, nil
+}
+
+func (gp *GoogleProvider) getGenerateAccessTokenURL(principal string) string {
+	return fmt.Sprintf(
+		"%s/v1/projects/-/serviceAccounts/%s:generateAccessToken",
+		strings.TrimSuffix(gp.runtimeCtx.IAMCredentialsURL, "/"),
+		url.PathEscape(principal),
+	)
+}
+
+// impersonate wraps an authenticated client for the base credential
+// in one that acts as authCtx.Impersonate.  The first token is
+// fetched eagerly so that a denied impersonation fails at once.
+func (gp *GoogleProvider) impersonate(authCtx *dto.AuthCtx, baseClient *http.Client) (*http.Client, error) {
+	mint := &impersonationTokenSource{
+		baseClient: baseClient,
+		url:        gp.getGenerateAccessTokenURL(authCtx.Impersonate),
+		scopes:     gp.getScopes(authCtx),
+	}
+	initial, err := mint.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newAuthenticatedClient(gp.runtimeCtx, mint, initial), nil
+}
+
+func getImpersonationAuthMetadata(authCtx *dto.AuthCtx, base *metadata.AuthMetadata) *metadata.AuthMetadata {
+	return &metadata.AuthMetadata{
+		Principal: authCtx.Impersonate,
+		Type:      strings.ToUpper(dto.AuthImpersonateStr),
+		Source:    fmt.Sprintf("%s (%s)", base.Principal, base.Type),
+	}
+}
@@ -0,0 +1,109 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"infraql/internal/iql/dto"
+)
+
+const impersonationTarget string = "target-sa@testing-project.iam.gserviceaccount.com"
+
+// iamCredentialsServer stands in for the IAM Credentials API, handing out
+// numbered tokens, the first of which expires within the refresh margin.
+type iamCredentialsServer struct {
+	mu     sync.Mutex
+	minted int
+}
+
+func (s *iamCredentialsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != fmt.Sprintf("/v1/projects/-/serviceAccounts/%s:generateAccessToken", impersonationTarget) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+metadataAccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Scope    []string `json:"scope"`
+		Lifetime string   `json:"lifetime"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Scope) == 0 || req.Lifetime != "3600s" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.minted++
+	minted := s.minted
+	s.mu.Unlock()
+	lifetime := time.Hour
+	if minted == 1 {
+		lifetime = 2 * time.Minute
+	}
+	fmt.Fprintf(w, `{"accessToken": "impersonated-token-%d", "expireTime": "%s"}`, minted, time.Now().Add(lifetime).UTC().Format(time.RFC3339))
+}
+
+func (s *iamCredentialsServer) getMinted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.minted
+}
+
+func TestImpersonation(t *testing.T) {
+	metadata := httptest.NewServer(&metadataServer{})
+	defer metadata.Close()
+	iam := &iamCredentialsServer{}
+	iamServer := httptest.NewServer(iam)
+	defer iamServer.Close()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	prov := getTestProvider(t, metadata.URL, iamServer.URL)
+
+	authCtx := &dto.AuthCtx{Type: dto.AuthADCStr, Impersonate: impersonationTarget}
+	authMeta, err := prov.ShowAuth(authCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if authMeta.Principal != impersonationTarget || authMeta.Type != "IMPERSONATE" || authMeta.Source != metadataServiceAccountEmail+" (ADC)" {
+		t.Fatalf("Test failed: impersonation reported as %v", authMeta)
+	}
+
+	client, err := prov.Auth(authCtx, dto.AuthADCStr, false)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	// the first token is fetched eagerly
+	if iam.getMinted() != 1 {
+		t.Fatalf("Test failed: %d tokens minted on auth, expected 1", iam.getMinted())
+	}
+
+	var authHeaders []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(api.URL)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	// the first token, within the refresh margin of expiry though not
+	// yet expired, is replaced before use and its replacement reused
+	if iam.getMinted() != 2 {
+		t.Fatalf("Test failed: %d tokens minted, expected 2", iam.getMinted())
+	}
+	for _, h := range authHeaders {
+		if h != "Bearer impersonated-token-2" {
+			t.Fatalf("Test failed: requests authorised with %v", authHeaders)
+		}
+	}
+}
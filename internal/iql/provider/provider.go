@@ -5,6 +5,7 @@ import (
 	"infraql/internal/iql/cache"
 	"infraql/internal/iql/config"
 	"infraql/internal/iql/constants"
+	"infraql/internal/iql/credentialcache"
 	"infraql/internal/iql/discovery"
 	"infraql/internal/iql/dto"
 	"infraql/internal/iql/googlediscovery"
@@ -46,6 +47,8 @@ type IProvider interface {
 
 	AuthRevoke(authCtx *dto.AuthCtx) error
 
+	AuthWithScopes(authCtx *dto.AuthCtx, scopes []string) (*http.Client, error)
+
 	CheckServiceAccountFile(credentialFile string) error
 
 	DescribeResource(serviceName string, resourceName string, runtimeCtx dto.RuntimeCtx, extended bool, full bool) (*metadata.Schema, []string, error)
@@ -133,7 +136,8 @@ func NewGoogleProvider(rtCtx dto.RuntimeCtx, providerStr string, dbEngine sqleng
 	}
 
 	gp := &GoogleProvider{
-		runtimeCtx: rtCtx,
+		runtimeCtx:      rtCtx,
+		credentialCache: credentialcache.NewCache(),
 		discoveryAdapter: discovery.NewBasicDiscoveryAdapter(
 			rtCtx.ProviderStr, // TODO: allow multiple
 			constants.GoogleV1DiscoveryDoc,
@@ -1,25 +1,109 @@
 package querysubmit
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"infraql/internal/iql/dto"
 	"infraql/internal/iql/handler"
+	"infraql/internal/iql/parserutil"
 	"infraql/internal/iql/planbuilder"
 
+	"vitess.io/vitess/go/vt/sqlparser"
+
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	authDirectiveStr string = "AUTH"
+)
+
 func SubmitQuery(handlerCtx *handler.HandlerContext) dto.ExecutorOutput {
 	log.Debugln("SubmitQuery() invoked...")
+	start := time.Now()
+	handlerCtx.QueryStats.Reset()
+	handlerCtx.AuthProfile = getAuthProfileForQuery(handlerCtx.Query)
+	handlerCtx.Scopes = nil
 	plan, err := planbuilder.BuildPlanFromContext(handlerCtx)
 	if err != nil {
+		reportTiming(handlerCtx, time.Since(start))
 		return dto.NewExecutorOutput(nil, nil, nil, err)
 	}
+	handlerCtx.Scopes = plan.Scopes
 	pl := dto.NewBasicPrimitiveContext(
 		nil,
 		nil,
-		handlerCtx.Outfile,
+		handlerCtx.GetStatusFile(),
 		handlerCtx.OutErrFile,
 		nil,
 	)
-	return plan.Instructions.Execute(pl)
+	if handlerCtx.Context != nil {
+		pl.SetContext(handlerCtx.Context)
+	}
+	if handlerCtx.ThrottleStats != nil {
+		handlerCtx.ThrottleStats.Reset()
+	}
+	output := plan.Instructions.Execute(pl)
+	elapsed := time.Since(start)
+	rows := 0
+	if output.Result != nil {
+		rows = len(output.Result.Rows)
+	}
+	plan.AddStats(elapsed, rows, output.Err, handlerCtx.QueryStats.Get())
+	reportThrottling(handlerCtx)
+	reportTiming(handlerCtx, elapsed)
+	return output
+}
+
+// reportTiming tells users who ask, with --timing or \timing in the
+// shell, how long the statement took and what it asked of the API.
+func reportTiming(handlerCtx *handler.HandlerContext, elapsed time.Duration) {
+	if !handlerCtx.RuntimeContext.Timing || handlerCtx.OutErrFile == nil {
+		return
+	}
+	stats := handlerCtx.QueryStats.Get()
+	fmt.Fprintf(
+		handlerCtx.OutErrFile,
+		"Time: %.3f ms; http calls: %d, pages: %d, bytes received: %d, rows inserted: %d, retries: %d\n",
+		float64(elapsed.Microseconds())/1000,
+		stats.HTTPCalls,
+		stats.Pages,
+		stats.BytesReceived,
+		stats.RowsInserted,
+		stats.Retries,
+	)
+}
+
+// reportThrottling tells verbose users how long the statement
+// was held back by client side rate limits.
+func reportThrottling(handlerCtx *handler.HandlerContext) {
+	if !handlerCtx.RuntimeContext.VerboseFlag || handlerCtx.ThrottleStats == nil || handlerCtx.OutErrFile == nil {
+		return
+	}
+	throttled, requests := handlerCtx.ThrottleStats.Get()
+	if requests > 0 {
+		fmt.Fprintf(handlerCtx.OutErrFile, "rate limits throttled %d requests for %v in total\n", requests, throttled.Round(time.Millisecond))
+	}
+}
+
+// getAuthProfileForQuery returns the credential profile named
+// by an AUTH(profile='name') directive, if the statement has one.
+func getAuthProfileForQuery(query string) string {
+	if !strings.Contains(strings.ToUpper(query), authDirectiveStr) {
+		return ""
+	}
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return ""
+	}
+	directive, ok := parserutil.ExtractFunctionDirective(parserutil.GetStatementComments(stmt), authDirectiveStr)
+	if !ok {
+		return ""
+	}
+	if profile, ok := directive.GetParam("profile"); ok {
+		return profile
+	}
+	profile, _ := directive.GetArg(0)
+	return profile
 }
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"infraql/internal/iql/dto"
+)
+
+// bucket is a token bucket, filling at rate tokens per second up to
+// burst.  Tokens are reserved rather than awaited, so the balance may go
+// negative and callers are admitted in the order that they arrived.
+type bucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	b := float64(burst)
+	if b < 1 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &bucket{
+		rate:   rate,
+		burst:  b,
+		tokens: b,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n tokens, returning how long to wait before they are due.
+func (b *bucket) reserve(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type limiter struct {
+	bucket *bucket
+	slots  chan struct{}
+}
+
+func newLimiter(limit dto.RateLimit) *limiter {
+	l := &limiter{}
+	if limit.Rate > 0 {
+		l.bucket = newBucket(limit.Rate, limit.Burst)
+	}
+	if limit.Concurrency > 0 {
+		l.slots = make(chan struct{}, limit.Concurrency)
+	}
+	return l
+}
+
+var (
+	limitersMutex sync.Mutex
+	limiters      map[string]*limiter = make(map[string]*limiter)
+)
+
+// getLimiter returns the process wide limiter for the scope, so that
+// every statement and, for the server, every connection shares it.
+func getLimiter(limits map[string]dto.RateLimit, scope string) *limiter {
+	limit, ok := limits[scope]
+	if !ok {
+		return nil
+	}
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+	l, ok := limiters[scope]
+	if !ok {
+		l = newLimiter(limit)
+		limiters[scope] = l
+	}
+	return l
+}
+
+// Acquire blocks until a request for the method is permitted by every
+// limit that applies to it.  The returned function must be called once
+// the request completes, to free its concurrency slots; the duration is
+// the time spent waiting.
+func Acquire(limits map[string]dto.RateLimit, hIds dto.HeirarchyIdentifiers) (func(), time.Duration) {
+	return AcquireN(limits, hIds, 1)
+}
+
+// AcquireN is as Acquire, for a batch of n requests for the method sent
+// together; the batch counts n times against rates but once against
+// concurrency.
+func AcquireN(limits map[string]dto.RateLimit, hIds dto.HeirarchyIdentifiers, n int) (func(), time.Duration) {
+	if len(limits) == 0 || n < 1 {
+		return func() {}, 0
+	}
+	var throttled time.Duration
+	var held []*limiter
+	for _, scope := range dto.GetRateLimitScopes(hIds) {
+		l := getLimiter(limits, scope)
+		if l == nil {
+			continue
+		}
+		if l.bucket != nil {
+			if wait := l.bucket.reserve(n); wait > 0 {
+				time.Sleep(wait)
+				throttled += wait
+			}
+		}
+		if l.slots != nil {
+			select {
+			case l.slots <- struct{}{}:
+			default:
+				start := time.Now()
+				l.slots <- struct{}{}
+				throttled += time.Since(start)
+			}
+			held = append(held, l)
+		}
+	}
+	release := func() {
+		for _, l := range held {
+			<-l.slots
+		}
+	}
+	return release, throttled
+}
+
+// Stats accumulates the time a statement's requests spent throttled.
+type Stats struct {
+	mutex     sync.Mutex
+	throttled time.Duration
+	requests  int
+}
+
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+func (s *Stats) Add(throttled time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if throttled > 0 {
+		s.throttled += throttled
+		s.requests++
+	}
+}
+
+// Get returns the total time spent throttled, and by how many requests.
+func (s *Stats) Get() (time.Duration, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.throttled, s.requests
+}
+
+func (s *Stats) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.throttled = 0
+	s.requests = 0
+}
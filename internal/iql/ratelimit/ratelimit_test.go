@@ -0,0 +1,59 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"infraql/internal/iql/dto"
+	. "infraql/internal/iql/ratelimit"
+)
+
+func TestAcquire(t *testing.T) {
+	limits, err := dto.ParseRateLimits(map[string]string{
+		"ratelimit.google.compute.rate":                   "100",
+		"ratelimit.google.compute.burst":                  "2",
+		"ratelimit.google.compute.instances.insert.rate":  "10",
+		"ratelimit.google.compute.instances.insert.burst": "1",
+		"ratelimit.google.storage.concurrency":            "1",
+		"keyfilepath":                                     "/path/to/key.json",
+	})
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if len(limits) != 3 {
+		t.Fatalf("Test failed: %d limits parsed, expected 3", len(limits))
+	}
+	if _, err := dto.ParseRateLimits(map[string]string{"ratelimit.google.compute.instances.rate": "1"}); err == nil {
+		t.Fatalf("Test failed: ambiguous rate limit scope accepted")
+	}
+
+	list := *dto.NewHeirarchyIdentifiers("google", "compute", "instances", "list")
+	var throttled time.Duration
+	for i := 0; i < 4; i++ {
+		release, d := Acquire(limits, list)
+		release()
+		throttled += d
+	}
+	// two requests within the burst, then two at 100 per second
+	if throttled < 15*time.Millisecond {
+		t.Fatalf("Test failed: throttled for %v, expected around 20ms", throttled)
+	}
+
+	insert := *dto.NewHeirarchyIdentifiers("google", "compute", "instances", "insert")
+	Acquire(limits, insert)
+	if _, d := Acquire(limits, insert); d < 50*time.Millisecond {
+		t.Fatalf("Test failed: method limit not applied, throttled for %v", d)
+	}
+
+	objects := *dto.NewHeirarchyIdentifiers("google", "storage", "objects", "list")
+	release, _ := Acquire(limits, objects)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	release, d := Acquire(limits, objects)
+	release()
+	if d < 15*time.Millisecond {
+		t.Fatalf("Test failed: concurrency limit not applied, throttled for %v", d)
+	}
+}
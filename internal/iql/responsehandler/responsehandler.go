@@ -43,7 +43,10 @@ func HandleResponse(handlerCtx *handler.HandlerContext, response dto.ExecutorOut
 			handleEmptyWriter(outputWriter, err)
 			return err
 		}
-		outputWriter.Write(response.Result)
+		if err = outputWriter.Write(response.Result); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return err
+		}
 	} else if response.Err != nil {
 		outputWriter, err = output.GetOutputWriter(
 			handlerCtx.Outfile,
@@ -9,6 +9,8 @@ and
 "name" not like 'sqlite%'
 and
 "name" not like '__iql__%'
+and
+"name" not like 'local.%'
 EXCEPT
 select ss.name 
 from sqlite_schema ss,
@@ -82,6 +82,14 @@ type SQLEngine interface {
 	CacheStoreGet(string) ([]byte, error)
 	CacheStoreGetAll() ([]dto.KeyVal, error)
 	CacheStorePut(string, []byte, string, int) error
+	ViewStoreGet(string) (string, error)
+	ViewStoreGetAll() ([]dto.KeyVal, error)
+	ViewStorePut(string, string) error
+	ViewStoreDelete(string) (bool, error)
+	OperationStoreGet(string) ([]dto.OperationRecord, error)
+	OperationStoreGetAll() ([]dto.OperationRecord, error)
+	OperationStoreGetPending([]string) ([]dto.OperationRecord, error)
+	OperationStorePut(dto.OperationRecord) error
 	// QueryOutput(*SQLEnginePayload, *dto.ExecutorOutput) dto.ExecutorOutput
 }
 
@@ -312,6 +320,108 @@ func (se SQLiteEngine) CacheStorePut(key string, val []byte, tablespace string,
 	return err
 }
 
+func (se SQLiteEngine) ViewStoreGet(viewName string) (string, error) {
+	var retVal string
+	res := se.db.QueryRow(`SELECT view_ddl FROM "__iql__.control.view" WHERE view_name = ?`, viewName)
+	err := res.Scan(&retVal)
+	return retVal, err
+}
+
+func (se SQLiteEngine) ViewStoreGetAll() ([]dto.KeyVal, error) {
+	var retVal []dto.KeyVal
+	res, err := se.db.Query(`SELECT view_name, view_ddl FROM "__iql__.control.view" ORDER BY view_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var kv dto.KeyVal
+		err = res.Scan(&kv.K, &kv.V)
+		if err != nil {
+			return nil, err
+		}
+		retVal = append(retVal, kv)
+	}
+	return retVal, res.Err()
+}
+
+func (se SQLiteEngine) ViewStorePut(viewName string, viewDDL string) error {
+	_, err := se.db.Exec(`INSERT INTO "__iql__.control.view" (view_name, view_ddl) VALUES(?, ?)`, viewName, viewDDL)
+	return err
+}
+
+func (se SQLiteEngine) ViewStoreDelete(viewName string) (bool, error) {
+	res, err := se.db.Exec(`DELETE FROM "__iql__.control.view" WHERE view_name = ?`, viewName)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+const operationColumns string = `operation_id, self_link, provider, resource, target, principal, auth_profile, status, error, created_dttm, updated_dttm`
+
+func (se SQLiteEngine) queryOperations(query string, args ...interface{}) ([]dto.OperationRecord, error) {
+	var retVal []dto.OperationRecord
+	res, err := se.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var op dto.OperationRecord
+		var resource, target, principal, authProfile, opErr sql.NullString
+		err = res.Scan(&op.ID, &op.SelfLink, &op.Provider, &resource, &target, &principal, &authProfile, &op.Status, &opErr, &op.CreatedDttm, &op.UpdatedDttm)
+		if err != nil {
+			return nil, err
+		}
+		op.Resource = resource.String
+		op.Target = target.String
+		op.Principal = principal.String
+		op.AuthProfile = authProfile.String
+		op.Error = opErr.String
+		retVal = append(retVal, op)
+	}
+	return retVal, res.Err()
+}
+
+// OperationStoreGet returns the operations of the given id or selfLink;
+// ids are unique only within a project and location.
+func (se SQLiteEngine) OperationStoreGet(id string) ([]dto.OperationRecord, error) {
+	return se.queryOperations(`SELECT `+operationColumns+` FROM "__iql__.control.operation" WHERE operation_id = ? OR self_link = ? ORDER BY created_dttm DESC`, id, id)
+}
+
+func (se SQLiteEngine) OperationStoreGetAll() ([]dto.OperationRecord, error) {
+	return se.queryOperations(`SELECT ` + operationColumns + ` FROM "__iql__.control.operation" ORDER BY created_dttm, operation_id`)
+}
+
+// OperationStoreGetPending returns the operations not in any of the final statuses.
+func (se SQLiteEngine) OperationStoreGetPending(finalStatuses []string) ([]dto.OperationRecord, error) {
+	var args []interface{}
+	var placeholders []string
+	for _, s := range finalStatuses {
+		args = append(args, s)
+		placeholders = append(placeholders, "?")
+	}
+	query := `SELECT ` + operationColumns + ` FROM "__iql__.control.operation"`
+	if len(placeholders) > 0 {
+		query += ` WHERE status NOT IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	return se.queryOperations(query+` ORDER BY created_dttm, operation_id`, args...)
+}
+
+// OperationStorePut records the operation, or its latest
+// status if already recorded, keyed on selfLink.
+func (se SQLiteEngine) OperationStorePut(op dto.OperationRecord) error {
+	_, err := se.db.Exec(
+		`INSERT INTO "__iql__.control.operation" (operation_id, self_link, provider, resource, target, principal, auth_profile, status, error)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(self_link) DO UPDATE SET status = excluded.status, error = excluded.error, updated_dttm = CURRENT_TIMESTAMP`,
+		op.ID, op.SelfLink, op.Provider, op.Resource, op.Target, op.Principal, op.AuthProfile, op.Status, op.Error,
+	)
+	return err
+}
+
 func (se SQLiteEngine) GCEnactFull() error {
 	err := se.collectObsolete()
 	if err != nil {
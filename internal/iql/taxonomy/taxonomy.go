@@ -164,11 +164,11 @@ func getHids(handlerCtx *handler.HandlerContext, node sqlparser.SQLNode) (*dto.H
 	case *sqlparser.Exec:
 		hIds = dto.ResolveMethodTerminalHeirarchyIdentifiers(n.MethodName)
 	case *sqlparser.Select:
-		currentSvcRsc, err := sqlparser.TableFromStatement(handlerCtx.Query)
+		currentSvcRsc, err := parserutil.ExtractSingleTableFromTableExprs(n.From)
 		if err != nil {
 			return nil, err
 		}
-		hIds = dto.ResolveResourceTerminalHeirarchyIdentifiers(currentSvcRsc)
+		hIds = dto.ResolveResourceTerminalHeirarchyIdentifiers(*currentSvcRsc)
 	case sqlparser.TableName:
 		hIds = dto.ResolveResourceTerminalHeirarchyIdentifiers(n)
 	case *sqlparser.AliasedTableExpr:
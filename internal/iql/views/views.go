@@ -0,0 +1,322 @@
+package views
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"infraql/internal/iql/parse"
+	"infraql/internal/iql/sqlengine"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// Views are saved SELECT statements, persisted in the control schema
+// and expanded in place of the view reference before planning.
+const (
+	maxExpansionDepth int = 16
+)
+
+func GetViewName(tn sqlparser.TableName) string {
+	var parts []string
+	for _, ident := range []sqlparser.TableIdent{tn.QualifierSecond, tn.Qualifier, tn.Name} {
+		if !ident.IsEmpty() {
+			parts = append(parts, strings.ToLower(ident.GetRawVal()))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func Get(eng sqlengine.SQLEngine, viewName string) (*sqlparser.Select, bool, error) {
+	ddl, err := eng.ViewStoreGet(viewName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	sel, err := parseSelect(ddl)
+	if err != nil {
+		return nil, false, fmt.Errorf("stored definition of view '%s' is invalid: %s", viewName, err.Error())
+	}
+	return sel, true, nil
+}
+
+func parseSelect(query string) (*sqlparser.Select, error) {
+	stmt, err := parse.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return nil, fmt.Errorf("view definition must be a SELECT statement, got %T", stmt)
+	}
+	return sel, nil
+}
+
+// cloneExpr guards against sharing a single view expression
+// between multiple sites in the expanded query.
+func cloneExpr(expr sqlparser.Expr) (sqlparser.Expr, error) {
+	sel, err := parseSelect("select " + sqlparser.String(expr))
+	if err != nil {
+		return nil, err
+	}
+	return sel.SelectExprs[0].(*sqlparser.AliasedExpr).Expr, nil
+}
+
+func getViewReference(sel *sqlparser.Select) (*sqlparser.AliasedTableExpr, sqlparser.TableName, bool) {
+	if len(sel.From) != 1 {
+		return nil, sqlparser.TableName{}, false
+	}
+	ate, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, sqlparser.TableName{}, false
+	}
+	tn, ok := ate.Expr.(sqlparser.TableName)
+	return ate, tn, ok
+}
+
+// Expand replaces a SELECT against a saved view with the view body,
+// merging the outer query into it.  Other statements are returned as is.
+func Expand(eng sqlengine.SQLEngine, stmt sqlparser.Statement) (sqlparser.Statement, error) {
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return stmt, nil
+	}
+	return ExpandSelect(eng, sel)
+}
+
+// ExpandSelect expands views to a fixed point, since views
+// may be defined over other views.
+func ExpandSelect(eng sqlengine.SQLEngine, sel *sqlparser.Select) (*sqlparser.Select, error) {
+	for i := 0; i < maxExpansionDepth; i++ {
+		ate, tn, ok := getViewReference(sel)
+		if !ok {
+			return sel, nil
+		}
+		viewSel, found, err := Get(eng, GetViewName(tn))
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return sel, nil
+		}
+		sel, err = Merge(viewSel, sel, ate, tn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("view expansion exceeded maximum depth of %d, views may be circular", maxExpansionDepth)
+}
+
+// Merge folds the outer query into the view body.  Outer predicates that
+// bind provider parameters, being equalities on columns the view does not
+// project, are conjoined with those of the view, so that required
+// parameters for the underlying resource may be supplied by either; this
+// holds for every view.  The rest of the outer query is folded in only
+// where that preserves its meaning over the view's result: a grouped or
+// limited view cannot take outer predicates on its columns, nor outer
+// grouping or aggregates, and a limited view cannot be re-ordered,
+// re-limited or made distinct.  Since the view is expanded in place of
+// the reference, rather than run as a subquery, such queries are
+// rejected.  References to view column aliases are replaced by the
+// aliased expressions throughout.
+func Merge(viewSel *sqlparser.Select, outer *sqlparser.Select, ate *sqlparser.AliasedTableExpr, tn sqlparser.TableName) (*sqlparser.Select, error) {
+	aliases := make(map[string]sqlparser.Expr)
+	projected := make(map[string]bool)
+	for _, se := range viewSel.SelectExprs {
+		ae, ok := se.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		if !ae.As.IsEmpty() {
+			aliases[ae.As.Lowered()] = ae.Expr
+			projected[ae.As.Lowered()] = true
+			continue
+		}
+		if col, ok := ae.Expr.(*sqlparser.ColName); ok {
+			projected[col.Name.Lowered()] = true
+		}
+	}
+	viewName := GetViewName(tn)
+	viewRefs := map[string]bool{
+		strings.ToLower(tn.Name.GetRawVal()): true,
+		viewName:                             true,
+	}
+	if !ate.As.IsEmpty() {
+		viewRefs[strings.ToLower(ate.As.GetRawVal())] = true
+	}
+	substitute := func(node sqlparser.SQLNode) (sqlparser.SQLNode, error) {
+		var rewriteErr error
+		result := sqlparser.Rewrite(node, func(cursor *sqlparser.Cursor) bool {
+			col, ok := cursor.Node().(*sqlparser.ColName)
+			if !ok {
+				return true
+			}
+			if !col.Qualifier.IsEmpty() {
+				if !viewRefs[GetViewName(col.Qualifier)] {
+					return false
+				}
+				col.Qualifier = sqlparser.TableName{}
+			}
+			if expr, ok := aliases[col.Name.Lowered()]; ok {
+				clone, err := cloneExpr(expr)
+				if err != nil {
+					rewriteErr = err
+					return false
+				}
+				cursor.Replace(clone)
+			}
+			return false
+		}, nil)
+		return result, rewriteErr
+	}
+	isLimited := viewSel.Limit != nil
+	isGrouped := len(viewSel.GroupBy) > 0 || viewSel.Having != nil || viewSel.Distinct || containsAggregate(viewSel.SelectExprs)
+	var predicates []sqlparser.Expr
+	if outer.Where != nil {
+		for _, expr := range splitConjuncts(outer.Where.Expr) {
+			if (isLimited || isGrouped) && !isParameterPredicate(expr, projected, viewRefs) {
+				return nil, fmt.Errorf("cannot apply predicate '%s' to grouped or limited view '%s', only equalities on provider parameters may be", sqlparser.String(expr), viewName)
+			}
+			predicates = append(predicates, expr)
+		}
+	}
+	if isLimited || isGrouped {
+		if len(outer.GroupBy) > 0 || outer.Having != nil || containsAggregate(outer.SelectExprs) {
+			return nil, fmt.Errorf("cannot group or aggregate over grouped or limited view '%s'", viewName)
+		}
+	}
+	if isLimited {
+		if len(outer.OrderBy) > 0 || outer.Limit != nil || outer.Distinct {
+			return nil, fmt.Errorf("cannot order, limit or apply distinct to limited view '%s'", viewName)
+		}
+	}
+	retVal := viewSel
+	if !isStarOnly(outer.SelectExprs) {
+		var selectExprs sqlparser.SelectExprs
+		for _, se := range outer.SelectExprs {
+			ae, ok := se.(*sqlparser.AliasedExpr)
+			if !ok {
+				return nil, fmt.Errorf("cannot combine '%s' with other select expressions on a view", sqlparser.String(se))
+			}
+			col, isCol := ae.Expr.(*sqlparser.ColName)
+			if isCol {
+				if expr, ok := aliases[col.Name.Lowered()]; ok {
+					clone, err := cloneExpr(expr)
+					if err != nil {
+						return nil, err
+					}
+					as := ae.As
+					if as.IsEmpty() {
+						as = col.Name
+					}
+					selectExprs = append(selectExprs, &sqlparser.AliasedExpr{Expr: clone, As: as})
+					continue
+				}
+			}
+			expr, err := substitute(ae.Expr)
+			if err != nil {
+				return nil, err
+			}
+			selectExprs = append(selectExprs, &sqlparser.AliasedExpr{Expr: expr.(sqlparser.Expr), As: ae.As})
+		}
+		retVal.SelectExprs = selectExprs
+	}
+	for _, predicate := range predicates {
+		expr, err := substitute(predicate)
+		if err != nil {
+			return nil, err
+		}
+		if retVal.Where == nil {
+			retVal.Where = sqlparser.NewWhere(sqlparser.WhereStr, expr.(sqlparser.Expr))
+			continue
+		}
+		retVal.Where = sqlparser.NewWhere(sqlparser.WhereStr, &sqlparser.AndExpr{Left: retVal.Where.Expr, Right: expr.(sqlparser.Expr)})
+	}
+	if len(outer.GroupBy) > 0 {
+		groupBy, err := substitute(outer.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		retVal.GroupBy = groupBy.(sqlparser.GroupBy)
+	}
+	if outer.Having != nil {
+		having, err := substitute(outer.Having.Expr)
+		if err != nil {
+			return nil, err
+		}
+		retVal.Having = sqlparser.NewWhere(sqlparser.HavingStr, having.(sqlparser.Expr))
+	}
+	if len(outer.OrderBy) > 0 {
+		orderBy, err := substitute(outer.OrderBy)
+		if err != nil {
+			return nil, err
+		}
+		retVal.OrderBy = orderBy.(sqlparser.OrderBy)
+	}
+	if outer.Limit != nil {
+		retVal.Limit = outer.Limit
+	}
+	retVal.Distinct = retVal.Distinct || outer.Distinct
+	retVal.Comments = append(retVal.Comments, outer.Comments...)
+	return retVal, nil
+}
+
+func splitConjuncts(expr sqlparser.Expr) []sqlparser.Expr {
+	if and, ok := expr.(*sqlparser.AndExpr); ok {
+		return append(splitConjuncts(and.Left), splitConjuncts(and.Right)...)
+	}
+	return []sqlparser.Expr{expr}
+}
+
+// isParameterPredicate reports whether a predicate binds a provider
+// parameter, that is an equality between a literal and a column the
+// view does not project.  Every column of a view over '*' is taken
+// as a possible parameter.
+func isParameterPredicate(expr sqlparser.Expr, projected map[string]bool, viewRefs map[string]bool) bool {
+	cmp, ok := expr.(*sqlparser.ComparisonExpr)
+	if !ok || cmp.Operator != sqlparser.EqualStr {
+		return false
+	}
+	col, ok := cmp.Left.(*sqlparser.ColName)
+	if !ok {
+		return false
+	}
+	if _, ok := cmp.Right.(*sqlparser.SQLVal); !ok {
+		return false
+	}
+	if !col.Qualifier.IsEmpty() && !viewRefs[GetViewName(col.Qualifier)] {
+		return false
+	}
+	return !projected[col.Name.Lowered()]
+}
+
+func containsAggregate(node sqlparser.SQLNode) bool {
+	retVal := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.FuncExpr:
+			if node.IsAggregate() {
+				retVal = true
+				return false, nil
+			}
+		case *sqlparser.GroupConcatExpr:
+			retVal = true
+			return false, nil
+		case *sqlparser.Subquery:
+			return false, nil
+		}
+		return true, nil
+	}, node)
+	return retVal
+}
+
+func isStarOnly(exprs sqlparser.SelectExprs) bool {
+	if len(exprs) != 1 {
+		return false
+	}
+	_, ok := exprs[0].(*sqlparser.StarExpr)
+	return ok
+}
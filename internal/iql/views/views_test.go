@@ -0,0 +1,106 @@
+package views_test
+
+import (
+	"testing"
+
+	. "infraql/internal/iql/views"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func parseSelect(t *testing.T, query string) *sqlparser.Select {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return stmt.(*sqlparser.Select)
+}
+
+func TestMerge(t *testing.T) {
+	viewSel := parseSelect(t, "select name as vm_name, status from google.compute.instances where zone = 'z' and status = 'RUNNING'")
+	outer := parseSelect(t, "select v.vm_name from fleet.running_vms as v where project = 'p' and vm_name like 'web%' order by vm_name")
+	ate := outer.From[0].(*sqlparser.AliasedTableExpr)
+	merged, err := Merge(viewSel, outer, ate, ate.Expr.(sqlparser.TableName))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	expected := `select name as vm_name from "google.compute.instances" where zone = 'z' and ` + "`status`" + ` = 'RUNNING' and project = 'p' and name like 'web%' order by name asc`
+	if actual := sqlparser.String(merged); actual != expected {
+		t.Fatalf("Test failed: merged query = '%s', expected '%s'", actual, expected)
+	}
+	if GetViewName(ate.Expr.(sqlparser.TableName)) != "fleet.running_vms" {
+		t.Fatalf("Test failed: unexpected view name '%s'", GetViewName(ate.Expr.(sqlparser.TableName)))
+	}
+}
+
+func TestMergeGroupedView(t *testing.T) {
+	viewSel := parseSelect(t, "select status, count(*) as vm_count from google.compute.instances where zone = 'z' group by status")
+	outer := parseSelect(t, "select status, vm_count from fleet.status_counts where project = 'p' order by vm_count desc")
+	ate := outer.From[0].(*sqlparser.AliasedTableExpr)
+	merged, err := Merge(viewSel, outer, ate, ate.Expr.(sqlparser.TableName))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	expected := `select ` + "`status`" + `, count(*) as vm_count from "google.compute.instances" where zone = 'z' and project = 'p' group by ` + "`status`" + ` order by count(*) desc`
+	if actual := sqlparser.String(merged); actual != expected {
+		t.Fatalf("Test failed: merged query = '%s', expected '%s'", actual, expected)
+	}
+
+	for _, query := range []string{
+		"select status from fleet.status_counts where project = 'p' and vm_count > 1",
+		"select status from fleet.status_counts where project = 'p' and status = 'RUNNING'",
+		"select count(*) from fleet.status_counts where project = 'p'",
+		"select status from fleet.status_counts where project = 'p' group by status",
+		"select status from fleet.status_counts where project = 'p' having count(*) > 1",
+	} {
+		viewSel := parseSelect(t, "select status, count(*) as vm_count from google.compute.instances where zone = 'z' group by status")
+		outer := parseSelect(t, query)
+		ate := outer.From[0].(*sqlparser.AliasedTableExpr)
+		if _, err := Merge(viewSel, outer, ate, ate.Expr.(sqlparser.TableName)); err == nil {
+			t.Fatalf("Test failed: expected error merging '%s' into grouped view", query)
+		}
+	}
+}
+
+func TestMergeLimitedView(t *testing.T) {
+	viewSel := parseSelect(t, "select name, status from google.compute.instances where zone = 'z' order by name limit 5")
+	outer := parseSelect(t, "select name from fleet.first_vms where project = 'p'")
+	ate := outer.From[0].(*sqlparser.AliasedTableExpr)
+	merged, err := Merge(viewSel, outer, ate, ate.Expr.(sqlparser.TableName))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	expected := `select name from "google.compute.instances" where zone = 'z' and project = 'p' order by name asc limit 5`
+	if actual := sqlparser.String(merged); actual != expected {
+		t.Fatalf("Test failed: merged query = '%s', expected '%s'", actual, expected)
+	}
+
+	for _, query := range []string{
+		"select name from fleet.first_vms where project = 'p' and status = 'RUNNING'",
+		"select name from fleet.first_vms where project = 'p' order by status",
+		"select name from fleet.first_vms where project = 'p' limit 2",
+		"select distinct status from fleet.first_vms where project = 'p'",
+		"select count(*) from fleet.first_vms where project = 'p'",
+	} {
+		viewSel := parseSelect(t, "select name, status from google.compute.instances where zone = 'z' order by name limit 5")
+		outer := parseSelect(t, query)
+		ate := outer.From[0].(*sqlparser.AliasedTableExpr)
+		if _, err := Merge(viewSel, outer, ate, ate.Expr.(sqlparser.TableName)); err == nil {
+			t.Fatalf("Test failed: expected error merging '%s' into limited view", query)
+		}
+	}
+}
+
+func TestMergeHavingSubstitution(t *testing.T) {
+	viewSel := parseSelect(t, "select status as vm_status, zone from google.compute.instances where project = 'p'")
+	outer := parseSelect(t, "select vm_status, count(*) as vm_count from fleet.vms group by vm_status having vm_status != 'TERMINATED'")
+	ate := outer.From[0].(*sqlparser.AliasedTableExpr)
+	merged, err := Merge(viewSel, outer, ate, ate.Expr.(sqlparser.TableName))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	expected := `select ` + "`status`" + ` as vm_status, count(*) as vm_count from "google.compute.instances" where project = 'p' group by ` + "`status`" + ` having ` + "`status`" + ` != 'TERMINATED'`
+	if actual := sqlparser.String(merged); actual != expected {
+		t.Fatalf("Test failed: merged query = '%s', expected '%s'", actual, expected)
+	}
+}
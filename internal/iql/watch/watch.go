@@ -0,0 +1,377 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"infraql/internal/iql/constants"
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/handler"
+	"infraql/internal/iql/operations"
+	"infraql/internal/iql/output"
+	"infraql/internal/iql/querysubmit"
+	"infraql/internal/iql/responsehandler"
+	"infraql/internal/iql/sqlengine"
+
+	log "github.com/sirupsen/logrus"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+const (
+	DeltaColumnName  string = "iql_delta"
+	DeltaInsertedStr string = "inserted"
+	DeltaUpdatedStr  string = "updated"
+	DeltaDeletedStr  string = "deleted"
+	DirectiveName    string = "WATCH"
+	DefaultInterval         = 60 * time.Second
+)
+
+// Row identity is inferred from the first of these columns
+// present in the result, unless key columns are supplied.
+var defaultKeyColumns []string = []string{"id", "selfLink", "name"}
+
+// ParseInterval accepts either a bare number of seconds or a go duration string.
+func ParseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DefaultInterval, nil
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		if secs <= 0 {
+			return 0, fmt.Errorf("watch interval must be positive, got '%s'", s)
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse watch interval '%s'", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("watch interval must be positive, got '%s'", s)
+	}
+	return d, nil
+}
+
+type snapshot struct {
+	fields []*querypb.Field
+	rows   map[string][]sqltypes.Value
+	order  []string
+}
+
+func rowFingerprint(row []sqltypes.Value) string {
+	vals := make([]string, len(row))
+	for i, v := range row {
+		vals[i] = strconv.Quote(v.ToString())
+	}
+	return strings.Join(vals, ",")
+}
+
+func resolveKeyIndices(fields []*querypb.Field, keyColumns []string) []int {
+	fieldIdx := make(map[string]int)
+	for i, f := range fields {
+		fieldIdx[f.Name] = i
+	}
+	if len(keyColumns) > 0 {
+		var retVal []int
+		for _, k := range keyColumns {
+			if i, ok := fieldIdx[k]; ok {
+				retVal = append(retVal, i)
+			}
+		}
+		return retVal
+	}
+	for _, k := range defaultKeyColumns {
+		if i, ok := fieldIdx[k]; ok {
+			return []int{i}
+		}
+	}
+	return nil
+}
+
+func newSnapshot(result *sqltypes.Result, keyColumns []string) *snapshot {
+	retVal := &snapshot{
+		rows: make(map[string][]sqltypes.Value),
+	}
+	if result == nil {
+		return retVal
+	}
+	retVal.fields = result.Fields
+	keyIdx := resolveKeyIndices(result.Fields, keyColumns)
+	for _, row := range result.Rows {
+		var key string
+		if len(keyIdx) == 0 {
+			key = rowFingerprint(row)
+		} else {
+			keyVals := make([]sqltypes.Value, len(keyIdx))
+			for i, idx := range keyIdx {
+				keyVals[i] = row[idx]
+			}
+			key = rowFingerprint(keyVals)
+		}
+		if _, ok := retVal.rows[key]; !ok {
+			retVal.order = append(retVal.order, key)
+		}
+		retVal.rows[key] = row
+	}
+	return retVal
+}
+
+func annotateRow(delta string, row []sqltypes.Value) []sqltypes.Value {
+	retVal := make([]sqltypes.Value, 0, len(row)+1)
+	retVal = append(retVal, sqltypes.NewVarChar(delta))
+	return append(retVal, row...)
+}
+
+// Each poll's rows are kept in the DRM, in the watch snapshot table, under
+// the generation and session of the handler and a transaction of the watch's
+// own, as are the rows of provider tables.  The delta of a poll is computed
+// there against the rows of the poll before, which are then collected.
+const (
+	snapshotTableName string = `"__iql__.watch.snapshot"`
+	snapshotPredicate string = `iql_generation_id = ? AND iql_session_id = ? AND iql_transaction_id = ?`
+)
+
+var deltaQuery string = fmt.Sprintf(`
+WITH cur AS (
+  SELECT rowid AS ord, row_key, row_fingerprint, row_values FROM %[1]s WHERE %[2]s AND iql_poll_id = ?
+), prev AS (
+  SELECT rowid AS ord, row_key, row_fingerprint, row_values FROM %[1]s WHERE %[2]s AND iql_poll_id = ?
+)
+SELECT CASE WHEN prev.row_key IS NULL THEN '%[3]s' ELSE '%[4]s' END, cur.row_values, 0, cur.ord
+FROM cur LEFT OUTER JOIN prev ON prev.row_key = cur.row_key
+WHERE prev.row_key IS NULL OR prev.row_fingerprint <> cur.row_fingerprint
+UNION ALL
+SELECT '%[5]s', prev.row_values, 1, prev.ord
+FROM prev LEFT OUTER JOIN cur ON cur.row_key = prev.row_key
+WHERE cur.row_key IS NULL
+ORDER BY 3, 4`, snapshotTableName, snapshotPredicate, DeltaInsertedStr, DeltaUpdatedStr, DeltaDeletedStr)
+
+func encodeRow(row []sqltypes.Value) (string, error) {
+	vals := make([]*string, len(row))
+	for i, v := range row {
+		if v.IsNull() {
+			continue
+		}
+		s := v.ToString()
+		vals[i] = &s
+	}
+	b, err := json.Marshal(vals)
+	return string(b), err
+}
+
+func decodeRow(fields []*querypb.Field, encoded string) ([]sqltypes.Value, error) {
+	var vals []*string
+	if err := json.Unmarshal([]byte(encoded), &vals); err != nil {
+		return nil, err
+	}
+	retVal := make([]sqltypes.Value, len(vals))
+	for i, v := range vals {
+		switch {
+		case v == nil:
+			retVal[i] = sqltypes.NULL
+		case i < len(fields):
+			retVal[i] = sqltypes.MakeTrusted(fields[i].Type, []byte(*v))
+		default:
+			retVal[i] = sqltypes.NewVarChar(*v)
+		}
+	}
+	return retVal, nil
+}
+
+// DeltaStore computes the rows inserted, updated or deleted between
+// successive polls, with a leading column naming the kind of change.
+// Rows are identified by the key columns, if any are supplied, otherwise
+// by the first present of id, selfLink and name, otherwise by their
+// entire content.
+type DeltaStore struct {
+	sqlEngine  sqlengine.SQLEngine
+	txnCtrlCtr dto.TxnControlCounters
+	keyColumns []string
+	fields     []*querypb.Field
+	pollId     int
+}
+
+func NewDeltaStore(sqlEngine sqlengine.SQLEngine, txnCtrlCtr dto.TxnControlCounters, keyColumns []string) *DeltaStore {
+	return &DeltaStore{
+		sqlEngine:  sqlEngine,
+		txnCtrlCtr: txnCtrlCtr,
+		keyColumns: keyColumns,
+	}
+}
+
+func (ds *DeltaStore) getSnapshotArgs(args ...interface{}) []interface{} {
+	return append([]interface{}{ds.txnCtrlCtr.GenId, ds.txnCtrlCtr.SessionId, ds.txnCtrlCtr.TxnId}, args...)
+}
+
+func (ds *DeltaStore) putSnapshot(pollId int, cur *snapshot) error {
+	db, err := ds.sqlEngine.GetDB()
+	if err != nil {
+		return err
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (iql_generation_id, iql_session_id, iql_transaction_id, iql_poll_id, row_key, row_fingerprint, row_values) VALUES (?, ?, ?, ?, ?, ?, ?)`, snapshotTableName)
+	for _, k := range cur.order {
+		row := cur.rows[k]
+		encoded, err := encodeRow(row)
+		if err != nil {
+			txn.Rollback()
+			return err
+		}
+		if _, err := txn.Exec(query, ds.getSnapshotArgs(pollId, k, rowFingerprint(row), encoded)...); err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+// Next stores the rows of a poll, returning the delta from the poll
+// before, if any; the first poll reports every row as inserted.
+func (ds *DeltaStore) Next(result *sqltypes.Result) (*sqltypes.Result, error) {
+	cur := newSnapshot(result, ds.keyColumns)
+	prevFields := ds.fields
+	fields := cur.fields
+	if fields == nil {
+		fields = prevFields
+	}
+	pollId := ds.pollId + 1
+	if err := ds.putSnapshot(pollId, cur); err != nil {
+		return nil, err
+	}
+	rows, err := ds.sqlEngine.Query(deltaQuery, ds.getSnapshotArgs(pollId, ds.txnCtrlCtr.GenId, ds.txnCtrlCtr.SessionId, ds.txnCtrlCtr.TxnId, ds.pollId)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	retVal := &sqltypes.Result{
+		Fields: append([]*querypb.Field{{Name: DeltaColumnName, Type: querypb.Type_VARCHAR}}, fields...),
+	}
+	for rows.Next() {
+		var delta, encoded string
+		var part, ord int
+		if err := rows.Scan(&delta, &encoded, &part, &ord); err != nil {
+			return nil, err
+		}
+		rowFields := fields
+		if delta == DeltaDeletedStr {
+			rowFields = prevFields
+		}
+		row, err := decodeRow(rowFields, encoded)
+		if err != nil {
+			return nil, err
+		}
+		retVal.Rows = append(retVal.Rows, annotateRow(delta, row))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	retVal.RowsAffected = uint64(len(retVal.Rows))
+	if _, err := ds.sqlEngine.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s AND iql_poll_id < ?`, snapshotTableName, snapshotPredicate), ds.getSnapshotArgs(pollId)...); err != nil {
+		return nil, err
+	}
+	ds.pollId = pollId
+	ds.fields = fields
+	return retVal, nil
+}
+
+// Close collects the rows of the last poll.
+func (ds *DeltaStore) Close() error {
+	_, err := ds.sqlEngine.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s`, snapshotTableName, snapshotPredicate), ds.getSnapshotArgs()...)
+	return err
+}
+
+type Watcher struct {
+	handlerCtx *handler.HandlerContext
+	query      string
+	interval   time.Duration
+	keyColumns []string
+	MaxPolls   int
+}
+
+func NewWatcher(handlerCtx *handler.HandlerContext, query string, interval time.Duration, keyColumns []string) *Watcher {
+	return &Watcher{
+		handlerCtx: handlerCtx,
+		query:      query,
+		interval:   interval,
+		keyColumns: keyColumns,
+	}
+}
+
+// Run re-executes the query every interval until the context is cancelled
+// or MaxPolls (if positive) is reached, writing only the changed rows.
+// The first poll reports every row as inserted.  Background refreshes of
+// operations are held off during each poll, though not between them.
+func (w *Watcher) Run(ctx context.Context) error {
+	txnCtrlCtr := dto.TxnControlCounters{
+		GenId:     w.handlerCtx.TxnCounterMgr.GetCurrentGenerationId(),
+		SessionId: w.handlerCtx.TxnCounterMgr.GetCurrentSessionId(),
+		TxnId:     w.handlerCtx.TxnCounterMgr.GetNextTxnId(),
+	}
+	store := NewDeltaStore(w.handlerCtx.SQLEngine, txnCtrlCtr, w.keyColumns)
+	defer store.Close()
+	for i := 0; w.MaxPolls <= 0 || i < w.MaxPolls; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(w.interval):
+			}
+		}
+		if err := w.poll(store); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poll runs the query once, writing the rows changed since the previous
+// successful poll.
+func (w *Watcher) poll(store *DeltaStore) error {
+	defer operations.Exclusive()()
+	w.handlerCtx.Query = w.query
+	response := querysubmit.SubmitQuery(w.handlerCtx)
+	if response.Err != nil {
+		log.Infoln(fmt.Sprintf("watch poll failed: %s", response.Err.Error()))
+		responsehandler.HandleResponse(w.handlerCtx, response)
+		return nil
+	}
+	delta, err := store.Next(response.Result)
+	if err != nil {
+		return err
+	}
+	if len(delta.Rows) == 0 {
+		return nil
+	}
+	return w.writeDelta(delta)
+}
+
+func (w *Watcher) writeDelta(delta *sqltypes.Result) error {
+	if w.handlerCtx.RuntimeContext.OutputFormat == constants.JsonStr {
+		return writeNDJSON(w.handlerCtx, delta)
+	}
+	return responsehandler.HandleResponse(w.handlerCtx, dto.NewExecutorOutput(delta, nil, nil, nil))
+}
+
+// json output is streamed as one object per changed row, so that watch
+// output can be tailed and piped.
+func writeNDJSON(handlerCtx *handler.HandlerContext, delta *sqltypes.Result) error {
+	for _, row := range delta.Rows {
+		b, err := json.Marshal(output.GetTypedRow(delta.Fields, row))
+		if err != nil {
+			return err
+		}
+		if _, err := handlerCtx.Outfile.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
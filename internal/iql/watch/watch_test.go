@@ -0,0 +1,228 @@
+package watch_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"infraql/internal/iql/dto"
+	"infraql/internal/iql/localtable"
+	"infraql/internal/iql/operations"
+	"infraql/internal/iql/sqlengine"
+	. "infraql/internal/iql/watch"
+	"infraql/internal/test/infraqltestutil"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func getWatchTestResult(rows ...[]string) *sqltypes.Result {
+	retVal := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "id", Type: querypb.Type_VARCHAR},
+			{Name: "status", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, row := range rows {
+		var r []sqltypes.Value
+		for _, v := range row {
+			r = append(r, sqltypes.NewVarChar(v))
+		}
+		retVal.Rows = append(retVal.Rows, r)
+	}
+	return retVal
+}
+
+func getDeltaRows(delta *sqltypes.Result) [][]string {
+	var retVal [][]string
+	for _, row := range delta.Rows {
+		var r []string
+		for _, v := range row {
+			r = append(r, v.ToString())
+		}
+		retVal = append(retVal, r)
+	}
+	return retVal
+}
+
+func getTestSQLEngine(t *testing.T) sqlengine.SQLEngine {
+	se, err := sqlengine.NewSQLEngine(sqlengine.NewSQLEngineConfig(dto.RuntimeCtx{}))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return se
+}
+
+var testTxnId int
+
+// getDeltaStore returns a store of a watch of its own, the
+// in memory DRM being shared by every engine of the process.
+func getDeltaStore(t *testing.T, keyColumns []string) *DeltaStore {
+	testTxnId++
+	store := NewDeltaStore(getTestSQLEngine(t), dto.TxnControlCounters{GenId: 1, SessionId: 1, TxnId: testTxnId}, keyColumns)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func nextDelta(t *testing.T, store *DeltaStore, result *sqltypes.Result) *sqltypes.Result {
+	delta, err := store.Next(result)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	return delta
+}
+
+func checkDelta(t *testing.T, delta *sqltypes.Result, expected [][]string) {
+	if len(delta.Fields) != 4 || delta.Fields[0].Name != DeltaColumnName {
+		t.Fatalf("Test failed: unexpected delta fields %v", delta.Fields)
+	}
+	actual := getDeltaRows(delta)
+	if len(actual) != len(expected) {
+		t.Fatalf("Test failed: expected delta %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if actual[i][j] != expected[i][j] {
+				t.Fatalf("Test failed: expected delta %v, got %v", expected, actual)
+			}
+		}
+	}
+	if delta.RowsAffected != uint64(len(expected)) {
+		t.Fatalf("Test failed: rows affected %d, expected %d", delta.RowsAffected, len(expected))
+	}
+}
+
+func TestComputeDelta(t *testing.T) {
+	store := getDeltaStore(t, nil)
+	first := getWatchTestResult(
+		[]string{"vm-1", "1", "RUNNING"},
+		[]string{"vm-2", "2", "RUNNING"},
+		[]string{"vm-3", "3", "RUNNING"},
+	)
+	checkDelta(t, nextDelta(t, store, first), [][]string{
+		{DeltaInsertedStr, "vm-1", "1", "RUNNING"},
+		{DeltaInsertedStr, "vm-2", "2", "RUNNING"},
+		{DeltaInsertedStr, "vm-3", "3", "RUNNING"},
+	})
+	// vm-2 is renamed, but keeps its id, so is updated rather than replaced
+	second := getWatchTestResult(
+		[]string{"vm-1", "1", "RUNNING"},
+		[]string{"vm-2-renamed", "2", "STOPPED"},
+		[]string{"vm-4", "4", "PROVISIONING"},
+	)
+	checkDelta(t, nextDelta(t, store, second), [][]string{
+		{DeltaUpdatedStr, "vm-2-renamed", "2", "STOPPED"},
+		{DeltaInsertedStr, "vm-4", "4", "PROVISIONING"},
+		{DeltaDeletedStr, "vm-3", "3", "RUNNING"},
+	})
+	checkDelta(t, nextDelta(t, store, second), nil)
+	// fields are kept when every row is deleted
+	checkDelta(t, nextDelta(t, store, &sqltypes.Result{}), [][]string{
+		{DeltaDeletedStr, "vm-1", "1", "RUNNING"},
+		{DeltaDeletedStr, "vm-2-renamed", "2", "STOPPED"},
+		{DeltaDeletedStr, "vm-4", "4", "PROVISIONING"},
+	})
+}
+
+func TestComputeDeltaKeyColumns(t *testing.T) {
+	prev := getWatchTestResult([]string{"vm-1", "1", "RUNNING"})
+	cur := getWatchTestResult([]string{"vm-1", "9", "RUNNING"})
+	// keyed by name, a changed id is an update
+	store := getDeltaStore(t, []string{"name"})
+	nextDelta(t, store, prev)
+	checkDelta(t, nextDelta(t, store, cur), [][]string{
+		{DeltaUpdatedStr, "vm-1", "9", "RUNNING"},
+	})
+	// keyed by id, by default, the same change replaces the row
+	store = getDeltaStore(t, nil)
+	nextDelta(t, store, prev)
+	checkDelta(t, nextDelta(t, store, cur), [][]string{
+		{DeltaInsertedStr, "vm-1", "9", "RUNNING"},
+		{DeltaDeletedStr, "vm-1", "1", "RUNNING"},
+	})
+	// without any key column rows are identified by their content
+	noKeyPrev := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "status", Type: querypb.Type_VARCHAR}, {Name: "count", Type: querypb.Type_VARCHAR}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar("RUNNING"), sqltypes.NewVarChar("2")}},
+	}
+	noKeyCur := &sqltypes.Result{
+		Fields: noKeyPrev.Fields,
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar("RUNNING"), sqltypes.NewVarChar("3")}},
+	}
+	store = getDeltaStore(t, []string{"nonesuch"})
+	nextDelta(t, store, noKeyPrev)
+	delta := nextDelta(t, store, noKeyCur)
+	actual := getDeltaRows(delta)
+	if len(actual) != 2 || actual[0][0] != DeltaInsertedStr || actual[0][2] != "3" || actual[1][0] != DeltaDeletedStr || actual[1][2] != "2" {
+		t.Fatalf("Test failed: unexpected delta %v", actual)
+	}
+}
+
+func TestDeltaStoreDRM(t *testing.T) {
+	se := getTestSQLEngine(t)
+	countRows := func() int {
+		rows, err := se.Query(`SELECT count(*) FROM "__iql__.watch.snapshot" WHERE iql_transaction_id IN (1001, 1002)`)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		defer rows.Close()
+		var count int
+		for rows.Next() {
+			if err := rows.Scan(&count); err != nil {
+				t.Fatalf("Test failed: %v", err)
+			}
+		}
+		return count
+	}
+	// watches of one session are told apart by their transaction
+	one := NewDeltaStore(se, dto.TxnControlCounters{GenId: 1, SessionId: 1, TxnId: 1001}, nil)
+	other := NewDeltaStore(se, dto.TxnControlCounters{GenId: 1, SessionId: 1, TxnId: 1002}, nil)
+	first := getWatchTestResult([]string{"vm-1", "1", "RUNNING"}, []string{"vm-2", "2", "RUNNING"})
+	nextDelta(t, one, first)
+	checkDelta(t, nextDelta(t, other, getWatchTestResult([]string{"vm-1", "1", "RUNNING"})), [][]string{
+		{DeltaInsertedStr, "vm-1", "1", "RUNNING"},
+	})
+	checkDelta(t, nextDelta(t, one, first), nil)
+	// only the latest poll of each is kept
+	if countRows() != 3 {
+		t.Fatalf("Test failed: %d snapshot rows, expected 3", countRows())
+	}
+	if err := one.Close(); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if countRows() != 0 {
+		t.Fatalf("Test failed: %d snapshot rows left after close", countRows())
+	}
+}
+
+func TestWatchReleasesRefresherBetweenPolls(t *testing.T) {
+	handlerCtx := infraqltestutil.GetOfflineHandlerContext(t, "", "text")
+	if err := localtable.CreateTable(handlerCtx.SQLEngine, "vms", []localtable.Column{localtable.NewColumn("name", "text")}, false); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	watcher := NewWatcher(handlerCtx, "SELECT name FROM local.vms", time.Second, nil)
+	watcher.MaxPolls = 2
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(context.Background())
+	}()
+	// between polls, background refreshes may proceed
+	time.Sleep(300 * time.Millisecond)
+	acquired := make(chan struct{})
+	go func() {
+		operations.Exclusive()()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("Test failed: watch holds off refreshes between polls")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package infraqltestutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"infraql/internal/iql/config"
+	"infraql/internal/iql/entryutil"
+	"infraql/internal/iql/handler"
+
+	lrucache "vitess.io/vitess/go/cache"
+)
+
+// GetOfflineHandlerContext returns a handler context for the google provider
+// that reads the compute discovery document from the test assets, as is done
+// for documents downloaded earlier when working offline, rather than from
+// the DRM cache or the network.
+func GetOfflineHandlerContext(t *testing.T, query string, outputFmtStr string) *handler.HandlerContext {
+	runtimeCtx, err := GetRuntimeCtx(config.GetGoogleProviderString(), outputFmtStr)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	computeDiscoveryBytes, err := getBytesFromLocalPath("test/assets/discovery-docs/google/compute-v1.json")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	// offline, discovery documents are read from the provider
+	// directory by the last element of their url
+	runtimeCtx.ProviderRootPath = t.TempDir()
+	runtimeCtx.WorkOffline = true
+	providerDir := filepath.Join(runtimeCtx.ProviderRootPath, config.GetGoogleProviderString())
+	if err := os.MkdirAll(providerDir, 0755); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(providerDir, "rest"), computeDiscoveryBytes, 0644); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	sqlEngine, err := entryutil.BuildSQLEngine(*runtimeCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	googleRootDiscoveryBytes, err := getBytesFromLocalPath("test/db/google._root_.json")
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if _, err := sqlEngine.Exec(`INSERT INTO "__iql__.cache.key_val"(k, v) VALUES(?, ?)`, "https://www.googleapis.com/discovery/v1/apis", googleRootDiscoveryBytes); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	handlerCtx, err := entryutil.BuildHandlerContext(*runtimeCtx, strings.NewReader(query), lrucache.NewLRUCache(int64(runtimeCtx.QueryCacheSize)), sqlEngine)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	handlerCtx.TxnCounterMgr, err = entryutil.GetTxnCounterManager(handlerCtx)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	handlerCtx.Outfile = ioutil.Discard
+	handlerCtx.OutErrFile = ioutil.Discard
+	return &handlerCtx
+}
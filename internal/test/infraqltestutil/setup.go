@@ -119,6 +119,10 @@ func SetupSimpleSelectGoogleContainerAggAllowedSubnetworks(t *testing.T) {
 }
 
 func getNetworkInsertSuccessExpectations() map[string]testhttpapi.HTTPRequestExpectations {
+	return getNetworkInsertExpectations(testobjects.GetSimplePollOperationGoogleNetworkInsertResponse())
+}
+
+func getNetworkInsertExpectations(pollResponse string) map[string]testhttpapi.HTTPRequestExpectations {
 	networkInsertURL := &url.URL{
 		Path: testobjects.NetworkInsertPath,
 	}
@@ -141,7 +145,7 @@ func getNetworkInsertSuccessExpectations() map[string]testhttpapi.HTTPRequestExp
 		"GET",
 		networkInsertOpPollURL,
 		testobjects.GoogleApisHost,
-		testobjects.GetSimplePollOperationGoogleNetworkInsertResponse(),
+		pollResponse,
 		nil,
 	)
 
@@ -361,6 +365,28 @@ func SetupSimpleInsertGoogleComputeNetworks(t *testing.T) {
 	asyncmonitor.MonitorPollIntervalSeconds = 2
 }
 
+func SetupFailedInsertGoogleComputeNetworks(t *testing.T) {
+
+	expectations := testhttpapi.NewExpectationStore(3)
+	for k, v := range getNetworkInsertExpectations(testobjects.GetFailedPollOperationGoogleNetworkInsertResponse()) {
+		expectations.Put(k, v)
+	}
+	testhttpapi.StartServer(t, expectations)
+	provider.DummyAuth = true
+	asyncmonitor.MonitorPollIntervalSeconds = 2
+}
+
+func SetupWarningsInsertGoogleComputeNetworks(t *testing.T) {
+
+	expectations := testhttpapi.NewExpectationStore(3)
+	for k, v := range getNetworkInsertExpectations(testobjects.GetWarningsPollOperationGoogleNetworkInsertResponse()) {
+		expectations.Put(k, v)
+	}
+	testhttpapi.StartServer(t, expectations)
+	provider.DummyAuth = true
+	asyncmonitor.MonitorPollIntervalSeconds = 2
+}
+
 func SetupSimpleDeleteGoogleComputeNetworks(t *testing.T) {
 
 	expectations := testhttpapi.NewExpectationStore(3)
@@ -12,6 +12,8 @@ const (
 	ExpectedK8STheHardWayRenderedFile                                  string = "test/assets/expected/k8s-the-hard-way/k8s-the-hard-way.iql"
 	ExpectedShowInsertAddressesRequiredFile                            string = "test/assets/expected/simple-templating/insert-compute-addresses-required.iql"
 	ExpectedComputeNetworkInsertAsyncFile                              string = "test/assets/expected/simple-insert/compute-network/insert-compute-network.txt"
+	ExpectedComputeNetworkInsertAsyncFailedFile                        string = "test/assets/expected/simple-insert/compute-network/insert-compute-network-failed.txt"
+	ExpectedComputeNetworkInsertAsyncWarningsFile                      string = "test/assets/expected/simple-insert/compute-network/insert-compute-network-warnings.txt"
 	ExpectedComputeNetworkDeleteAsyncFile                              string = "test/assets/expected/simple-delete/compute-network/delete-compute-network.txt"
 	ExpectedK8STheHardWayAsyncFile                                     string = "test/assets/expected/k8s-the-hard-way/k8s-the-hard-way-e2e/success.txt"
 	ExpectedShowResourcesFilteredFile                                  string = "test/assets/expected/show/show-resources-filtered.csv"
@@ -190,6 +190,62 @@ const (
 		"kind": "compute#operation"
 	}
 	`
+	simpleGoogleComputePollOperationFailedResponse string = `
+	{
+		"id": "8485551673440766140",
+		"name": "operation-xxxxx-yyyyy-0001",
+		"operationType": "%s",
+		"targetLink": "%s",
+		"targetId": "6645238333082165609",
+		"status": "%s",
+		"user": "test-user@gmail.com",
+		"progress": 100,
+		"insertTime": "2021-03-21T02:24:38.285-07:00",
+		"startTime": "2021-03-21T02:24:38.293-07:00",
+		"endTime": "2021-03-21T02:24:45.870-07:00",
+		"error": {
+			"errors": [
+				{
+					"code": "RESOURCE_ALREADY_EXISTS",
+					"message": "The resource 'projects/infraql-demo/global/networks/kubernetes-the-hard-way-vpc' already exists"
+				}
+			]
+		},
+		"httpErrorStatusCode": 409,
+		"httpErrorMessage": "CONFLICT",
+		"selfLink": "%s",
+		"kind": "compute#operation"
+	}
+	`
+	simpleGoogleComputePollOperationWarningsResponse string = `
+	{
+		"id": "8485551673440766140",
+		"name": "operation-xxxxx-yyyyy-0001",
+		"operationType": "%s",
+		"targetLink": "%s",
+		"targetId": "6645238333082165609",
+		"status": "%s",
+		"user": "test-user@gmail.com",
+		"progress": 100,
+		"insertTime": "2021-03-21T02:24:38.285-07:00",
+		"startTime": "2021-03-21T02:24:38.293-07:00",
+		"endTime": "2021-03-21T02:24:45.870-07:00",
+		"warnings": [
+			{
+				"code": "DEPRECATED_RESOURCE_USED",
+				"message": "The resource 'projects/infraql-demo/global/images/legacy-image' is deprecated",
+				"data": [
+					{
+						"key": "resource_name",
+						"value": "projects/infraql-demo/global/images/legacy-image"
+					}
+				]
+			}
+		],
+		"selfLink": "%s",
+		"kind": "compute#operation"
+	}
+	`
 )
 
 func GetSimpleGoogleNetworkInsertResponse() string {
@@ -212,6 +268,26 @@ func GetSimplePollOperationGoogleNetworkInsertResponse() string {
 	)
 }
 
+func GetFailedPollOperationGoogleNetworkInsertResponse() string {
+	return fmt.Sprintf(
+		simpleGoogleComputePollOperationFailedResponse,
+		"insert",
+		NetworkInsertURL+"/kubernetes-the-hard-way-vpc",
+		"DONE",
+		GoogleComputeInsertOperationURL,
+	)
+}
+
+func GetWarningsPollOperationGoogleNetworkInsertResponse() string {
+	return fmt.Sprintf(
+		simpleGoogleComputePollOperationWarningsResponse,
+		"insert",
+		NetworkInsertURL+"/kubernetes-the-hard-way-vpc",
+		"DONE",
+		GoogleComputeInsertOperationURL,
+	)
+}
+
 func GetSimpleGoogleNetworkDeleteResponse() string {
 	return fmt.Sprintf(
 		simpleGoogleComputeOperationInitialResponse,